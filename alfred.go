@@ -12,6 +12,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/demisto/alfred/bot"
 	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/jobs"
 	"github.com/demisto/alfred/queue"
 	"github.com/demisto/alfred/repo"
 	"github.com/demisto/alfred/web"
@@ -66,6 +67,12 @@ func run(signalCh chan os.Signal) {
 			serviceChannel <- true
 		}()
 		closers = append(closers, &botCloser{b})
+		poller := jobs.NewTAXIIPoller(r)
+		go poller.Start()
+		closers = append(closers, poller)
+		deliverer := jobs.NewWebhookDeliverer(r)
+		go deliverer.Start()
+		closers = append(closers, deliverer)
 		appC := web.NewContext(r, q, b)
 		router := web.New(appC)
 		go func() {
@@ -75,7 +82,7 @@ func run(signalCh chan os.Signal) {
 	}
 
 	if conf.Options.Worker {
-		worker, err := bot.NewWorker(q)
+		worker, err := bot.NewWorker(r, q)
 		if err != nil {
 			logrus.Fatal(err)
 		}
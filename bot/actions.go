@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+	"github.com/demisto/alfred/render"
+	"github.com/demisto/alfred/util"
+)
+
+// HandleAction handles the buttons users click on our warnings
+func (b *Bot) HandleAction(payload util.Object) {
+	team := payload.S("team.id")
+	if team == "" {
+		logrus.Warnf("got empty team in action %s", util.ToJSONString(payload))
+		return
+	}
+	sub := b.relevantTeam(team)
+	if sub == nil {
+		var err error
+		if sub, err = b.loadSubscription(team); err != nil {
+			logrus.WithError(err).Warnf("Error loading team configuration for action - %v", team)
+			return
+		}
+	}
+	channel, user := payload.S("channel.id"), payload.S("user.id")
+	// We reply in the thread of the message so the thread is the message we warned about
+	messageID := payload.S("message.thread_ts")
+	if messageID == "" {
+		messageID = payload.S("container.message_ts")
+	}
+	for _, a := range payload.A("actions") {
+		action, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		actionID := util.Object(action).S("action_id")
+		if actionID == render.ActionDetails {
+			// The button opens the details page by itself
+			continue
+		}
+		value, err := render.ParseActionValue(util.Object(action).S("value"))
+		if err != nil {
+			logrus.WithError(err).Warnf("Invalid value for action %s", actionID)
+			continue
+		}
+		var text string
+		switch actionID {
+		case render.ActionFalsePositive:
+			text = b.reportFalsePositive(sub, channel, user, messageID, value)
+		case render.ActionRescan:
+			text = b.rescan(sub, channel, user, messageID, value)
+		case render.ActionAllowlist:
+			text = b.allowlist(sub, user, value)
+		default:
+			logrus.Warnf("Unknown action %s", actionID)
+			continue
+		}
+		b.postEphemeral(sub, channel, user, messageID, text)
+	}
+}
+
+func (b *Bot) reportFalsePositive(sub *subscription, channel, user, messageID string, value *render.ActionValue) string {
+	err := b.r.StoreFeedback(&domain.Feedback{Team: sub.team.ID, User: user, Channel: channel, MessageID: messageID,
+		Indicator: value.Indicator, IndicatorType: value.Type, Kind: render.ActionFalsePositive})
+	if err != nil {
+		logrus.WithError(err).Warnf("Unable to store false positive for team [%s]", sub.team.ID)
+		return "I had an issue saving your report, please try again."
+	}
+	return fmt.Sprintf("Thanks, we will look into %s.", ioc.Defang(value.Indicator))
+}
+
+func (b *Bot) rescan(sub *subscription, channel, user, messageID string, value *render.ActionValue) string {
+	workReq := domain.NewWorkRequest(sub.team, sub.configuration)
+	workReq.MessageID, workReq.Type, workReq.Text, workReq.ReplyQueue = messageID, "message", value.Indicator, util.Hostname
+	workReq.Context = &domain.Context{Team: sub.team.ExternalID, User: user, OriginalUser: user, Type: "message", Channel: channel,
+		Thread: messageID, Action: render.ActionRescan}
+	// Someone is waiting for the answer
+	workReq.Priority, workReq.Rescan = domain.PriorityHigh, true
+	if err := b.q.PushWork(workReq); err != nil {
+		logrus.WithError(err).Warnf("Unable to push rescan request %s", util.ToJSONStringNoIndent(workReq))
+		return "I had an issue starting the rescan, please try again."
+	}
+	return fmt.Sprintf("Rescanning %s, the result will be posted in the thread.", ioc.Defang(value.Indicator))
+}
+
+func (b *Bot) allowlist(sub *subscription, user string, value *render.ActionValue) string {
+	if err := b.r.AddToAllowlist(sub.team.ID, value.Indicator, user); err != nil {
+		logrus.WithError(err).Warnf("Unable to add to the allowlist of team [%s]", sub.team.ID)
+		return "I had an issue adding to the allowlist, please try again."
+	}
+	// Let all the bots know so the next requests carry the new allowlist
+	if err := b.q.PushConf(sub.team.ExternalID); err != nil {
+		logrus.WithError(err).Warnf("error pushing configuration message for %s", sub.team.ExternalID)
+	}
+	return fmt.Sprintf("%s was added to the team allowlist and will be considered clean.", ioc.Defang(value.Indicator))
+}
+
+// postEphemeral shows the text only to the user that clicked the button
+func (b *Bot) postEphemeral(sub *subscription, channel, user, thread, text string) {
+	message := map[string]interface{}{"channel": channel, "user": user, "text": text, "as_user": true}
+	if thread != "" {
+		message["thread_ts"] = thread
+	}
+	if _, err := sub.s.Do("POST", "chat.postEphemeral", message); err != nil {
+		logrus.WithError(err).Warnf("Unable to post ephemeral message to team [%s] on channel [%s]", sub.team.ID, channel)
+	}
+}
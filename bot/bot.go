@@ -1,13 +1,15 @@
 package bot
 
 import (
-	"regexp"
+	"context"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/cef"
 	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
 	"github.com/demisto/alfred/queue"
 	"github.com/demisto/alfred/repo"
 	"github.com/demisto/alfred/slack"
@@ -33,10 +35,15 @@ type Bot struct {
 	smu           sync.Mutex  // Guards the statistics
 	stats         map[string]*domain.Statistics
 	firstMessages map[string]bool
+	siem          *cef.Forwarder // Forwards the verdicts to a SIEM if syslog is configured
 }
 
 // New returns a new bot
 func New(r *repo.MySQL, q queue.Queue) (*Bot, error) {
+	siem, err := newSIEM()
+	if err != nil {
+		return nil, err
+	}
 	return &Bot{
 		stop:          make(chan bool, 1),
 		r:             r,
@@ -44,6 +51,7 @@ func New(r *repo.MySQL, q queue.Queue) (*Bot, error) {
 		q:             q,
 		stats:         make(map[string]*domain.Statistics),
 		firstMessages: make(map[string]bool),
+		siem:          siem,
 	}, nil
 }
 
@@ -85,17 +93,20 @@ func (b *Bot) loadSubscription(team string) (*subscription, error) {
 	return teamSub, nil
 }
 
-var (
-	ipReg     = regexp.MustCompile("\\b\\d{1,3}\\.\\d{1,3}\\.\\d{1,3}\\.\\d{1,3}\\b")
-	md5Reg    = regexp.MustCompile("\\b[a-fA-F\\d]{32}\\b")
-	sha1Reg   = regexp.MustCompile("\\b[a-fA-F\\d]{40}\\b")
-	sha256Reg = regexp.MustCompile("\\b[a-fA-F\\d]{64}\\b")
-)
-
 func (b *Bot) HandleMessage(msg util.Object) {
 	if msg == nil {
 		return
 	}
+	// Slack retries events it thinks we did not get so make sure we handle each of them once
+	if eventID := msg.S("event_id"); eventID != "" {
+		first, err := b.r.MarkEventSeen(eventID)
+		if err != nil {
+			logrus.WithError(err).Warnf("Unable to check if event %s was seen - handling it anyway", eventID)
+		} else if !first {
+			logrus.Debugf("Ignoring duplicate event %s", eventID)
+			return
+		}
+	}
 	team := msg.S("team_id")
 	if team == "" {
 		logrus.Warnf("got empty team in message %s", util.ToJSONString(msg))
@@ -113,34 +124,49 @@ func (b *Bot) HandleMessage(msg util.Object) {
 	msgType := msg.S("type")
 	switch msgType {
 	case "message":
-		msgUser := msg.S("user")
+		subtype := msg.S("subtype")
+		channel := msg.S("channel")
+		if subtype == "message_deleted" {
+			b.removeReply(team, channel, msg.S("deleted_ts"), sub)
+			return
+		}
+		msgUser, thread := msg.S("user"), msg.S("thread_ts")
+		if subtype == "message_changed" {
+			msgUser, thread = msg.S("message.user"), msg.S("message.thread_ts")
+		}
 		// If it's our message - no need to do anything
 		if msgUser == sub.team.BotUserID {
 			return
 		}
 		text := msg.S("text")
 		ltext := strings.ToLower(text)
-		channel := msg.S("channel")
 		push := false
 		// If this is an internal command to us we should not check hashes, etc.
 		if !(msg.S("subtype") == "" && channel != "" && channel[0] == 'D' &&
 			(strings.HasPrefix(ltext, "join ") || strings.HasPrefix(ltext, "verbose ") || ltext == "config" ||
 				text == "?" || strings.HasPrefix(ltext, "help") || strings.HasPrefix(ltext, "vt ") ||
-				strings.HasPrefix(ltext, "xfe "))) {
+				strings.HasPrefix(ltext, "xfe ") || strings.HasPrefix(ltext, "allow "))) {
 			if msg.S("subtype") == "" {
-				push = strings.Contains(ltext, "<http") || ipReg.MatchString(text) || md5Reg.MatchString(text) || sha1Reg.MatchString(text) || sha256Reg.MatchString(text)
+				push = needsLookup(sub, channel, ioc.Extract(text))
 			}
 			if msg.S("subtype") == "file_share" {
 				push = true
 			}
+			if subtype == "message_changed" {
+				push = b.shouldRescan(team, channel, msg)
+			}
 		}
 		// If we need to handle the message, pass it to the queue
 		if push {
 			logrus.Debugf("Handling message - %+v\n", util.ToJSONString(msg))
-			workReq := domain.WorkRequestFromMessage(msg, sub.team.BotToken, sub.team.VTKey, sub.team.XFEKey, sub.team.XFEPass, sub.team.AFKey)
+			workReq := domain.WorkRequestFromMessage(msg, sub.team, sub.configuration)
 			logrus.Debug("Pushing to queue")
-			ctx := &domain.Context{Team: team, User: msgUser, Type: msgType, Channel: channel, OriginalUser: msgUser}
+			ctx := &domain.Context{Team: team, User: msgUser, Type: msgType, Channel: channel, OriginalUser: msgUser, Thread: thread}
 			workReq.ReplyQueue, workReq.Context = util.Hostname, ctx
+			// Someone is waiting for the answer in a direct message
+			if channel != "" && channel[0] == 'D' {
+				workReq.Priority = domain.PriorityHigh
+			}
 			if err := b.q.PushWork(workReq); err != nil {
 				logrus.WithError(err).Warnf("Unable to push work request %s", util.ToJSONStringNoIndent(workReq))
 			}
@@ -162,6 +188,8 @@ func (b *Bot) HandleMessage(msg util.Object) {
 					b.handleXFE(team, text, channel, sub)
 				case strings.HasPrefix(text, "af "):
 					b.handleXFE(team, text, channel, sub)
+				case strings.HasPrefix(ltext, "allow "):
+					b.handleAllow(team, text, channel, msgUser, sub)
 				}
 			}
 			b.smu.Lock()
@@ -176,6 +204,46 @@ func (b *Bot) HandleMessage(msg util.Object) {
 	}
 }
 
+// needsLookup if any of the indicators is not on the team allowlist. Verbose channels and direct messages
+// still get a reply for allowlisted indicators so the users can see why they are considered clean.
+// Indicators on the team blocklist always get a reply.
+func needsLookup(sub *subscription, channel string, indicators []ioc.Indicator) bool {
+	if len(indicators) == 0 {
+		return false
+	}
+	if channel != "" && channel[0] == 'D' || sub.configuration.IsVerbose(channel) {
+		return true
+	}
+	for _, indicator := range indicators {
+		for _, entry := range sub.configuration.Blocklist {
+			if ioc.Matches(entry.Indicator, indicator) {
+				return true
+			}
+		}
+		if _, ok := ioc.Match(sub.configuration.Allowlist, indicator); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRescan an edited message if its text changed and it either has indicators or we already replied to it
+func (b *Bot) shouldRescan(team, channel string, msg util.Object) bool {
+	// Slack also sends changes when it adds link previews to the message
+	if msg.S("message.text") == msg.S("previous_message.text") {
+		return false
+	}
+	if len(ioc.Extract(msg.S("message.text"))) > 0 {
+		return true
+	}
+	// The indicators were removed so the reply should be removed as well
+	_, err := b.r.BotReply(team, channel, msg.S("message.ts"))
+	if err != nil && err != repo.ErrNotFound {
+		logrus.WithError(err).Warnf("Unable to load reply for message %s", msg.S("message.ts"))
+	}
+	return err == nil
+}
+
 func (b *Bot) storeStatistics() {
 	b.smu.Lock()
 	defer b.smu.Unlock()
@@ -233,7 +301,7 @@ func (b *Bot) subscriptionChanged(team string) {
 
 func (b *Bot) monitorChanges() {
 	for {
-		team, err := b.q.PopConf(0)
+		team, err := b.q.PopConf(context.Background())
 		if err != nil || team == "" {
 			logrus.WithError(err).Info("Quiting monitoring changes")
 			break
@@ -245,7 +313,7 @@ func (b *Bot) monitorChanges() {
 
 func (b *Bot) monitorReplies() {
 	for {
-		reply, err := b.q.PopWorkReply(util.Hostname, 0)
+		reply, err := b.q.PopWorkReply(context.Background(), util.Hostname)
 		if err != nil || reply == nil {
 			logrus.Infof("Quiting monitoring replies - %v\n", err)
 			break
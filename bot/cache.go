@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/repo"
+	"github.com/demisto/alfred/util"
+)
+
+// sectionProvider is implemented by the built-in providers that fill a dedicated section of the reply.
+// The section is cached together with the provider result so it can be restored on a cache hit.
+type sectionProvider interface {
+	section(reply interface{}) interface{}
+}
+
+// providerSection of the reply if the provider has one
+func providerSection(p Provider, reply interface{}) interface{} {
+	if sp, ok := p.(sectionProvider); ok {
+		return sp.section(reply)
+	}
+	return nil
+}
+
+// cacheEntry is what we keep for each provider and indicator
+type cacheEntry struct {
+	Result  domain.ProviderResult `json:"result"`
+	Section json.RawMessage       `json:"section,omitempty"`
+}
+
+// reputationCache keeps the provider results in the database so all workers share them,
+// with an in-process LRU in front of it
+type reputationCache struct {
+	r   *repo.MySQL
+	lru *util.LRU
+}
+
+func newReputationCache(r *repo.MySQL) *reputationCache {
+	c := &reputationCache{r: r}
+	if conf.Options.Cache.Size > 0 {
+		c.lru = util.NewLRU(conf.Options.Cache.Size)
+	}
+	return c
+}
+
+// teamKeyProvider is implemented by the providers that use the own key of the team if it has one
+type teamKeyProvider interface {
+	// teamKey used for the request - empty if the global key is used
+	teamKey(request *domain.WorkRequest) string
+}
+
+// cacheID of the provider result for the indicator. Results fetched with the key of a team are only
+// shared by the requests using the same key - the key might see more and the team pays for its own quota.
+func cacheID(p Provider, request *domain.WorkRequest, indicator string) string {
+	id := p.Name() + ":" + indicator
+	if tk, ok := p.(teamKeyProvider); ok {
+		if key := tk.teamKey(request); key != "" {
+			id = p.Name() + ":" + key + ":" + indicator
+		}
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(id)))
+}
+
+// ttl of the result based on the configuration - zero means do not cache
+func ttl(result int) time.Duration {
+	switch result {
+	case domain.ResultClean:
+		return time.Duration(conf.Options.Cache.Clean) * time.Minute
+	case domain.ResultDirty:
+		return time.Duration(conf.Options.Cache.Dirty) * time.Minute
+	}
+	return time.Duration(conf.Options.Cache.Unknown) * time.Minute
+}
+
+// get the cached result of the provider for the indicator and restore the reply section.
+// Online requests need more details so only results cached by online requests are good for them.
+func (c *reputationCache) get(p Provider, request *domain.WorkRequest, indicator string, reply interface{}) (domain.ProviderResult, bool) {
+	id, online := cacheID(p, request, indicator), request.Online
+	var cached *domain.CachedResult
+	if c.lru != nil {
+		if v, ok := c.lru.Get(id); ok {
+			cached = v.(*domain.CachedResult)
+			if cached.Expires.Before(time.Now()) {
+				c.lru.Remove(id)
+				cached = nil
+			}
+		}
+	}
+	if cached == nil && c.r != nil {
+		var err error
+		cached, err = c.r.CachedResult(id)
+		if err != nil {
+			if err != repo.ErrNotFound {
+				logrus.WithError(err).Warnf("Unable to load cached result of %s", p.Name())
+			}
+			return domain.ProviderResult{}, false
+		}
+		if c.lru != nil {
+			c.lru.Add(id, cached)
+		}
+	}
+	if cached == nil || online && !cached.Online {
+		return domain.ProviderResult{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal([]byte(cached.Result), &entry); err != nil {
+		logrus.WithError(err).Warnf("Unable to parse cached result of %s", p.Name())
+		return domain.ProviderResult{}, false
+	}
+	if section := providerSection(p, reply); section != nil && len(entry.Section) > 0 {
+		if err := json.Unmarshal(entry.Section, section); err != nil {
+			logrus.WithError(err).Warnf("Unable to parse cached section of %s", p.Name())
+			return domain.ProviderResult{}, false
+		}
+	}
+	return entry.Result, true
+}
+
+// set the result of the provider for the indicator - failed lookups are not cached
+func (c *reputationCache) set(p Provider, request *domain.WorkRequest, indicator string, res domain.ProviderResult, reply interface{}) {
+	d := ttl(res.Result)
+	if res.Error != "" || d <= 0 {
+		return
+	}
+	entry := cacheEntry{Result: res}
+	if section := providerSection(p, reply); section != nil {
+		b, err := json.Marshal(section)
+		if err != nil {
+			logrus.WithError(err).Warnf("Unable to cache section of %s", p.Name())
+			return
+		}
+		entry.Section = b
+	}
+	b, err := json.Marshal(&entry)
+	if err != nil {
+		logrus.WithError(err).Warnf("Unable to cache result of %s", p.Name())
+		return
+	}
+	cached := &domain.CachedResult{
+		ID:        cacheID(p, request, indicator),
+		Provider:  p.Name(),
+		Indicator: indicator,
+		Online:    request.Online,
+		Result:    string(b),
+		Expires:   time.Now().Add(d),
+	}
+	if c.lru != nil {
+		c.lru.Add(cached.ID, cached)
+	}
+	if c.r != nil {
+		if err = c.r.SetCachedResult(cached); err != nil {
+			logrus.WithError(err).Warnf("Unable to store cached result of %s", p.Name())
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+)
+
+func TestCacheTeamKey(t *testing.T) {
+	conf.Load("", true)
+	vt, cy := &vtProvider{}, &cyProvider{}
+	global, own := &domain.WorkRequest{Team: "T1"}, &domain.WorkRequest{Team: "T2", VTKey: "key"}
+	if cacheID(vt, global, "evil.com") == cacheID(vt, own, "evil.com") {
+		t.Error("Results fetched with the team key should not be shared with the global key")
+	}
+	if cacheID(cy, global, "evil.com") != cacheID(cy, own, "evil.com") {
+		t.Error("Providers without a team key should share the results")
+	}
+	c := newReputationCache(nil)
+	c.set(vt, own, "evil.com", domain.ProviderResult{Result: domain.ResultDirty, Score: 9}, &domain.DomainReply{})
+	if _, ok := c.get(vt, global, "evil.com", &domain.DomainReply{}); ok {
+		t.Error("The result of the team key was served to a team using the global key")
+	}
+	if res, ok := c.get(vt, &domain.WorkRequest{Team: "T2", VTKey: "key"}, "evil.com", &domain.DomainReply{}); !ok || res.Score != 9 {
+		t.Errorf("Expected the cached result of the team key but got %+v", res)
+	}
+}
@@ -2,11 +2,11 @@ package bot
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"debug/pe"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"runtime"
@@ -15,100 +15,122 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/demisto/alfred/autofocus"
-	"github.com/demisto/alfred/conf"
 	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
 	"github.com/demisto/alfred/queue"
-	"github.com/demisto/goxforce"
-	"github.com/demisto/infinigo"
+	"github.com/demisto/alfred/repo"
+	"github.com/demisto/alfred/util"
 	stackerr "github.com/go-errors/errors"
-	"github.com/slavikm/govt"
-)
-
-const (
-	numOfPositivesToConvict         = 7
-	numOfPositivesToConvictForFiles = 3
-	xfeScoreToConvict               = 7
-	cyScoreToConvict                = -0.5
 )
 
 // Worker reads messages from the queue and does the actual work
 type Worker struct {
-	q    queue.Queue
-	c    chan *domain.WorkRequest
-	xfe  *goxforce.Client
-	vt   *govt.Client
-	cy   *infinigo.Client
-	clam *clamEngine
-	af   *autofocus.Client
+	q         queue.Queue
+	c         chan *domain.WorkRequest
+	high      chan *domain.WorkRequest // High priority work is handled first
+	clam      *clamEngine
+	providers []Provider
+	cache     *reputationCache
+	lists     *teamListsCache
+	r         *repo.MySQL
 }
 
-// NewWorker that loads work messages from the queue
-func NewWorker(q queue.Queue) (*Worker, error) {
-	xfe, err := goxforce.New(
-		goxforce.SetCredentials(conf.Options.XFE.Key, conf.Options.XFE.Password),
-		goxforce.SetErrorLog(log.New(conf.LogWriter, "XFE:", log.Lshortfile)))
-	if err != nil {
-		return nil, err
-	}
-	vt, err := govt.New(
-		govt.SetApikey(conf.Options.VT),
-		govt.SetErrorLog(log.New(conf.LogWriter, "VT:", log.Lshortfile)))
-	if err != nil {
-		return nil, err
-	}
-	cy, err := infinigo.New(
-		infinigo.SetKey(conf.Options.Cy),
-		infinigo.SetErrorLog(log.New(conf.LogWriter, "VT:", log.Lshortfile)))
+// NewWorker that loads work messages from the queue and shares reputation results via the repository
+func NewWorker(r *repo.MySQL, q queue.Queue) (*Worker, error) {
+	clam, err := newClamEngine()
 	if err != nil {
 		return nil, err
 	}
-	clam, err := newClamEngine()
+	providers, err := newProviders()
 	if err != nil {
 		return nil, err
 	}
 	return &Worker{
-		q:    q,
-		c:    make(chan *domain.WorkRequest, runtime.NumCPU()),
-		xfe:  xfe,
-		vt:   vt,
-		cy:   cy,
-		clam: clam,
-		af:   &autofocus.Client{Token: conf.Options.AF},
+		q:         q,
+		c:         make(chan *domain.WorkRequest, runtime.NumCPU()),
+		high:      make(chan *domain.WorkRequest, runtime.NumCPU()),
+		clam:      clam,
+		providers: providers,
+		cache:     newReputationCache(r),
+		lists:     newTeamListsCache(r),
+		r:         r,
 	}, nil
 }
 
+// next work to handle - high priority first. Returns false once the worker is stopped.
+func (w *Worker) next() (*domain.WorkRequest, bool) {
+	select {
+	case msg, ok := <-w.high:
+		if ok {
+			return msg, true
+		}
+	default:
+		select {
+		case msg, ok := <-w.high:
+			if ok {
+				return msg, true
+			}
+		case msg, ok := <-w.c:
+			return msg, ok
+		}
+	}
+	// The high priority lane is closed so we are stopping - finish the normal work left
+	msg, ok := <-w.c
+	return msg, ok
+}
+
 func (w *Worker) handle() {
-	for msg := range w.c {
+	for {
+		msg, ok := w.next()
+		if !ok {
+			return
+		}
 		if msg == nil {
 			w.clam.close()
 			return
 		}
 		if msg.ReplyQueue == "" {
 			logrus.Warnf("got message without a reply queue destination %+v", msg)
+			w.nack(msg)
 			continue
 		}
 		reply := &domain.WorkReply{Context: msg.Context, MessageID: msg.MessageID}
 		switch msg.Type {
 		case "message":
-			if strings.Contains(msg.Text, "<http") {
-				w.handleURL(msg, reply)
+			indicators := ioc.Extract(msg.Text)
+			if urls := ioc.Filter(indicators, ioc.URL); len(urls) > 0 {
+				w.handleURL(msg, reply, urls)
+			}
+			if ips := ioc.Filter(indicators, ioc.IP); len(ips) > 0 {
+				w.handleIP(msg, reply, ips)
 			}
-			if ipReg.MatchString(msg.Text) {
-				w.handleIP(msg, reply)
+			if domains := ioc.Filter(indicators, ioc.Domain); len(domains) > 0 {
+				w.handleDomains(msg, reply, domains)
 			}
-			if md5Reg.MatchString(msg.Text) || sha1Reg.MatchString(msg.Text) || sha256Reg.MatchString(msg.Text) {
-				w.handleHashes(msg, reply)
+			if hashes := ioc.Filter(indicators, ioc.Hash); len(hashes) > 0 {
+				w.handleHashes(msg, reply, hashes)
 			}
 		case "file":
 			w.handleFile(msg, reply)
 		}
 		if err := w.q.PushWorkReply(msg.ReplyQueue, reply); err != nil {
 			logrus.WithError(err).Warnf("error pushing message to reply queue %+v", msg)
+			w.nack(msg)
+			continue
+		}
+		if err := w.q.Ack(msg); err != nil {
+			logrus.WithError(err).Warnf("error acking message %s", msg.MessageID)
 		}
 	}
 }
 
+// nack the message so it is retried or dead lettered
+func (w *Worker) nack(msg *domain.WorkRequest) {
+	if err := w.q.Nack(msg); err != nil {
+		logrus.WithError(err).Warnf("error nacking message %s", msg.MessageID)
+	}
+}
+
 // Start the worker process. To stop, just close the queue.
 func (w *Worker) Start() {
 	// Right now, just use the number of CPUs
@@ -116,277 +138,193 @@ func (w *Worker) Start() {
 		go w.handle()
 	}
 	for {
-		msg, err := w.q.PopWork(0)
+		msg, err := w.q.PopWork(context.Background())
 		if err != nil || msg == nil {
 			logrus.Infof("stopping WorkManager process - %v, %v", err, msg)
+			close(w.high)
 			close(w.c)
 			return
 		}
 		logrus.Debugf("working on message - %+v", msg)
-		w.c <- msg
+		if msg.Priority == domain.PriorityHigh {
+			w.high <- msg
+		} else {
+			w.c <- msg
+		}
 	}
 }
 
-// localGetReputation - get reputation clients from VT, XFE and AF
-func (w *Worker) localGetReputation(request *domain.WorkRequest) (*goxforce.Client, *govt.Client, *autofocus.Client) {
-	vt := w.vt
-	if request.VTKey != "" {
-		vtTmp, err := govt.New(
-			govt.SetApikey(request.VTKey),
-			govt.SetErrorLog(log.New(conf.LogWriter, "VT:", log.Lshortfile)))
-		if err == nil {
-			vt = vtTmp
+// enabledProviders for the request - if the team did not choose, all of them
+func (w *Worker) enabledProviders(request *domain.WorkRequest) []Provider {
+	if len(request.Providers) == 0 {
+		return w.providers
+	}
+	var providers []Provider
+	for _, p := range w.providers {
+		if util.In(request.Providers, p.Name()) {
+			providers = append(providers, p)
 		}
 	}
-	xfe := w.xfe
-	if request.XFEKey != "" && request.XFEPass != "" {
-		xfeTmp, err := goxforce.New(
-			goxforce.SetCredentials(request.XFEKey, request.XFEPass),
-			goxforce.SetErrorLog(log.New(conf.LogWriter, "XFE:", log.Lshortfile)))
-		if err == nil {
-			xfe = xfeTmp
+	return providers
+}
+
+// provider with the given name if it exists
+func (w *Worker) provider(name string) Provider {
+	for _, p := range w.providers {
+		if p.Name() == name {
+			return p
 		}
 	}
-	af := w.af
-	if request.AFKey != "" {
-		af = &autofocus.Client{Token: request.AFKey}
+	return nil
+}
+
+// supports checks if the provider can look up the indicator type of the reply
+func supports(p Provider, reply interface{}) bool {
+	switch reply.(type) {
+	case *domain.HashReply:
+		_, ok := p.(HashProvider)
+		return ok
+	case *domain.URLReply:
+		_, ok := p.(URLProvider)
+		return ok
+	case *domain.IPReply:
+		_, ok := p.(IPProvider)
+		return ok
+	case *domain.DomainReply:
+		_, ok := p.(DomainProvider)
+		return ok
 	}
-	return xfe, vt, af
+	return false
 }
 
-func (w *Worker) handleURL(request *domain.WorkRequest, reply *domain.WorkReply) {
-	text := request.Text
-	online := request.Online
-	xfe, vt, _ := w.localGetReputation(request)
-	for {
-		start := strings.Index(text, "<http")
-		if start < 0 {
-			break
-		}
-		end := strings.Index(text[start:], ">")
-		if end < start {
-			break
-		}
-		end = end + start
-		realEnd := end
-		filter := strings.Index(text[start:end], "|")
-		if filter > 0 {
-			end = start + filter
+// query the provider for the indicator based on the reply type
+func query(p Provider, request *domain.WorkRequest, indicator string, reply interface{}) domain.ProviderResult {
+	switch r := reply.(type) {
+	case *domain.HashReply:
+		return p.(HashProvider).Hash(request, indicator, r)
+	case *domain.URLReply:
+		return p.(URLProvider).URL(request, indicator, r)
+	case *domain.IPReply:
+		return p.(IPProvider).IP(request, indicator, r)
+	case *domain.DomainReply:
+		return p.(DomainProvider).Domain(request, indicator, r)
+	}
+	return domain.ProviderResult{Result: domain.ResultUnknown}
+}
+
+// lookup the indicator with all the providers supporting it in parallel and collect the results.
+// Results are taken from the cache if possible and fresh results are stored there for the other workers.
+func (w *Worker) lookup(request *domain.WorkRequest, providers []Provider, indicator string, reply interface{}) map[string]domain.ProviderResult {
+	results := make(map[string]domain.ProviderResult)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range providers {
+		if !supports(p, reply) {
+			continue
 		}
-		url := text[start+1 : end]
-		logrus.Debugf("URL found - %s\n", url)
-		text = text[realEnd:]
-		reply.URLs = append(reply.URLs, domain.URLReply{})
-		counter := len(reply.URLs) - 1
-		reply.URLs[counter].Details = url
-		reply.Type |= domain.ReplyTypeURL
-		// Do the network commands in parallel
-		var wg sync.WaitGroup
-		wg.Add(2)
-		go func() {
-			defer wg.Done()
-			urlResp, err := xfe.URL(url)
-			if err != nil {
-				// Small hack - see if the URL was not found
-				if strings.Contains(err.Error(), "404") {
-					reply.URLs[counter].XFE.NotFound = true
-				} else {
-					reply.URLs[counter].XFE.Error = err.Error()
-				}
-			} else {
-				reply.URLs[counter].XFE.URLDetails = urlResp.Result
-			}
-			resolve, err := xfe.Resolve(url)
-			if err == nil {
-				reply.URLs[counter].XFE.Resolve = *resolve
-			}
-			if online {
-				malware, err := xfe.URLMalware(url)
-				if err == nil {
-					reply.URLs[counter].XFE.URLMalware = *malware
+		wg.Add(1)
+		go func(p Provider) {
+			defer func() {
+				if err := recover(); err != nil {
+					logrus.Errorf("Provider %s failed - %v", p.Name(), err)
+					logrus.Error(stackerr.Wrap(err, 2).ErrorStack())
 				}
+				wg.Done()
+			}()
+			var res domain.ProviderResult
+			ok := false
+			// A rescan was asked for because the cached results might be stale
+			if !request.Rescan {
+				res, ok = w.cache.get(p, request, indicator, reply)
 			}
-		}()
-		go func() {
-			defer wg.Done()
-			vtResp, err := vt.GetUrlReport(url)
-			if err != nil {
-				reply.URLs[counter].VT.Error = err.Error()
-			} else {
-				reply.URLs[counter].VT.URLReport = *vtResp
+			if !ok {
+				res = query(p, request, indicator, reply)
+				w.cache.set(p, request, indicator, res, reply)
 			}
-		}()
-		wg.Wait()
-		if reply.URLs[counter].XFE.URLDetails.Score >= xfeScoreToConvict || reply.URLs[counter].VT.URLReport.Positives >= numOfPositivesToConvict {
-			// This is known bad scenario
-			reply.URLs[counter].Result = domain.ResultDirty
-		} else if !reply.URLs[counter].XFE.NotFound || reply.URLs[counter].VT.URLReport.ResponseCode == 1 {
-			// At least one of reputation services found this to be known good
-			// Keep the default
-			reply.URLs[counter].Result = domain.ResultClean
+			mu.Lock()
+			defer mu.Unlock()
+			results[p.Name()] = res
+		}(p)
+	}
+	wg.Wait()
+	return results
+}
+
+func (w *Worker) handleURL(request *domain.WorkRequest, reply *domain.WorkReply, urls []ioc.Indicator) {
+	providers := w.enabledProviders(request)
+	policy := policyFor(request, domain.ReplyTypeURL)
+	for _, u := range urls {
+		logrus.Debugf("URL found - %s\n", u.Value)
+		res := &domain.URLReply{Details: u.Value, Original: u.Original}
+		reply.Type |= domain.ReplyTypeURL
+		if result, rule, results, ok := w.localVerdict(request, u); ok {
+			res.Result, res.Rule, res.Providers = result, rule, results
+		} else {
+			res.Providers = w.lookup(request, providers, u.Value, res)
+			res.Result, res.Rule = verdict(policy, res.Providers)
 		}
+		reply.URLs = append(reply.URLs, *res)
 	}
 }
 
-func (w *Worker) handleIP(request *domain.WorkRequest, reply *domain.WorkReply) {
-	text := request.Text
-	online := request.Online
-	xfe, vt, _ := w.localGetReputation(request)
-	ips := ipReg.FindAllString(text, -1)
+func (w *Worker) handleIP(request *domain.WorkRequest, reply *domain.WorkReply, ips []ioc.Indicator) {
+	providers := w.enabledProviders(request)
+	policy := policyFor(request, domain.ReplyTypeIP)
 	for _, ip := range ips {
-		reply.IPs = append(reply.IPs, domain.IPReply{})
-		counter := len(reply.IPs) - 1
-		reply.IPs[counter].Details = ip
+		res := &domain.IPReply{Details: ip.Value, Original: ip.Original}
 		reply.Type |= domain.ReplyTypeIP
-		// First, let's check if IP is globally unicast addressable and is public
-		ipData := net.ParseIP(ip)
-		ipv4 := ipData.To4()
-		if ipv4 == nil {
-			// If not IPv4 then return - by default it will be marked clean
-			reply.IPs[counter].XFE.NotFound = true
-			return
-		}
-		if !ipv4.IsGlobalUnicast() {
-			// If not global unicast ignore
-			reply.IPs[counter].XFE.NotFound = true
-			return
-		}
-		// Private networks
-		if ipv4[0] == 10 || ipv4[0] == 172 && ipv4[1] >= 16 && ipv4[1] <= 31 || ipv4[0] == 192 && ipv4[1] == 168 {
-			reply.IPs[counter].XFE.NotFound = true
-			reply.IPs[counter].Private = true
-			return
-		}
-		var wg sync.WaitGroup
-		wg.Add(2)
-		go func() {
-			defer wg.Done()
-			ipResp, err := xfe.IPR(ip)
-			if err != nil {
-				// Small hack - see if the URL was not found
-				if strings.Contains(err.Error(), "404") {
-					reply.IPs[counter].XFE.NotFound = true
-				} else {
-					reply.IPs[counter].XFE.Error = err.Error()
-				}
-			} else {
-				reply.IPs[counter].XFE.IPReputation = *ipResp
-				if online {
-					hist, err := xfe.IPRHistory(ip)
-					if err == nil {
-						reply.IPs[counter].XFE.IPHistory = *hist
-					}
-				}
-			}
-		}()
-		go func() {
-			defer wg.Done()
-			vtResp, err := vt.GetIpReport(ip)
-			if err != nil {
-				reply.IPs[counter].VT.Error = err.Error()
-			} else {
-				reply.IPs[counter].VT.IPReport = *vtResp
-			}
-		}()
-		wg.Wait()
-		var vtPositives uint16
-		now := time.Now()
-		for i := range reply.IPs[counter].VT.IPReport.DetectedUrls {
-			t, err := time.Parse("2006-01-02 15:04:05", reply.IPs[counter].VT.IPReport.DetectedUrls[i].ScanDate)
-			if err != nil {
-				logrus.Debugf("Error parsing scan date - %v", err)
-				continue
-			}
-			if reply.IPs[counter].VT.IPReport.DetectedUrls[i].Positives > vtPositives && t.Add(365*24*time.Hour).After(now) {
-				vtPositives = reply.IPs[counter].VT.IPReport.DetectedUrls[i].Positives
-			}
+		if result, rule, results, ok := w.localVerdict(request, ip); ok {
+			// Internal addresses from the team incidents can be on the team lists and intel as well
+			res.Result, res.Rule, res.Providers = result, rule, results
+		} else if isInternalIP(net.ParseIP(ip.Value)) {
+			// There is no reputation for internal addresses - by default they are marked clean
+			res.XFE.NotFound, res.Private = true, true
+			res.Result, res.Rule = domain.ResultClean, "private"
+		} else {
+			res.Providers = w.lookup(request, providers, ip.Value, res)
+			res.Result, res.Rule = verdict(policy, res.Providers)
 		}
-		reply.IPs[counter].Result = domain.ResultUnknown
-		if reply.IPs[counter].XFE.IPReputation.Score >= xfeScoreToConvict || vtPositives >= numOfPositivesToConvict && reply.IPs[counter].XFE.NotFound {
-			// This is known bad scenario
-			reply.IPs[counter].Result = domain.ResultDirty
-		} else if !reply.IPs[counter].XFE.NotFound || reply.IPs[counter].VT.IPReport.ResponseCode == 1 {
-			// At least one of reputation services found this to be known good
-			// Keep the default
-			reply.IPs[counter].Result = domain.ResultClean
+		reply.IPs = append(reply.IPs, *res)
+	}
+}
+
+func (w *Worker) handleDomains(request *domain.WorkRequest, reply *domain.WorkReply, domains []ioc.Indicator) {
+	providers := w.enabledProviders(request)
+	policy := policyFor(request, domain.ReplyTypeDomain)
+	for _, name := range domains {
+		res := &domain.DomainReply{Details: name.Value, Original: name.Original}
+		reply.Type |= domain.ReplyTypeDomain
+		if result, rule, results, ok := w.localVerdict(request, name); ok {
+			res.Result, res.Rule, res.Providers = result, rule, results
+		} else {
+			res.Providers = w.lookup(request, providers, name.Value, res)
+			res.Result, res.Rule = verdict(policy, res.Providers)
 		}
+		reply.Domains = append(reply.Domains, *res)
 	}
 }
 
-func (w *Worker) handleHashes(request *domain.WorkRequest, reply *domain.WorkReply) {
-	text := request.Text
-	xfe, vt, af := w.localGetReputation(request)
-	hashes := md5Reg.FindAllString(text, -1)
-	hashes = append(hashes, sha1Reg.FindAllString(text, -1)...)
-	hashes = append(hashes, sha256Reg.FindAllString(text, -1)...)
+func (w *Worker) handleHashes(request *domain.WorkRequest, reply *domain.WorkReply, hashes []ioc.Indicator) {
+	providers := w.enabledProviders(request)
+	policy := policyFor(request, domain.ReplyTypeHash)
 	for _, hash := range hashes {
-		var res domain.HashReply
+		res := &domain.HashReply{Details: hash.Value, Original: hash.Original}
 		reply.Type |= domain.ReplyTypeHash
-		res.Details = hash
-		var wg sync.WaitGroup
-		wg.Add(4)
-		go func() {
-			defer wg.Done()
-			xfeResp, err := xfe.MalwareDetails(hash)
-			if err != nil {
-				// Small hack - see if the file was not found
-				if strings.Contains(err.Error(), "404") {
-					res.XFE.NotFound = true
-				} else {
-					res.XFE.Error = err.Error()
-				}
-			} else {
-				res.XFE.Malware = xfeResp.Malware
-			}
-		}()
-		go func() {
-			defer wg.Done()
-			vtResp, err := vt.GetFileReport(hash)
-			if err != nil {
-				res.VT.Error = err.Error()
-			} else {
-				res.VT.FileReport = *vtResp
-			}
-		}()
-		go func() {
-			defer wg.Done()
-			cyResp, err := w.cy.Query("", hash)
-			if err != nil {
-				res.Cy.Error = err.Error()
-			} else {
-				// Should be only one
-				for k := range cyResp {
-					res.Cy.Result = cyResp[k]
-				}
-			}
-		}()
-		go func() {
-			defer wg.Done()
-			afResp := af.HashReputation(hash)
-			if afResp != nil {
-				res.AF.Result = *afResp
-			} else {
-				res.AF.Error = "Sample not found"
-			}
-		}()
-		wg.Wait()
-		res.Result = domain.ResultUnknown
-		if len(res.XFE.Malware.Family) > 0 || len(res.XFE.Malware.Origins.External.Family) > 0 ||
-			res.VT.FileReport.Positives >= numOfPositivesToConvictForFiles ||
-			res.Cy.Result.GeneralScore < cyScoreToConvict || res.AF.Result.Malware {
-			// This is known bad scenario
-			res.Result = domain.ResultDirty
-		} else if !res.XFE.NotFound || res.VT.FileReport.ResponseCode == 1 || res.Cy.Result.StatusCode == 1 ||
-			!res.AF.Result.Created.IsZero() && !res.AF.Result.Malware {
-			// At least one of reputation services found this to be known good
-			// Keep the default
-			res.Result = domain.ResultClean
+		if result, rule, results, ok := w.localVerdict(request, hash); ok {
+			res.Result, res.Rule, res.Providers = result, rule, results
+		} else {
+			res.Providers = w.lookup(request, providers, hash.Value, res)
+			res.Result, res.Rule = verdict(policy, res.Providers)
 		}
-		reply.Hashes = append(reply.Hashes, res)
+		reply.Hashes = append(reply.Hashes, *res)
 	}
 }
 
-func (w *Worker) uploadToCylance(reply *domain.WorkReply, buf *bytes.Buffer) {
+func (w *Worker) uploadToCylance(request *domain.WorkRequest, reply *domain.WorkReply, buf *bytes.Buffer) {
+	cy, ok := w.provider("cy").(*cyProvider)
+	if !ok {
+		return
+	}
 	// For now, just check Windows executables
 	_, err := pe.NewFile(bytes.NewReader(buf.Bytes()))
 	if err != nil {
@@ -394,7 +332,7 @@ func (w *Worker) uploadToCylance(reply *domain.WorkReply, buf *bytes.Buffer) {
 		return
 	}
 	logrus.Debugf("Sending file %s to Cylance", reply.File.Details.Name)
-	resp, err := w.cy.Upload(reply.Hashes[0].Cy.Result.ConfirmCode, bytes.NewReader(buf.Bytes()))
+	resp, err := cy.c.Upload(reply.Hashes[0].Cy.Result.ConfirmCode, bytes.NewReader(buf.Bytes()))
 	if err != nil {
 		logrus.WithError(err).Infof("Error uploading the file - configuration code was %s", reply.Hashes[0].Cy.Result.ConfirmCode)
 		return
@@ -405,7 +343,7 @@ func (w *Worker) uploadToCylance(reply *domain.WorkReply, buf *bytes.Buffer) {
 			tries := 3
 			for i := 0; i < tries; i++ {
 				time.Sleep(10 * time.Second)
-				cyResp, err := w.cy.Query("", reply.Hashes[0].Details)
+				cyResp, err := cy.c.Query("", reply.Hashes[0].Details)
 				if err != nil {
 					return
 				} else {
@@ -413,6 +351,12 @@ func (w *Worker) uploadToCylance(reply *domain.WorkReply, buf *bytes.Buffer) {
 					for k := range cyResp {
 						if cyResp[k].StatusCode == 1 {
 							reply.Hashes[0].Cy.Result = cyResp[k]
+							if reply.Hashes[0].Providers != nil {
+								res := cyResult(cyResp[k])
+								w.cache.set(cy, request, reply.Hashes[0].Details, res, &reply.Hashes[0])
+								reply.Hashes[0].Providers["cy"] = res
+								reply.Hashes[0].Result, reply.Hashes[0].Rule = verdict(policyFor(request, domain.ReplyTypeHash), reply.Hashes[0].Providers)
+							}
 							return
 						} else if cyResp[k].StatusCode != 2 {
 							// If there is an error it means Cylance does not handle the file so no point in waiting
@@ -471,8 +415,7 @@ func (w *Worker) handleFile(request *domain.WorkRequest, reply *domain.WorkReply
 			reply.File.Error = err.Error()
 		}
 	}()
-	request.Text = h
-	w.handleHashes(request, reply)
+	w.handleHashes(request, reply, []ioc.Indicator{{Type: ioc.Hash, Value: h, Original: h}})
 	wg.Wait()
 	reply.File.Result = domain.ResultUnknown
 	if len(reply.Hashes) != 1 {
@@ -481,14 +424,21 @@ func (w *Worker) handleFile(request *domain.WorkRequest, reply *domain.WorkReply
 	}
 	// If Cylance does not know about the file but can handle it then handle it...
 	if reply.Hashes[0].Cy.Result.StatusCode == 3 {
-		w.uploadToCylance(reply, buf)
+		w.uploadToCylance(request, reply, buf)
 	}
-	if reply.File.Virus != "" || reply.Hashes[0].Result == domain.ResultDirty {
+	if strings.HasPrefix(reply.Hashes[0].Rule, domain.RuleBlocklist) {
+		// The team knows this file from its own incidents
+		reply.File.Result, reply.File.Rule = domain.ResultDirty, reply.Hashes[0].Rule
+	} else if strings.HasPrefix(reply.Hashes[0].Rule, domain.RuleAllowlist) {
+		// The team trusts this file
+		reply.File.Result, reply.File.Rule = domain.ResultClean, reply.Hashes[0].Rule
+	} else if reply.File.Virus != "" {
 		// This is known bad scenario
-		reply.File.Result = domain.ResultDirty
-	} else if reply.File.Virus == "" || reply.Hashes[0].Result == domain.ResultClean {
-		// At least one of reputation services found this to be known good
-		// Keep the default
-		reply.File.Result = domain.ResultClean
+		reply.File.Result, reply.File.Rule = domain.ResultDirty, "clamav"
+	} else if reply.Hashes[0].Result == domain.ResultDirty {
+		reply.File.Result, reply.File.Rule = domain.ResultDirty, reply.Hashes[0].Rule
+	} else {
+		// ClamAV did not find anything so unless one of the reputation services convicted it we keep the default
+		reply.File.Result, reply.File.Rule = domain.ResultClean, "clamav"
 	}
 }
@@ -0,0 +1,27 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/demisto/alfred/domain"
+)
+
+func TestWorkerNext(t *testing.T) {
+	w := &Worker{c: make(chan *domain.WorkRequest, 2), high: make(chan *domain.WorkRequest, 2)}
+	w.c <- &domain.WorkRequest{MessageID: "normal"}
+	w.high <- &domain.WorkRequest{MessageID: "high", Priority: domain.PriorityHigh}
+	for _, expected := range []string{"high", "normal"} {
+		if msg, ok := w.next(); !ok || msg.MessageID != expected {
+			t.Fatalf("Expected %s but got %+v", expected, msg)
+		}
+	}
+	w.c <- &domain.WorkRequest{MessageID: "left"}
+	close(w.high)
+	close(w.c)
+	if msg, ok := w.next(); !ok || msg.MessageID != "left" {
+		t.Fatalf("Expected the normal work left after stop but got %+v", msg)
+	}
+	if _, ok := w.next(); ok {
+		t.Fatal("Expected the worker to stop")
+	}
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+	"github.com/demisto/alfred/repo"
+)
+
+// teamListsTTL is how long the worker keeps the lists of a team before loading them again
+const teamListsTTL = time.Minute
+
+// newAllowlist from the entries of the team
+func newAllowlist(entries []string) []ioc.Entry {
+	res := make([]ioc.Entry, len(entries))
+	for i := range entries {
+		res[i] = ioc.NewEntry(entries[i])
+	}
+	return res
+}
+
+// blocklistEntry with its network parsed
+type blocklistEntry struct {
+	ioc.Entry
+	Description string
+}
+
+// newBlocklist from the entries of the team
+func newBlocklist(entries []domain.BlocklistEntry) []blocklistEntry {
+	res := make([]blocklistEntry, len(entries))
+	for i := range entries {
+		res[i] = blocklistEntry{Entry: ioc.NewEntry(entries[i].Indicator), Description: entries[i].Description}
+	}
+	return res
+}
+
+// intelNetwork is a CIDR indicator from the team intel with its network parsed
+type intelNetwork struct {
+	indicator domain.IntelIndicator
+	network   *net.IPNet
+}
+
+// newIntelNetworks from the CIDR indicators of the team - invalid networks are skipped
+func newIntelNetworks(indicators []domain.IntelIndicator) []intelNetwork {
+	var res []intelNetwork
+	for i := range indicators {
+		if _, ipnet, err := net.ParseCIDR(indicators[i].Value); err == nil {
+			res = append(res, intelNetwork{indicator: indicators[i], network: ipnet})
+		}
+	}
+	return res
+}
+
+// teamLists are the lists of a team the worker checks before asking the providers
+type teamLists struct {
+	loaded    time.Time
+	allowlist []ioc.Entry
+	blocklist []blocklistEntry
+	networks  []intelNetwork
+}
+
+// teamListsCache keeps the lists of each team for a short while so they are not loaded for every indicator
+// and do not have to travel with every work request
+type teamListsCache struct {
+	r     *repo.MySQL
+	mu    sync.Mutex
+	teams map[string]*teamLists
+}
+
+func newTeamListsCache(r *repo.MySQL) *teamListsCache {
+	return &teamListsCache{r: r, teams: make(map[string]*teamLists)}
+}
+
+// load the lists of the team from the repository
+func (c *teamListsCache) load(team string) (*teamLists, error) {
+	allowlist, err := c.r.Allowlist(team)
+	if err != nil {
+		return nil, err
+	}
+	blocklist, err := c.r.Blocklist(team)
+	if err != nil {
+		return nil, err
+	}
+	networks, err := c.r.IntelNetworks(team)
+	if err != nil {
+		return nil, err
+	}
+	return &teamLists{loaded: time.Now(), allowlist: newAllowlist(allowlist), blocklist: newBlocklist(blocklist), networks: newIntelNetworks(networks)}, nil
+}
+
+// get the lists of the team loading them if they are missing or stale. If loading fails we keep using what we have.
+func (c *teamListsCache) get(team string) *teamLists {
+	if c == nil || c.r == nil || team == "" {
+		return &teamLists{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lists, ok := c.teams[team]
+	if ok && time.Since(lists.loaded) < teamListsTTL {
+		return lists
+	}
+	loaded, err := c.load(team)
+	if err != nil {
+		logrus.WithError(err).Warnf("Unable to load the lists of team [%s]", team)
+		if ok {
+			return lists
+		}
+		return &teamLists{}
+	}
+	c.teams[team] = loaded
+	return loaded
+}
+
+// intel returns the valid STIX indicator of the team with the given type and value
+func (w *Worker) intel(team string, indicatorType int, value string) (*domain.IntelIndicator, bool) {
+	i, err := w.r.IntelIndicator(team, indicatorType, value)
+	if err != nil {
+		if err != repo.ErrNotFound {
+			logrus.WithError(err).Warnf("Unable to check the intel of team [%s]", team)
+		}
+		return nil, false
+	}
+	return i, true
+}
+
+// intelIP checks the address and then the cached networks in the team intel
+func (w *Worker) intelIP(team, value string) (*domain.IntelIndicator, bool) {
+	if i, ok := w.intel(team, domain.ReplyTypeIP, value); ok {
+		return i, true
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, false
+	}
+	return matchNetworks(w.lists.get(team).networks, ip)
+}
+
+// matchNetworks returns the first network that contains the address and is still valid - the networks are cached
+// so some might have expired since they were loaded
+func matchNetworks(networks []intelNetwork, ip net.IP) (*domain.IntelIndicator, bool) {
+	now := time.Now()
+	for i := range networks {
+		if networks[i].network.Contains(ip) && networks[i].indicator.Active(now) {
+			return &networks[i].indicator, true
+		}
+	}
+	return nil, false
+}
+
+// stixVerdict for indicators found in the STIX intel the team imported. URLs are checked by their host as well.
+func (w *Worker) stixVerdict(request *domain.WorkRequest, indicator ioc.Indicator) (int, string, map[string]domain.ProviderResult, bool) {
+	if request.Team == "" || w.r == nil {
+		return domain.ResultUnknown, "", nil, false
+	}
+	var i *domain.IntelIndicator
+	ok := false
+	switch indicator.Type {
+	case ioc.Hash:
+		i, ok = w.intel(request.Team, domain.ReplyTypeHash, indicator.Value)
+	case ioc.Domain:
+		i, ok = w.intel(request.Team, domain.ReplyTypeDomain, indicator.Value)
+	case ioc.IP:
+		i, ok = w.intelIP(request.Team, indicator.Value)
+	case ioc.URL:
+		if i, ok = w.intel(request.Team, domain.ReplyTypeURL, indicator.Value); !ok {
+			if u, err := url.Parse(indicator.Value); err == nil && u.Hostname() != "" {
+				if net.ParseIP(u.Hostname()) != nil {
+					i, ok = w.intelIP(request.Team, u.Hostname())
+				} else {
+					i, ok = w.intel(request.Team, domain.ReplyTypeDomain, u.Hostname())
+				}
+			}
+		}
+	}
+	if !ok {
+		return domain.ResultUnknown, "", nil, false
+	}
+	summary := "Found in the team STIX intel as " + i.Value
+	if i.Name != "" {
+		summary += " - " + i.Name
+	}
+	providers := map[string]domain.ProviderResult{domain.ProviderSTIX: {Result: domain.ResultDirty, Summary: summary, Details: i}}
+	return domain.ResultDirty, domain.RuleSTIX + i.STIXID, providers, true
+}
+
+// localVerdict for indicators on the team lists or in the team intel - these are not sent to the providers
+func (w *Worker) localVerdict(request *domain.WorkRequest, indicator ioc.Indicator) (int, string, map[string]domain.ProviderResult, bool) {
+	if result, rule, results, ok := teamVerdict(w.lists.get(request.Team), indicator); ok {
+		return result, rule, results, true
+	}
+	return w.stixVerdict(request, indicator)
+}
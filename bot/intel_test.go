@@ -0,0 +1,27 @@
+package bot
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+func TestMatchNetworks(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	networks := newIntelNetworks([]domain.IntelIndicator{
+		{STIXID: "indicator--1", Value: "10.0.0.0/8", ValidUntil: &expired},
+		{STIXID: "indicator--2", Value: "not a network"},
+		{STIXID: "indicator--3", Value: "10.1.0.0/16"},
+	})
+	if len(networks) != 2 {
+		t.Fatalf("Expected the invalid network to be skipped but got %+v", networks)
+	}
+	if i, ok := matchNetworks(networks, net.ParseIP("10.1.2.3")); !ok || i.STIXID != "indicator--3" {
+		t.Errorf("Expected the valid network to match but got %+v", i)
+	}
+	if i, ok := matchNetworks(networks, net.ParseIP("10.2.0.1")); ok {
+		t.Errorf("Expected the expired network not to match but got %+v", i)
+	}
+}
@@ -0,0 +1,32 @@
+package bot
+
+import "net"
+
+// internalNets have no reputation information - private, shared, link-local and unique local addresses
+var internalNets = parseCIDRs("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "169.254.0.0/16",
+	"fc00::/7", "fe80::/10")
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isInternalIP checks if the IP is not a public unicast address so there is no point in looking it up
+func isInternalIP(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() {
+		return true
+	}
+	for _, n := range internalNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,493 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/demisto/alfred/autofocus"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/util"
+	"github.com/demisto/goxforce"
+	"github.com/demisto/infinigo"
+	"github.com/slavikm/govt"
+)
+
+// Provider is a source of reputation information.
+// A provider supports an indicator type by implementing the matching lookup interface as well.
+type Provider interface {
+	// Name of the provider - the key of its results in the reply
+	Name() string
+}
+
+// HashProvider can look up file hashes
+type HashProvider interface {
+	Provider
+	Hash(request *domain.WorkRequest, hash string, reply *domain.HashReply) domain.ProviderResult
+}
+
+// URLProvider can look up URLs
+type URLProvider interface {
+	Provider
+	URL(request *domain.WorkRequest, url string, reply *domain.URLReply) domain.ProviderResult
+}
+
+// IPProvider can look up IP addresses
+type IPProvider interface {
+	Provider
+	IP(request *domain.WorkRequest, ip string, reply *domain.IPReply) domain.ProviderResult
+}
+
+// DomainProvider can look up domain names
+type DomainProvider interface {
+	Provider
+	Domain(request *domain.WorkRequest, name string, reply *domain.DomainReply) domain.ProviderResult
+}
+
+// ProviderFactory creates a provider when the worker starts
+type ProviderFactory func() (Provider, error)
+
+var (
+	providersMu       sync.Mutex
+	providerFactories = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider makes a reputation provider available to the worker under the given name.
+// It is usually called from the init function of the package implementing the provider.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if factory == nil {
+		panic("bot: RegisterProvider factory is nil")
+	}
+	if _, dup := providerFactories[name]; dup {
+		panic("bot: RegisterProvider called twice for provider " + name)
+	}
+	providerFactories[name] = factory
+}
+
+// Providers returns the names of the registered providers sorted
+func Providers() []string {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	var names []string
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newProviders creates all the registered providers
+func newProviders() ([]Provider, error) {
+	var providers []Provider
+	for _, name := range Providers() {
+		providersMu.Lock()
+		factory := providerFactories[name]
+		providersMu.Unlock()
+		p, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create provider %s - %v", name, err)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+func init() {
+	RegisterProvider("xfe", newXFEProvider)
+	RegisterProvider("vt", newVTProvider)
+	RegisterProvider("cy", newCyProvider)
+	RegisterProvider("af", newAFProvider)
+}
+
+// notFound checks the error message of the clients for a 404 reply
+func notFound(err error) bool {
+	return strings.Contains(err.Error(), "404")
+}
+
+// xfeProvider uses IBM X-Force Exchange
+type xfeProvider struct {
+	c *goxforce.Client
+}
+
+func newXFEProvider() (Provider, error) {
+	xfe, err := goxforce.New(
+		goxforce.SetCredentials(conf.Options.XFE.Key, conf.Options.XFE.Password),
+		goxforce.SetErrorLog(log.New(conf.LogWriter, "XFE:", log.Lshortfile)))
+	if err != nil {
+		return nil, err
+	}
+	return &xfeProvider{c: xfe}, nil
+}
+
+func (p *xfeProvider) Name() string {
+	return "xfe"
+}
+
+func (p *xfeProvider) section(reply interface{}) interface{} {
+	switch r := reply.(type) {
+	case *domain.HashReply:
+		return &r.XFE
+	case *domain.URLReply:
+		return &r.XFE
+	case *domain.IPReply:
+		return &r.XFE
+	case *domain.DomainReply:
+		return &r.XFE
+	}
+	return nil
+}
+
+func (p *xfeProvider) teamKey(request *domain.WorkRequest) string {
+	if request.XFEKey != "" && request.XFEPass != "" {
+		return request.XFEKey
+	}
+	return ""
+}
+
+// client to use for the request - if the team has its own credentials use them
+func (p *xfeProvider) client(request *domain.WorkRequest) *goxforce.Client {
+	if p.teamKey(request) != "" {
+		xfe, err := goxforce.New(
+			goxforce.SetCredentials(request.XFEKey, request.XFEPass),
+			goxforce.SetErrorLog(log.New(conf.LogWriter, "XFE:", log.Lshortfile)))
+		if err == nil {
+			return xfe
+		}
+	}
+	return p.c
+}
+
+func (p *xfeProvider) Hash(request *domain.WorkRequest, hash string, reply *domain.HashReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://exchange.xforce.ibmcloud.com/malware/" + hash}
+	xfeResp, err := p.client(request).MalwareDetails(hash)
+	if err != nil {
+		// Small hack - see if the file was not found
+		if notFound(err) {
+			reply.XFE.NotFound = true
+		} else {
+			reply.XFE.Error = err.Error()
+			res.Error = err.Error()
+		}
+		return res
+	}
+	reply.XFE.Malware = xfeResp.Malware
+	families := append(append([]string{}, xfeResp.Malware.Family...), xfeResp.Malware.Origins.External.Family...)
+	res.Result, res.Score, res.Summary = domain.ResultClean, float64(len(families)), "Family: "+strings.Join(families, ",")
+	if len(families) > 0 {
+		res.Result = domain.ResultDirty
+	}
+	return res
+}
+
+func (p *xfeProvider) URL(request *domain.WorkRequest, url string, reply *domain.URLReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://exchange.xforce.ibmcloud.com/url/" + url}
+	xfe := p.client(request)
+	urlResp, err := xfe.URL(url)
+	if err != nil {
+		// Small hack - see if the URL was not found
+		if notFound(err) {
+			reply.XFE.NotFound = true
+		} else {
+			reply.XFE.Error = err.Error()
+			res.Error = err.Error()
+		}
+	} else {
+		reply.XFE.URLDetails = urlResp.Result
+		res.Result, res.Score = domain.ResultClean, float64(urlResp.Result.Score)
+		res.Summary = fmt.Sprintf("Score: %v, Categories: %s", urlResp.Result.Score, util.JoinMap(urlResp.Result.Cats))
+	}
+	resolve, err := xfe.Resolve(url)
+	if err == nil {
+		reply.XFE.Resolve = *resolve
+	}
+	if request.Online {
+		malware, err := xfe.URLMalware(url)
+		if err == nil {
+			reply.XFE.URLMalware = *malware
+		}
+	}
+	return res
+}
+
+func (p *xfeProvider) IP(request *domain.WorkRequest, ip string, reply *domain.IPReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://exchange.xforce.ibmcloud.com/ip/" + ip}
+	xfe := p.client(request)
+	ipResp, err := xfe.IPR(ip)
+	if err != nil {
+		// Small hack - see if the IP was not found
+		if notFound(err) {
+			reply.XFE.NotFound = true
+		} else {
+			reply.XFE.Error = err.Error()
+			res.Error = err.Error()
+		}
+		return res
+	}
+	reply.XFE.IPReputation = *ipResp
+	res.Result, res.Score = domain.ResultClean, float64(ipResp.Score)
+	res.Summary = fmt.Sprintf("Score: %v, Categories: %s, Geo: %v", ipResp.Score, util.JoinMapInt(ipResp.Cats), util.NilOrUnknown(ipResp.Geo["country"]))
+	if request.Online {
+		hist, err := xfe.IPRHistory(ip)
+		if err == nil {
+			reply.XFE.IPHistory = *hist
+		}
+	}
+	return res
+}
+
+func (p *xfeProvider) Domain(request *domain.WorkRequest, name string, reply *domain.DomainReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://exchange.xforce.ibmcloud.com/url/" + name}
+	xfe := p.client(request)
+	urlResp, err := xfe.URL(name)
+	if err != nil {
+		// Small hack - see if the domain was not found
+		if notFound(err) {
+			reply.XFE.NotFound = true
+		} else {
+			reply.XFE.Error = err.Error()
+			res.Error = err.Error()
+		}
+	} else {
+		reply.XFE.URLDetails = urlResp.Result
+		res.Result, res.Score = domain.ResultClean, float64(urlResp.Result.Score)
+		res.Summary = fmt.Sprintf("Score: %v, Categories: %s", urlResp.Result.Score, util.JoinMap(urlResp.Result.Cats))
+	}
+	resolve, err := xfe.Resolve(name)
+	if err == nil {
+		reply.XFE.Resolve = *resolve
+	}
+	return res
+}
+
+// vtProvider uses VirusTotal
+type vtProvider struct {
+	c *govt.Client
+}
+
+func newVTProvider() (Provider, error) {
+	vt, err := govt.New(
+		govt.SetApikey(conf.Options.VT),
+		govt.SetErrorLog(log.New(conf.LogWriter, "VT:", log.Lshortfile)))
+	if err != nil {
+		return nil, err
+	}
+	return &vtProvider{c: vt}, nil
+}
+
+func (p *vtProvider) Name() string {
+	return "vt"
+}
+
+func (p *vtProvider) section(reply interface{}) interface{} {
+	switch r := reply.(type) {
+	case *domain.HashReply:
+		return &r.VT
+	case *domain.URLReply:
+		return &r.VT
+	case *domain.IPReply:
+		return &r.VT
+	case *domain.DomainReply:
+		return &r.VT
+	}
+	return nil
+}
+
+func (p *vtProvider) teamKey(request *domain.WorkRequest) string {
+	return request.VTKey
+}
+
+// client to use for the request - if the team has its own key use it
+func (p *vtProvider) client(request *domain.WorkRequest) *govt.Client {
+	if request.VTKey != "" {
+		vt, err := govt.New(
+			govt.SetApikey(request.VTKey),
+			govt.SetErrorLog(log.New(conf.LogWriter, "VT:", log.Lshortfile)))
+		if err == nil {
+			return vt
+		}
+	}
+	return p.c
+}
+
+func (p *vtProvider) Hash(request *domain.WorkRequest, hash string, reply *domain.HashReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown}
+	vtResp, err := p.client(request).GetFileReport(hash)
+	if err != nil {
+		reply.VT.Error, res.Error = err.Error(), err.Error()
+		return res
+	}
+	reply.VT.FileReport = *vtResp
+	if vtResp.ResponseCode == 1 {
+		res.Result, res.Score, res.Link = domain.ResultClean, float64(vtResp.Positives), vtResp.Permalink
+		res.Summary = fmt.Sprintf("%v / %v", vtResp.Positives, vtResp.Total)
+	}
+	return res
+}
+
+func (p *vtProvider) URL(request *domain.WorkRequest, url string, reply *domain.URLReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown}
+	vtResp, err := p.client(request).GetUrlReport(url)
+	if err != nil {
+		reply.VT.Error, res.Error = err.Error(), err.Error()
+		return res
+	}
+	reply.VT.URLReport = *vtResp
+	if vtResp.ResponseCode == 1 {
+		res.Result, res.Score, res.Link = domain.ResultClean, float64(vtResp.Positives), vtResp.Permalink
+		res.Summary = fmt.Sprintf("%v / %v", vtResp.Positives, vtResp.Total)
+	}
+	return res
+}
+
+func (p *vtProvider) IP(request *domain.WorkRequest, ip string, reply *domain.IPReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://www.virustotal.com/en/search?query=" + ip}
+	if net.ParseIP(ip).To4() == nil {
+		// VirusTotal has IP reports only for IPv4
+		return res
+	}
+	vtResp, err := p.client(request).GetIpReport(ip)
+	if err != nil {
+		reply.VT.Error, res.Error = err.Error(), err.Error()
+		return res
+	}
+	reply.VT.IPReport = *vtResp
+	if vtResp.ResponseCode == 1 {
+		// The score of the IP is the worst URL detected on it during the last year
+		res.Result, res.Score = domain.ResultClean, float64(maxRecentPositives(vtResp.DetectedUrls))
+		res.Summary = fmt.Sprintf("%v detected URLs", len(vtResp.DetectedUrls))
+	}
+	return res
+}
+
+func (p *vtProvider) Domain(request *domain.WorkRequest, name string, reply *domain.DomainReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://www.virustotal.com/en/domain/" + name + "/information/"}
+	vtResp, err := p.client(request).GetDomainReport(name)
+	if err != nil {
+		reply.VT.Error, res.Error = err.Error(), err.Error()
+		return res
+	}
+	reply.VT.DomainReport = *vtResp
+	if vtResp.ResponseCode == 1 {
+		// Just like IPs, the score of the domain is the worst URL detected on it during the last year
+		res.Result, res.Score = domain.ResultClean, float64(maxRecentPositives(vtResp.DetectedUrls))
+		res.Summary = fmt.Sprintf("%v detected URLs, %v resolutions", len(vtResp.DetectedUrls), len(vtResp.Resolutions))
+	}
+	return res
+}
+
+// maxRecentPositives of the detected URLs scanned during the last year
+func maxRecentPositives(urls []govt.DetectedUrl) uint16 {
+	var positives uint16
+	now := time.Now()
+	for i := range urls {
+		t, err := time.Parse("2006-01-02 15:04:05", urls[i].ScanDate)
+		if err != nil {
+			continue
+		}
+		if urls[i].Positives > positives && t.Add(365*24*time.Hour).After(now) {
+			positives = urls[i].Positives
+		}
+	}
+	return positives
+}
+
+// cyProvider uses Cylance Infinity
+type cyProvider struct {
+	c *infinigo.Client
+}
+
+func newCyProvider() (Provider, error) {
+	cy, err := infinigo.New(
+		infinigo.SetKey(conf.Options.Cy),
+		infinigo.SetErrorLog(log.New(conf.LogWriter, "CY:", log.Lshortfile)))
+	if err != nil {
+		return nil, err
+	}
+	return &cyProvider{c: cy}, nil
+}
+
+func (p *cyProvider) Name() string {
+	return "cy"
+}
+
+func (p *cyProvider) section(reply interface{}) interface{} {
+	if r, ok := reply.(*domain.HashReply); ok {
+		return &r.Cy
+	}
+	return nil
+}
+
+func (p *cyProvider) Hash(request *domain.WorkRequest, hash string, reply *domain.HashReply) domain.ProviderResult {
+	cyResp, err := p.c.Query("", hash)
+	if err != nil {
+		reply.Cy.Error = err.Error()
+		return domain.ProviderResult{Result: domain.ResultUnknown, Error: err.Error()}
+	}
+	// Should be only one
+	for k := range cyResp {
+		reply.Cy.Result = cyResp[k]
+	}
+	return cyResult(reply.Cy.Result)
+}
+
+// cyResult converts the Cylance reply - negative scores are bad so we flip the sign
+func cyResult(r infinigo.QueryResponse) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://www.cylance.com"}
+	if r.StatusCode == 1 {
+		res.Result, res.Score = domain.ResultClean, -float64(r.GeneralScore)
+		res.Summary = fmt.Sprintf("%v - %v", r.GeneralScore, r.Classifiers)
+	}
+	return res
+}
+
+// afProvider uses Palo Alto Networks AutoFocus
+type afProvider struct {
+	c *autofocus.Client
+}
+
+func newAFProvider() (Provider, error) {
+	return &afProvider{c: &autofocus.Client{Token: conf.Options.AF}}, nil
+}
+
+func (p *afProvider) Name() string {
+	return "af"
+}
+
+func (p *afProvider) section(reply interface{}) interface{} {
+	if r, ok := reply.(*domain.HashReply); ok {
+		return &r.AF
+	}
+	return nil
+}
+
+func (p *afProvider) teamKey(request *domain.WorkRequest) string {
+	return request.AFKey
+}
+
+func (p *afProvider) Hash(request *domain.WorkRequest, hash string, reply *domain.HashReply) domain.ProviderResult {
+	res := domain.ProviderResult{Result: domain.ResultUnknown, Link: "https://www.paloaltonetworks.com/products/secure-the-network/autofocus"}
+	af := p.c
+	if request.AFKey != "" {
+		af = &autofocus.Client{Token: request.AFKey}
+	}
+	afResp := af.HashReputation(hash)
+	if afResp == nil {
+		reply.AF.Error = "Sample not found"
+		return res
+	}
+	reply.AF.Result = *afResp
+	res.Summary = afResp.String()
+	if afResp.Malware {
+		res.Result, res.Score = domain.ResultDirty, 1
+	} else if !afResp.Created.IsZero() {
+		res.Result = domain.ResultClean
+	}
+	return res
+}
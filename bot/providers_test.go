@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+)
+
+// fakeProvider answers hash and IP lookups with a fixed result
+type fakeProvider struct {
+	name string
+	res  domain.ProviderResult
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Hash(request *domain.WorkRequest, hash string, reply *domain.HashReply) domain.ProviderResult {
+	return p.res
+}
+
+func (p *fakeProvider) IP(request *domain.WorkRequest, ip string, reply *domain.IPReply) domain.ProviderResult {
+	return p.res
+}
+
+func TestRegisterProvider(t *testing.T) {
+	names := Providers()
+	for _, name := range []string{"af", "cy", "vt", "xfe"} {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			t.Errorf("Expected the built-in provider %s to be registered but got %v", name, names)
+		}
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Errorf("Expected the providers sorted but got %v", names)
+		}
+	}
+	RegisterProvider("fake", func() (Provider, error) { return &fakeProvider{name: "fake"}, nil })
+	defer func() {
+		providersMu.Lock()
+		delete(providerFactories, "fake")
+		providersMu.Unlock()
+	}()
+	panics := func(name string, factory ProviderFactory) (panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+		RegisterProvider(name, factory)
+		return false
+	}
+	if !panics("fake", func() (Provider, error) { return nil, nil }) {
+		t.Error("Expected a panic registering a duplicate name")
+	}
+	if !panics("nil", nil) {
+		t.Error("Expected a panic registering a nil factory")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	conf.Load("", true)
+	xfe := &fakeProvider{name: "xfe", res: domain.ProviderResult{Result: domain.ResultClean, Score: 1}}
+	vt := &fakeProvider{name: "vt", res: domain.ProviderResult{Result: domain.ResultClean, Score: 9}}
+	w := &Worker{providers: []Provider{xfe, vt}, cache: &reputationCache{}}
+	if p := w.enabledProviders(&domain.WorkRequest{Providers: []string{"vt"}}); len(p) != 1 || p[0] != vt {
+		t.Errorf("Expected only vt to be enabled but got %v", p)
+	}
+	if w.provider("cy") != nil || w.provider("xfe") != xfe {
+		t.Error("Unexpected provider lookup by name")
+	}
+	results := w.lookup(&domain.WorkRequest{}, w.providers, "1.2.3.4", &domain.IPReply{})
+	if len(results) != 2 || results["xfe"].Score != 1 || results["vt"].Score != 9 {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	if results = w.lookup(&domain.WorkRequest{}, w.providers, "evil.com", &domain.DomainReply{}); len(results) != 0 {
+		t.Errorf("Expected no results from providers without domain support but got %+v", results)
+	}
+}
+
+func TestIPVerdict(t *testing.T) {
+	conf.Load("", true)
+	policy := policyFor(&domain.WorkRequest{}, domain.ReplyTypeIP)
+	tests := []struct {
+		xfe, vt domain.ProviderResult
+		result  int
+		rule    string
+	}{
+		// VT convicts only addresses XFE does not know
+		{domain.ProviderResult{Result: domain.ResultClean, Score: 1}, domain.ProviderResult{Result: domain.ResultClean, Score: 9}, domain.ResultClean, "any-clean"},
+		{domain.ProviderResult{Result: domain.ResultUnknown}, domain.ProviderResult{Result: domain.ResultClean, Score: 9}, domain.ResultDirty, "vt-dirty"},
+		{domain.ProviderResult{Result: domain.ResultUnknown, Error: "failed"}, domain.ProviderResult{Result: domain.ResultClean, Score: 9}, domain.ResultUnknown, ""},
+		{domain.ProviderResult{Result: domain.ResultClean, Score: 7}, domain.ProviderResult{Result: domain.ResultClean, Score: 0}, domain.ResultDirty, "xfe-dirty"},
+		{domain.ProviderResult{Result: domain.ResultUnknown}, domain.ProviderResult{Result: domain.ResultClean, Score: 2}, domain.ResultClean, "any-clean"},
+	}
+	for i, test := range tests {
+		results := map[string]domain.ProviderResult{"xfe": test.xfe, "vt": test.vt}
+		if res, rule := verdict(policy, results); res != test.result || rule != test.rule {
+			t.Errorf("%d: expected %v by %s but got %v by %s", i, test.result, test.rule, res, rule)
+		}
+	}
+}
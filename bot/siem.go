@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/cef"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+)
+
+// verdictSeverities are the CEF severities of the results
+var verdictSeverities = map[int]int{domain.ResultClean: 1, domain.ResultUnknown: 3, domain.ResultDirty: 8}
+
+// verdictNames of the results in the CEF events
+var verdictNames = map[int]string{domain.ResultClean: "clean", domain.ResultUnknown: "unknown", domain.ResultDirty: "dirty"}
+
+// verdictTitles of the results in the CEF event names
+var verdictTitles = map[int]string{domain.ResultClean: "Clean", domain.ResultUnknown: "Unknown", domain.ResultDirty: "Malicious"}
+
+// newSIEM forwarder if syslog is configured
+func newSIEM() (*cef.Forwarder, error) {
+	if conf.Options.Syslog.Address == "" {
+		return nil, nil
+	}
+	var tlsConfig *tls.Config
+	if conf.Options.Syslog.Network == "tls" {
+		host, _, err := net.SplitHostPort(conf.Options.Syslog.Address)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig = &tls.Config{ServerName: host}
+		if conf.Options.Syslog.ServerCA != "" {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(conf.Options.Syslog.ServerCA)) {
+				return nil, errors.New("Unable to add syslog ServerCA PEM")
+			}
+		}
+	}
+	return cef.NewForwarder(conf.Options.Syslog.Network, conf.Options.Syslog.Address, tlsConfig)
+}
+
+// providerScores of the indicator as provider: summary pairs sorted by provider
+func providerScores(providers map[string]domain.ProviderResult) string {
+	var scores []string
+	for name, res := range providers {
+		if res.Summary != "" {
+			scores = append(scores, name+": "+res.Summary)
+		}
+	}
+	sort.Strings(scores)
+	return strings.Join(scores, "; ")
+}
+
+// verdictEvent for a single indicator of the reply
+func verdictEvent(reply *domain.WorkReply, ctx *domain.Context, sub *subscription, indicatorType, indicator string, result int, rule, scores string) *cef.Event {
+	e := &cef.Event{
+		SignatureID: indicatorType + "-" + verdictNames[result],
+		Name:        verdictTitles[result] + " " + indicatorType,
+		Severity:    verdictSeverities[result],
+	}
+	e.Add("rt", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+	e.Add("externalId", reply.MessageID)
+	e.Add("cs1Label", "team")
+	e.Add("cs1", sub.team.ExternalID)
+	e.Add("cs2Label", "channel")
+	e.Add("cs2", ctx.Channel)
+	e.Add("suser", ctx.User)
+	e.Add("cs3Label", "indicatorType")
+	e.Add("cs3", indicatorType)
+	e.Add("cs4Label", "providerScores")
+	e.Add("cs4", scores)
+	e.Add("cs5Label", "rule")
+	e.Add("cs5", rule)
+	e.Add("cs6Label", "indicator")
+	e.Add("cs6", indicator)
+	e.Add("outcome", verdictNames[result])
+	return e
+}
+
+// verdictEvents of every indicator in the reply with at least the given severity
+func verdictEvents(reply *domain.WorkReply, ctx *domain.Context, sub *subscription, minSeverity int) []*cef.Event {
+	var events []*cef.Event
+	add := func(e *cef.Event) {
+		if e.Severity >= minSeverity {
+			events = append(events, e)
+		}
+	}
+	if reply.Type&domain.ReplyTypeFile > 0 {
+		hash, scores := "", ""
+		if len(reply.Hashes) > 0 {
+			hash, scores = reply.Hashes[0].Details, providerScores(reply.Hashes[0].Providers)
+		}
+		if reply.File.Virus != "" {
+			scores = strings.TrimPrefix(scores+"; clamav: "+reply.File.Virus, "; ")
+		}
+		e := verdictEvent(reply, ctx, sub, "file", reply.File.Details.Name, reply.File.Result, reply.File.Rule, scores)
+		e.Add("fname", reply.File.Details.Name)
+		e.Add("fileHash", hash)
+		add(e)
+		return events
+	}
+	for i := range reply.Hashes {
+		h := &reply.Hashes[i]
+		e := verdictEvent(reply, ctx, sub, "hash", h.Details, h.Result, h.Rule, providerScores(h.Providers))
+		e.Add("fileHash", h.Details)
+		add(e)
+	}
+	for i := range reply.URLs {
+		u := &reply.URLs[i]
+		e := verdictEvent(reply, ctx, sub, "url", u.Details, u.Result, u.Rule, providerScores(u.Providers))
+		e.Add("request", u.Details)
+		add(e)
+	}
+	for i := range reply.Domains {
+		d := &reply.Domains[i]
+		e := verdictEvent(reply, ctx, sub, "domain", d.Details, d.Result, d.Rule, providerScores(d.Providers))
+		e.Add("dhost", d.Details)
+		add(e)
+	}
+	for i := range reply.IPs {
+		ip := &reply.IPs[i]
+		e := verdictEvent(reply, ctx, sub, "ip", ip.Details, ip.Result, ip.Rule, providerScores(ip.Providers))
+		if parsed := net.ParseIP(ip.Details); parsed != nil && parsed.To4() != nil {
+			e.Add("dst", ip.Details)
+		}
+		add(e)
+	}
+	return events
+}
+
+// forwardVerdicts of the reply to the SIEM
+func (b *Bot) forwardVerdicts(reply *domain.WorkReply, ctx *domain.Context, sub *subscription) {
+	if b.siem == nil {
+		return
+	}
+	for _, e := range verdictEvents(reply, ctx, sub, conf.Options.Syslog.MinSeverity) {
+		if !b.siem.Send(e) {
+			logrus.Warnf("Syslog buffer is full - dropping %s event for team [%s]", e.SignatureID, sub.team.ID)
+		}
+	}
+}
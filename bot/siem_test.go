@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/demisto/alfred/domain"
+)
+
+func TestVerdictEvents(t *testing.T) {
+	sub := &subscription{team: &domain.Team{ID: "t1", ExternalID: "T1"}}
+	ctx := &domain.Context{Team: "T1", User: "U1", Channel: "C1"}
+	reply := &domain.WorkReply{
+		Type:      domain.ReplyTypeURL | domain.ReplyTypeIP,
+		MessageID: "m1",
+		URLs: []domain.URLReply{{Details: "http://evil.com/a", Result: domain.ResultDirty, Rule: "any-dirty", Providers: map[string]domain.ProviderResult{
+			"xfe": {Summary: "Score 7"}, "vt": {Summary: "5 / 60"},
+		}}},
+		IPs: []domain.IPReply{{Details: "8.8.8.8", Result: domain.ResultClean}},
+	}
+	events := verdictEvents(reply, ctx, sub, 0)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events but got %d", len(events))
+	}
+	s := events[0].String()
+	for _, part := range []string{"|url-dirty|Malicious url|8|", "cs1=T1", "cs2=C1", "suser=U1", "cs4=vt: 5 / 60; xfe: Score 7", "cs5=any-dirty", "request=http://evil.com/a"} {
+		if !strings.Contains(s, part) {
+			t.Errorf("Expected %s in %s", part, s)
+		}
+	}
+	if s = events[1].String(); !strings.Contains(s, "|ip-clean|Clean ip|1|") || !strings.Contains(s, "dst=8.8.8.8") {
+		t.Errorf("Unexpected IP event %s", s)
+	}
+	// Clean verdicts are below the minimum severity
+	if events = verdictEvents(reply, ctx, sub, 5); len(events) != 1 || events[0].SignatureID != "url-dirty" {
+		t.Errorf("Expected only the malicious URL but got %+v", events)
+	}
+}
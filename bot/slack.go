@@ -1,73 +1,22 @@
 package bot
 
 import (
+	"encoding/json"
 	"fmt"
-	"net/url"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/demisto/alfred/conf"
 	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+	"github.com/demisto/alfred/render"
+	"github.com/demisto/alfred/repo"
 	"github.com/demisto/alfred/slack"
 	"github.com/demisto/alfred/util"
-	"github.com/slavikm/govt"
 )
 
-const (
-	fileCommentGood    = "File (%s) is clean. Click %s for more details."
-	fileCommentBig     = "File (%s) is too large to scan. Click %s for more details."
-	fileCommentBad     = "Warning: File (%s) is malicious. Click %s for more details."
-	fileCommentWarning = "Unable to find details regarding this file (%s). Click %s for more details."
-	urlCommentGood     = "URL (%s) is clean: %s."
-	urlCommentBad      = "Warning: URL (%s) is malicious: %s."
-	urlCommentWarning  = "Unable to find details regarding this URL (%s): %s."
-	ipCommentGood      = "IP (%s) is clean: %s."
-	ipCommentBad       = "Warning: IP (%s) is malicious: %s."
-	ipCommentWarning   = "Unable to find details regarding this IP (%s): %s."
-	ipCommentPrivate   = "IP (%s) is a private (internal) IP so we cannot provide reputation information: %s."
-	hashCommentGood    = "Hash (%s) is clean: %s."
-	hashCommentBad     = "Warning: hash (%s) is malicious: %s."
-	hashCommentWarning = "Unable to find details regarding this hash (%s): %s."
-	mainMessage        = "Security check by DBot - Demisto Bot. Click <%s|here> for configuration and details."
-)
-
-func joinMap(m map[string]bool) string {
-	res := ""
-	for k, v := range m {
-		if v {
-			res += k + ","
-		}
-	}
-	if len(res) > 0 {
-		return res[0 : len(res)-1]
-	}
-	return res
-}
-
-func joinMapInt(m map[string]int) string {
-	res := ""
-	for k, v := range m {
-		res += fmt.Sprintf("%s (%d),", k, v)
-	}
-	if len(res) > 0 {
-		return res[0 : len(res)-1]
-	}
-	return res
-}
-
-func joinMapFloat32(m map[string]float32) string {
-	res := ""
-	for k, v := range m {
-		res += fmt.Sprintf("%s: %v,", k, v)
-	}
-	if len(res) > 0 {
-		return res[0 : len(res)-1]
-	}
-	return res
-}
+const mainMessage = "Security check by DBot - Demisto Bot. Click <%s|here> for configuration and details."
 
 func mainMessageFormatted() string {
 	return fmt.Sprintf(mainMessage, conf.Options.ExternalAddress)
@@ -80,97 +29,15 @@ func (b *Bot) handleFileReply(reply *domain.WorkReply, data *domain.Context, sub
 		return
 	}
 	link := fmt.Sprintf("%s/details?f=%s&t=%s", conf.Options.ExternalAddress, reply.File.Details.ID, sub.team.ID)
-	color := "warning"
-	comment := fileCommentWarning
-	shouldPost := false
-	if reply.File.FileTooLarge {
-		comment = fileCommentBig
-		shouldPost = true
-	} else if reply.File.Result == domain.ResultDirty {
-		color = "danger"
-		comment = fileCommentBad
-	} else if reply.File.Result == domain.ResultClean {
-		// At least one of reputation services found this to be known good
-		// Keep the default
-		color = "good"
-		comment = fileCommentGood
-	}
-	fileMessage := fmt.Sprintf(comment, reply.File.Details.Name, fmt.Sprintf("<%s&text=%s|Details>", link, url.QueryEscape(reply.Hashes[0].Details)))
-	attachments := []map[string]interface{}{{"fallback": fileMessage, "text": fileMessage, "color": color}}
-	postMessage := map[string]interface{}{"channel": data.Channel}
-	if data.Channel != "" {
-		if reply.Hashes[0].Cy.Error == "" && reply.Hashes[0].Cy.Result.StatusCode == 1 {
-			cyColor := "good"
-			if reply.Hashes[0].Cy.Result.GeneralScore < cyScoreToConvict {
-				cyColor = "danger"
-			}
-			attachments = append(attachments, map[string]interface{}{
-				"fallback":   fmt.Sprintf("Score: %v, Classifiers: %v", reply.Hashes[0].Cy.Result.GeneralScore, reply.Hashes[0].Cy.Result.Classifiers),
-				"color":      cyColor,
-				"title":      "Cylance Infinity",
-				"title_link": "https://www.cylance.com",
-				"fields": []map[string]interface{}{
-					{"title": "Score", "value": fmt.Sprintf("%v", reply.Hashes[0].Cy.Result.GeneralScore), "short": true},
-					{"title": "Classifiers", "value": joinMapFloat32(reply.Hashes[0].Cy.Result.Classifiers), "short": true},
-				},
-			})
-		}
-		if !reply.Hashes[0].XFE.NotFound && reply.Hashes[0].XFE.Error == "" {
-			xfeColor := "good"
-			if len(reply.Hashes[0].XFE.Malware.Family) > 0 || len(reply.Hashes[0].XFE.Malware.Origins.External.Family) > 0 {
-				xfeColor = "danger"
-			}
-			attachments = append(attachments, map[string]interface{}{
-				"fallback":   fmt.Sprintf("Mime Type: %s, Family: %s", reply.Hashes[0].XFE.Malware.MimeType, strings.Join(reply.Hashes[0].XFE.Malware.Family, ",")),
-				"color":      xfeColor,
-				"title":      "IBM X-Force Exchange",
-				"title_link": fmt.Sprintf("https://exchange.xforce.ibmcloud.com/malware/%s", reply.Hashes[0].Details),
-				"fields": []map[string]interface{}{
-					{"title": "Family", "value": strings.Join(reply.Hashes[0].XFE.Malware.Family, ","), "short": true},
-					{"title": "MIME Type", "value": reply.Hashes[0].XFE.Malware.MimeType, "short": true},
-					{"title": "Created", "value": reply.Hashes[0].XFE.Malware.Created.String(), "short": true},
-				},
-			})
-		}
-		if reply.Hashes[0].VT.FileReport.ResponseCode == 1 {
-			vtColor := "good"
-			if reply.Hashes[0].VT.FileReport.Positives >= numOfPositivesToConvictForFiles {
-				vtColor = "danger"
-			}
-			attachments = append(attachments, map[string]interface{}{
-				"fallback":   fmt.Sprintf("Scan Date: %s, Positives: %v, Total: %v", reply.Hashes[0].VT.FileReport.ScanDate, reply.Hashes[0].VT.FileReport.Positives, reply.Hashes[0].VT.FileReport.Total),
-				"color":      vtColor,
-				"title":      "VirusTotal",
-				"title_link": reply.Hashes[0].VT.FileReport.Permalink,
-				"fields": []map[string]interface{}{
-					{"title": "Scan Date", "value": reply.Hashes[0].VT.FileReport.ScanDate, "short": true},
-					{"title": "Positives", "value": fmt.Sprintf("%v", reply.Hashes[0].VT.FileReport.Positives), "short": true},
-					{"title": "Total", "value": fmt.Sprintf("%v", reply.Hashes[0].VT.FileReport.Total), "short": true},
-				},
-			})
-		}
-		if reply.File.Virus != "" {
-			attachments = append(attachments, map[string]interface{}{
-				"fallback":    fmt.Sprintf("Virus name: %s", reply.File.Virus),
-				"text":        fmt.Sprintf("Virus name: %s", reply.File.Virus),
-				"color":       "danger",
-				"author_name": "ClamAV",
-				"title":       "ClamAV",
-			})
-		}
-		if verbose {
-			shouldPost = true
-		} else if reply.File.Result == domain.ResultDirty {
-			shouldPost = true
-		}
-	}
+	shouldPost := reply.File.FileTooLarge || data.Channel != "" && (verbose || reply.File.Result == domain.ResultDirty)
 	if shouldPost {
-		postMessage["attachments"] = attachments
-		err := b.post(postMessage, reply, data, sub)
+		err := b.post(render.File(reply, link), reply, data, sub)
 		if err != nil {
 			logrus.Errorf("Unable to send message to Slack - %v\n", err)
 			return
 		}
+	} else {
+		b.removeReply(data.Team, data.Channel, reply.MessageID, sub)
 	}
 }
 
@@ -219,7 +86,34 @@ func (b *Bot) handleReplyStats(reply *domain.WorkReply, sub *subscription) {
 				stats.IPsUnknown++
 			}
 		}
+		for i := range reply.Domains {
+			if reply.Domains[i].Result == domain.ResultClean {
+				stats.DomainsClean++
+			} else if reply.Domains[i].Result == domain.ResultDirty {
+				stats.DomainsDirty++
+			} else {
+				stats.DomainsUnknown++
+			}
+		}
+	}
+}
+
+// intelSummary of the team intel that convicted the indicator if any
+func intelSummary(providers map[string]domain.ProviderResult) string {
+	if res, ok := providers[domain.ProviderTeamIntel]; ok {
+		return res.Summary
+	}
+	return providers[domain.ProviderSTIX].Summary
+}
+
+// storeConvicted content together with its event for the webhooks of the team
+func (b *Bot) storeConvicted(sub *subscription, convicted *domain.MaliciousContent) error {
+	convicted.Timestamp = time.Now()
+	event, err := json.Marshal(&domain.ConvictedEvent{Event: domain.EventConvicted, TeamID: sub.team.ExternalID, Convicted: convicted})
+	if err != nil {
+		return err
 	}
+	return b.r.StoreConvicted(convicted, event)
 }
 
 func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub *subscription) {
@@ -232,7 +126,7 @@ func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub
 		vtScore := fmt.Sprintf("%v / %v", reply.Hashes[0].VT.FileReport.Positives, reply.Hashes[0].VT.FileReport.Total)
 		xfeScore := strings.Join(reply.Hashes[0].XFE.Malware.Family, ",")
 		cyScore := fmt.Sprintf("%v - %v", reply.Hashes[0].Cy.Result.GeneralScore, reply.Hashes[0].Cy.Result.Classifiers)
-		if err := b.r.StoreMaliciousContent(&domain.MaliciousContent{
+		if err := b.storeConvicted(sub, &domain.MaliciousContent{
 			Team:        sub.team.ID,
 			Channel:     ctx.Channel,
 			MessageID:   reply.File.Details.ID,
@@ -242,7 +136,8 @@ func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub
 			VT:          vtScore,
 			XFE:         xfeScore,
 			Cy:          cyScore,
-			ClamAV:      reply.File.Virus}); err != nil {
+			ClamAV:      reply.File.Virus,
+			Intel:       intelSummary(reply.Hashes[0].Providers)}); err != nil {
 			logrus.WithError(err).Warnf("Unable to store convicted for team [%s]", sub.team.ID)
 		}
 	} else {
@@ -252,7 +147,7 @@ func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub
 				xfeScore := strings.Join(reply.Hashes[i].XFE.Malware.Family, ",")
 				cyScore := fmt.Sprintf("%v - %v", reply.Hashes[i].Cy.Result.GeneralScore, reply.Hashes[i].Cy.Result.Classifiers)
 				afScore := fmt.Sprintf("%s", reply.Hashes[i].AF.Result.String())
-				if err := b.r.StoreMaliciousContent(&domain.MaliciousContent{
+				if err := b.storeConvicted(sub, &domain.MaliciousContent{
 					Team:        sub.team.ID,
 					Channel:     ctx.Channel,
 					MessageID:   reply.MessageID,
@@ -261,7 +156,8 @@ func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub
 					VT:          vtScore,
 					XFE:         xfeScore,
 					Cy:          cyScore,
-					AF:          afScore}); err != nil {
+					AF:          afScore,
+					Intel:       intelSummary(reply.Hashes[i].Providers)}); err != nil {
 					logrus.WithError(err).Warnf("Unable to store convicted for team [%s]", sub.team.ID)
 				}
 			}
@@ -270,14 +166,32 @@ func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub
 			if reply.URLs[i].Result == domain.ResultDirty {
 				vtScore := fmt.Sprintf("%v / %v", reply.URLs[i].VT.URLReport.Positives, reply.URLs[i].VT.URLReport.Total)
 				xfeScore := fmt.Sprintf("%v", reply.URLs[i].XFE.URLDetails.Score)
-				if err := b.r.StoreMaliciousContent(&domain.MaliciousContent{
+				if err := b.storeConvicted(sub, &domain.MaliciousContent{
 					Team:        sub.team.ID,
 					Channel:     ctx.Channel,
 					MessageID:   reply.MessageID,
 					ContentType: domain.ReplyTypeURL,
 					Content:     reply.URLs[i].Details,
 					VT:          vtScore,
-					XFE:         xfeScore}); err != nil {
+					XFE:         xfeScore,
+					Intel:       intelSummary(reply.URLs[i].Providers)}); err != nil {
+					logrus.WithError(err).Warnf("Unable to store convicted for team [%s]", sub.team.ID)
+				}
+			}
+		}
+		for i := range reply.Domains {
+			if reply.Domains[i].Result == domain.ResultDirty {
+				vtScore := fmt.Sprintf("%v", len(reply.Domains[i].VT.DomainReport.DetectedUrls))
+				xfeScore := fmt.Sprintf("%v", reply.Domains[i].XFE.URLDetails.Score)
+				if err := b.storeConvicted(sub, &domain.MaliciousContent{
+					Team:        sub.team.ID,
+					Channel:     ctx.Channel,
+					MessageID:   reply.MessageID,
+					ContentType: domain.ReplyTypeDomain,
+					Content:     reply.Domains[i].Details,
+					VT:          vtScore,
+					XFE:         xfeScore,
+					Intel:       intelSummary(reply.Domains[i].Providers)}); err != nil {
 					logrus.WithError(err).Warnf("Unable to store convicted for team [%s]", sub.team.ID)
 				}
 			}
@@ -286,14 +200,15 @@ func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub
 			if reply.IPs[i].Result == domain.ResultDirty {
 				vtScore := fmt.Sprintf("%v", len(reply.IPs[i].VT.IPReport.DetectedUrls))
 				xfeScore := fmt.Sprintf("%v", reply.IPs[i].XFE.IPReputation.Score)
-				if err := b.r.StoreMaliciousContent(&domain.MaliciousContent{
+				if err := b.storeConvicted(sub, &domain.MaliciousContent{
 					Team:        sub.team.ID,
 					Channel:     ctx.Channel,
 					MessageID:   reply.MessageID,
 					ContentType: domain.ReplyTypeIP,
 					Content:     reply.IPs[i].Details,
 					VT:          vtScore,
-					XFE:         xfeScore}); err != nil {
+					XFE:         xfeScore,
+					Intel:       intelSummary(reply.IPs[i].Providers)}); err != nil {
 					logrus.WithError(err).Warnf("Unable to store convicted for team [%s]", sub.team.ID)
 				}
 			}
@@ -301,30 +216,12 @@ func (b *Bot) handleConvicted(reply *domain.WorkReply, ctx *domain.Context, sub
 	}
 }
 
-// IPByDate sorting
-type IPByDate []govt.DetectedUrl
-
-func (a IPByDate) Len() int           { return len(a) }
-func (a IPByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a IPByDate) Less(i, j int) bool { return a[i].ScanDate < a[j].ScanDate }
-
 func (b *Bot) relevantTeam(team string) *subscription {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 	return b.subscriptions[team]
 }
 
-func nilOrUnknown(v interface{}) string {
-	if v == nil {
-		return "Unknown"
-	}
-	return fmt.Sprintf("%v", v)
-}
-
-func defangURL(u string) string {
-	return strings.Replace(strings.Replace(strings.Replace(u, "https://", "https[://]", 1), "http://", "http[://]", 1), ".", "[.]", -1)
-}
-
 func (b *Bot) handleReply(reply *domain.WorkReply) {
 	logrus.Debugf("Handling reply - %s", reply.MessageID)
 	data, err := domain.GetContext(reply.Context)
@@ -341,6 +238,7 @@ func (b *Bot) handleReply(reply *domain.WorkReply) {
 	}
 	b.handleReplyStats(reply, sub)
 	b.handleConvicted(reply, data, sub)
+	b.forwardVerdicts(reply, data, sub)
 	verbose := false
 	if data.Channel != "" {
 		if data.Channel[0] == 'D' {
@@ -350,268 +248,103 @@ func (b *Bot) handleReply(reply *domain.WorkReply) {
 			verbose = sub.configuration.IsVerbose(data.Channel)
 		}
 	}
-	if reply.Type&domain.ReplyTypeFile > 0 {
+	// Someone clicked a button and is waiting for the full answer
+	if data.Action != "" {
+		verbose = true
+	}
+	if data.ResponseURL != "" {
+		b.respond(reply, data, sub)
+	} else if reply.Type&domain.ReplyTypeFile > 0 {
 		b.handleFileReply(reply, data, sub, verbose)
 	} else {
 		link := fmt.Sprintf("%s/details?c=%s&m=%s&t=%s", conf.Options.ExternalAddress, data.Channel, reply.MessageID, sub.team.ID)
-		postMessage := util.Object{"channel": data.Channel}
-		attachments := make([]map[string]interface{}, 0)
-		for i := range reply.URLs {
-			color := "warning"
-			comment := urlCommentWarning
-			if reply.URLs[i].Result == domain.ResultDirty {
-				color = "danger"
-				comment = urlCommentBad
-			} else if reply.URLs[i].Result == domain.ResultClean {
-				color = "good"
-				comment = urlCommentGood
-			}
-			urlMessage := fmt.Sprintf(comment, defangURL(reply.URLs[i].Details), fmt.Sprintf("<%s&text=%s|Details>", link, url.QueryEscape("<"+reply.URLs[i].Details+">")))
-			if verbose || color != "good" {
-				attachments = append(attachments, map[string]interface{}{
-					"fallback": urlMessage,
-					"text":     urlMessage,
-					"color":    color,
-				})
-			}
-			if verbose {
-				if !reply.URLs[i].XFE.NotFound && reply.URLs[i].XFE.Error == "" {
-					xfeColor := "good"
-					if reply.URLs[i].XFE.URLDetails.Score >= xfeScoreToConvict {
-						xfeColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback": fmt.Sprintf("Score: %v, A Records: %s, Categories: %s",
-							reply.URLs[i].XFE.URLDetails.Score,
-							strings.Join(reply.URLs[i].XFE.Resolve.A, ","),
-							joinMap(reply.URLs[i].XFE.URLDetails.Cats)),
-						"color":      xfeColor,
-						"title":      "IBM X-Force Exchange",
-						"title_link": fmt.Sprintf("https://exchange.xforce.ibmcloud.com/url/%s", reply.URLs[i].Details),
-						"fields": []map[string]interface{}{
-							{"title": "Score", "value": fmt.Sprintf("%v", reply.URLs[i].XFE.URLDetails.Score), "short": true},
-							{"title": "A Records", "value": strings.Join(reply.URLs[i].XFE.Resolve.A, ","), "short": true},
-							{"title": "Categories", "value": joinMap(reply.URLs[i].XFE.URLDetails.Cats), "short": true},
-						},
-					})
-					if len(reply.URLs[i].XFE.Resolve.AAAA) > 0 {
-						attachments[len(attachments)-1]["fields"] = append(attachments[len(attachments)-1]["fields"].([]map[string]interface{}),
-							map[string]interface{}{"title": "A Records", "value": strings.Join(reply.URLs[i].XFE.Resolve.AAAA, ","), "short": true})
-					}
-				}
-				if reply.URLs[i].VT.URLReport.ResponseCode == 1 {
-					vtColor := "good"
-					if reply.URLs[i].VT.URLReport.Positives >= numOfPositivesToConvict {
-						vtColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback":   fmt.Sprintf("Scan Date: %s, Positives: %v, Total: %v", reply.URLs[i].VT.URLReport.ScanDate, reply.URLs[i].VT.URLReport.Positives, reply.URLs[i].VT.URLReport.Total),
-						"color":      vtColor,
-						"title":      "VirusTotal",
-						"title_link": reply.URLs[i].VT.URLReport.Permalink,
-						"fields": []map[string]interface{}{
-							{"title": "Scan Date", "value": reply.URLs[i].VT.URLReport.ScanDate, "short": true},
-							{"title": "Positives", "value": fmt.Sprintf("%v", reply.URLs[i].VT.URLReport.Positives), "short": true},
-							{"title": "Total", "value": fmt.Sprintf("%v", reply.URLs[i].VT.URLReport.Total), "short": true},
-						},
-					})
-				}
-			}
-		}
-		for i := range reply.IPs {
-			color := "warning"
-			comment := ipCommentWarning
-			if reply.IPs[i].Private {
-				color = "good"
-				comment = ipCommentPrivate
-			} else if reply.IPs[i].Result == domain.ResultDirty {
-				color = "danger"
-				comment = ipCommentBad
-			} else if reply.IPs[i].Result == domain.ResultClean {
-				color = "good"
-				comment = ipCommentGood
-			}
-			ipMessage := fmt.Sprintf(comment, reply.IPs[i].Details, fmt.Sprintf("<%s&text=%s|Details>", link, url.QueryEscape(reply.IPs[i].Details)))
-			if verbose || color != "good" {
-				attachments = append(attachments, map[string]interface{}{
-					"fallback": ipMessage,
-					"text":     ipMessage,
-					"color":    color,
-				})
-			}
-			if verbose {
-				if !reply.IPs[i].XFE.NotFound && reply.IPs[i].XFE.Error == "" {
-					xfeColor := "good"
-					if reply.IPs[i].XFE.IPReputation.Score >= xfeScoreToConvict {
-						xfeColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback": fmt.Sprintf("Score: %v, Categories: %s, Geo: %v",
-							reply.IPs[i].XFE.IPReputation.Score, joinMapInt(reply.IPs[i].XFE.IPReputation.Cats), nilOrUnknown(reply.IPs[i].XFE.IPReputation.Geo["country"])),
-						"color":      xfeColor,
-						"title":      "IBM X-Force Exchange",
-						"title_link": fmt.Sprintf("https://exchange.xforce.ibmcloud.com/ip/%s", reply.IPs[i].Details),
-						"fields": []map[string]interface{}{
-							{"title": "Score", "value": fmt.Sprintf("%v", reply.IPs[i].XFE.IPReputation.Score), "short": true},
-							{"title": "Categories", "value": joinMapInt(reply.IPs[i].XFE.IPReputation.Cats), "short": true},
-							{"title": "Geo", "value": nilOrUnknown(reply.IPs[i].XFE.IPReputation.Geo["country"]), "short": true},
-						},
-					})
-				}
-				if reply.IPs[i].VT.IPReport.ResponseCode == 1 {
-					var vtPositives uint16
-					listOfURLs := ""
-					now := time.Now()
-					detectedURLs := reply.IPs[i].VT.IPReport.DetectedUrls
-					sort.Sort(sort.Reverse(IPByDate(detectedURLs)))
-					for j := range detectedURLs {
-						t, err := time.Parse("2006-01-02 15:04:05", detectedURLs[j].ScanDate)
-						if err != nil {
-							logrus.Debugf("Error parsing scan date - %v", err)
-							continue
-						}
-						if detectedURLs[j].Positives > vtPositives && t.Add(365*24*time.Hour).After(now) {
-							vtPositives = detectedURLs[j].Positives
-						}
-						if j < 20 {
-							listOfURLs += fmt.Sprintf("URL: %s, Positives: %v, Total: %v, Date: %s", defangURL(detectedURLs[j].Url), detectedURLs[j].Positives, detectedURLs[j].Total, detectedURLs[j].ScanDate) + "\n"
-						}
-					}
-					vtColor := "good"
-					if vtPositives >= numOfPositivesToConvict {
-						vtColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback":   listOfURLs,
-						"text":       listOfURLs,
-						"color":      vtColor,
-						"title":      "VirusTotal",
-						"title_link": "https://www.virustotal.com/en/search?query=" + reply.IPs[i].Details,
-					})
-				}
-			}
-		}
-		// We will handle hashes only for verbose channels
-		if verbose {
-			for i := range reply.Hashes {
-				color := "warning"
-				comment := hashCommentWarning
-				if reply.Hashes[i].Result == domain.ResultDirty {
-					color = "danger"
-					comment = hashCommentBad
-				} else if reply.Hashes[i].Result == domain.ResultClean {
-					color = "good"
-					comment = hashCommentGood
-				}
-				hashMessage := fmt.Sprintf(comment, reply.Hashes[i].Details, fmt.Sprintf("<%s&text=%s|Details>", link, url.QueryEscape(reply.Hashes[i].Details)))
-				attachments = append(attachments, map[string]interface{}{
-					"fallback": hashMessage,
-					"text":     hashMessage,
-					"color":    color,
-				})
-				if reply.Hashes[i].AF.Error == "" {
-					afColor := "good"
-					if reply.Hashes[0].AF.Result.Malware {
-						afColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback":   reply.Hashes[i].AF.Result.String(),
-						"color":      afColor,
-						"title":      "Palo Alto Networks AutoFocus",
-						"title_link": "https://www.paloaltonetworks.com/products/secure-the-network/autofocus",
-						"fields": []map[string]interface{}{
-							{"title": "Malware", "value": strconv.FormatBool(reply.Hashes[i].AF.Result.Malware)},
-							{"title": "Created", "value": fmt.Sprintf("%v", reply.Hashes[i].AF.Result.Created)},
-							{"title": "Tags", "value": strings.Join(reply.Hashes[i].AF.Result.Tags, ", ")},
-							{"title": "Tag Groups", "value": strings.Join(reply.Hashes[i].AF.Result.TagGroups, ", ")},
-							{"title": "Regions", "value": strings.Join(reply.Hashes[i].AF.Result.Regions, ", ")},
-							{"title": "File Type", "value": reply.Hashes[i].AF.Result.FileType},
-						},
-					})
-				}
-				if reply.Hashes[i].Cy.Error == "" && reply.Hashes[0].Cy.Result.StatusCode == 1 {
-					cyColor := "good"
-					if reply.Hashes[i].Cy.Result.GeneralScore < cyScoreToConvict {
-						cyColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback":   fmt.Sprintf("Score: %v, Classifiers: %v", reply.Hashes[i].Cy.Result.GeneralScore, reply.Hashes[0].Cy.Result.Classifiers),
-						"color":      cyColor,
-						"title":      "Cylance Infinity",
-						"title_link": "https://www.cylance.com",
-						"fields": []map[string]interface{}{
-							{"title": "Score", "value": fmt.Sprintf("%v", reply.Hashes[i].Cy.Result.GeneralScore), "short": true},
-							{"title": "Classifiers", "value": joinMapFloat32(reply.Hashes[i].Cy.Result.Classifiers), "short": true},
-						},
-					})
-				}
-				if !reply.Hashes[i].XFE.NotFound && reply.Hashes[i].XFE.Error == "" {
-					xfeColor := "good"
-					if len(reply.Hashes[i].XFE.Malware.Family) > 0 || len(reply.Hashes[i].XFE.Malware.Origins.External.Family) > 0 {
-						xfeColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback":   fmt.Sprintf("Mime Type: %s, Family: %s", reply.Hashes[i].XFE.Malware.MimeType, strings.Join(reply.Hashes[i].XFE.Malware.Family, ",")),
-						"color":      xfeColor,
-						"title":      "IBM X-Force Exchange",
-						"title_link": fmt.Sprintf("https://exchange.xforce.ibmcloud.com/malware/%s", reply.Hashes[i].Details),
-						"fields": []map[string]interface{}{
-							{"title": "Family", "value": strings.Join(reply.Hashes[i].XFE.Malware.Family, ","), "short": true},
-							{"title": "MIME Type", "value": reply.Hashes[i].XFE.Malware.MimeType, "short": true},
-							{"title": "Created", "value": reply.Hashes[i].XFE.Malware.Created.String(), "short": true},
-						},
-					})
-				}
-				if reply.Hashes[i].VT.FileReport.ResponseCode == 1 {
-					vtColor := "good"
-					if reply.Hashes[i].VT.FileReport.Positives >= numOfPositivesToConvictForFiles {
-						vtColor = "danger"
-					}
-					attachments = append(attachments, map[string]interface{}{
-						"fallback":   fmt.Sprintf("Scan Date: %s, Positives: %v, Total: %v", reply.Hashes[i].VT.FileReport.ScanDate, reply.Hashes[i].VT.FileReport.Positives, reply.Hashes[i].VT.FileReport.Total),
-						"color":      vtColor,
-						"title":      "VirusTotal",
-						"title_link": reply.Hashes[i].VT.FileReport.Permalink,
-						"fields": []map[string]interface{}{
-							{"title": "Scan Date", "value": reply.Hashes[i].VT.FileReport.ScanDate, "short": true},
-							{"title": "Positives", "value": fmt.Sprintf("%v", reply.Hashes[i].VT.FileReport.Positives), "short": true},
-							{"title": "Total", "value": fmt.Sprintf("%v", reply.Hashes[i].VT.FileReport.Total), "short": true},
-						},
-					})
-				}
-			}
-		}
-		clean := true
-		if !verbose {
-			for i := range attachments {
-				if attachments[i]["color"] != "good" {
-					clean = false
-					break
-				}
-			}
-		}
-		if verbose || !clean {
-			postMessage["attachments"] = attachments
-			err = b.post(postMessage, reply, data, sub)
+		message := render.Indicators(reply, link, verbose)
+		if verbose || !message.Clean() {
+			err = b.post(message, reply, data, sub)
 			if err != nil {
 				logrus.Errorf("Unable to send message to Slack - %v\n", err)
 				return
 			}
 		} else {
 			logrus.Debugf("Reply %s clean, ignoring", reply.MessageID)
+			// The message might have been edited after we warned about it
+			b.removeReply(data.Team, data.Channel, reply.MessageID, sub)
 		}
 	}
 }
 
+// formatMessage as Block Kit blocks or as legacy attachments based on the configuration
+func formatMessage(m *render.Message) map[string]interface{} {
+	message := map[string]interface{}{"text": mainMessageFormatted()}
+	if conf.Options.Slack.LegacyAttachments {
+		message["attachments"] = render.Attachments(m)
+	} else {
+		m.Footer = mainMessageFormatted()
+		message["blocks"] = render.Blocks(m)
+	}
+	return message
+}
+
 // post uses the correct client to post to the channel
 // See if the original message poster is subscribed and if so use him.
 // If not, use the first user we have that is subscribed to the channel.
-func (b *Bot) post(message map[string]interface{}, reply *domain.WorkReply, data *domain.Context, sub *subscription) error {
-	message["text"] = mainMessageFormatted()
-	message["as_user"] = true
-	var err error
-	_, err = sub.s.Do("POST", "chat.postMessage", message)
-	return err
+func (b *Bot) post(m *render.Message, reply *domain.WorkReply, data *domain.Context, sub *subscription) error {
+	message := formatMessage(m)
+	message["channel"], message["as_user"] = data.Channel, true
+	if reply.MessageID == "" {
+		_, err := sub.s.Do("POST", "chat.postMessage", message)
+		return err
+	}
+	// If the message was edited, update the reply we already posted for it.
+	// Replies to buttons are new messages in the thread and leave the original reply as is.
+	if data.Action == "" {
+		existing, err := b.r.BotReply(data.Team, data.Channel, reply.MessageID)
+		if err == nil {
+			message["ts"] = existing.ReplyTS
+			if _, err = sub.s.Do("POST", "chat.update", message); err == nil {
+				return nil
+			}
+			logrus.WithError(err).Warnf("Unable to update reply %s - posting a new one", existing.ReplyTS)
+			delete(message, "ts")
+		} else if err != repo.ErrNotFound {
+			logrus.WithError(err).Warnf("Unable to load reply for message %s", reply.MessageID)
+		}
+	}
+	// Reply in the thread of the message, or in the thread the message is part of
+	message["thread_ts"] = reply.MessageID
+	if data.Thread != "" {
+		message["thread_ts"] = data.Thread
+	}
+	res, err := sub.s.Do("POST", "chat.postMessage", message)
+	if err != nil || data.Action != "" {
+		return err
+	}
+	err = b.r.SetBotReply(&domain.BotReply{Team: data.Team, Channel: data.Channel, MessageID: reply.MessageID, ReplyTS: res.S("ts")})
+	if err != nil {
+		logrus.WithError(err).Warnf("Unable to store reply for message %s", reply.MessageID)
+	}
+	return nil
+}
+
+// removeReply deletes the reply we posted for the message if there is one
+func (b *Bot) removeReply(team, channel, messageID string, sub *subscription) {
+	if messageID == "" {
+		return
+	}
+	existing, err := b.r.BotReply(team, channel, messageID)
+	if err != nil {
+		if err != repo.ErrNotFound {
+			logrus.WithError(err).Warnf("Unable to load reply for message %s", messageID)
+		}
+		return
+	}
+	_, err = sub.s.Do("POST", "chat.delete", map[string]interface{}{"channel": channel, "ts": existing.ReplyTS, "as_user": true})
+	if err != nil {
+		logrus.WithError(err).Warnf("Unable to delete reply %s", existing.ReplyTS)
+	}
+	if err = b.r.DelBotReply(team, channel, messageID); err != nil {
+		logrus.WithError(err).Warnf("Unable to forget reply for message %s", messageID)
+	}
 }
 
 func parseChannels(sub *subscription, text string, pos int) ([]string, []string, error) {
@@ -784,6 +517,75 @@ func (b *Bot) handleVerbose(team, text, channel string, sub *subscription) {
 	}
 }
 
+const allowUsage = "I could not understand your command. Allow commands are:\nallow add entry1 entry2... - to add indicators, domain suffixes like *.example.com or CIDRs to the team allowlist.\nallow remove entry1 entry2... - to remove entries from the team allowlist.\nallow list - to show the team allowlist."
+
+func (b *Bot) handleAllow(team, text, channel, user string, sub *subscription) {
+	postMessage := map[string]interface{}{
+		"channel": channel,
+		"as_user": true,
+	}
+	parts := strings.Fields(text)
+	command := ""
+	if len(parts) > 1 {
+		command = strings.ToLower(parts[1])
+	}
+	switch {
+	case command == "list" && len(parts) == 2:
+		allowlist, err := b.r.Allowlist(sub.team.ID)
+		if err != nil {
+			logrus.WithError(err).Warnf("error loading allowlist for team %s", team)
+			postMessage["text"] = "I had an issue loading the allowlist."
+		} else if len(allowlist) == 0 {
+			postMessage["text"] = "The team allowlist is empty."
+		} else {
+			for i := range allowlist {
+				allowlist[i] = ioc.Defang(allowlist[i])
+			}
+			postMessage["text"] = "Team allowlist:\n" + strings.Join(allowlist, "\n")
+		}
+	case (command == "add" || command == "remove") && len(parts) > 2:
+		var changed, invalid []string
+		for _, part := range parts[2:] {
+			entry, err := ioc.ParseEntry(part)
+			if err != nil {
+				invalid = append(invalid, part)
+				continue
+			}
+			if command == "add" {
+				err = b.r.AddToAllowlist(sub.team.ID, entry, user)
+			} else {
+				err = b.r.RemoveFromAllowlist(sub.team.ID, entry)
+			}
+			if err != nil {
+				logrus.WithError(err).Warnf("error updating allowlist for team %s", team)
+				invalid = append(invalid, part)
+				continue
+			}
+			changed = append(changed, ioc.Defang(entry))
+		}
+		var lines []string
+		if len(changed) > 0 {
+			if err := b.q.PushConf(team); err != nil {
+				logrus.WithError(err).Warnf("error pushing configuration message for %s", team)
+			}
+			action := "Added to"
+			if command == "remove" {
+				action = "Removed from"
+			}
+			lines = append(lines, fmt.Sprintf("%s the team allowlist: %s", action, strings.Join(changed, ", ")))
+		}
+		if len(invalid) > 0 {
+			lines = append(lines, fmt.Sprintf("I could not handle: %s - %s", strings.Join(invalid, ", "), ioc.ErrInvalidEntry))
+		}
+		postMessage["text"] = strings.Join(lines, "\n")
+	default:
+		postMessage["text"] = allowUsage
+	}
+	if _, err := sub.s.Do("POST", "chat.postMessage", postMessage); err != nil {
+		logrus.WithError(err).Warnf("error posting allow message to Slack for team [%s] on channel [%s]", team, channel)
+	}
+}
+
 func (b *Bot) handleConfig(team string, msg util.Object, sub *subscription) {
 	postMessage := map[string]interface{}{
 		"channel": msg.S("channel"),
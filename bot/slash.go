@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/render"
+	"github.com/demisto/alfred/slack"
+	"github.com/demisto/alfred/util"
+)
+
+// HandleSlash queues the lookup of the indicators in a slash command. The reply is sent to the response URL.
+func (b *Bot) HandleSlash(team, channel, user, text, responseURL string) error {
+	sub := b.relevantTeam(team)
+	if sub == nil {
+		var err error
+		if sub, err = b.loadSubscription(team); err != nil {
+			return err
+		}
+	}
+	workReq := domain.NewWorkRequest(sub.team, sub.configuration)
+	// There is no message so make up an ID for the details page
+	workReq.MessageID, workReq.Type, workReq.Text = "slash."+strconv.FormatInt(time.Now().UnixNano(), 10), "message", text
+	workReq.ReplyQueue = util.Hostname
+	workReq.Context = &domain.Context{Team: team, User: user, OriginalUser: user, Type: "message", Channel: channel, ResponseURL: responseURL}
+	// Someone is waiting for the answer
+	workReq.Priority = domain.PriorityHigh
+	return b.q.PushWork(workReq)
+}
+
+// respond to a slash command with the full reply that only the user who asked sees
+func (b *Bot) respond(reply *domain.WorkReply, data *domain.Context, sub *subscription) {
+	link := fmt.Sprintf("%s/details?c=%s&m=%s&t=%s", conf.Options.ExternalAddress, data.Channel, reply.MessageID, sub.team.ID)
+	message := formatMessage(render.Indicators(reply, link, true))
+	message["response_type"], message["replace_original"] = "ephemeral", false
+	if err := slack.Respond(data.ResponseURL, message); err != nil {
+		logrus.WithError(err).Warnf("Unable to respond to slash command for team [%s]", sub.team.ID)
+	}
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+	"github.com/demisto/alfred/util"
+)
+
+// policyFor the given indicator type - the team policy overrides the trust and thresholds of the default
+// policy for the providers it mentions and replaces the rules if it has any
+func policyFor(request *domain.WorkRequest, replyType int) *conf.VerdictPolicy {
+	def, team := selectPolicy(&conf.Options.Verdict, replyType), (*conf.VerdictPolicy)(nil)
+	if request.Policy != nil {
+		team = selectPolicy(request.Policy, replyType)
+	}
+	if team == nil {
+		return def
+	}
+	policy := &conf.VerdictPolicy{
+		Trust:      make(map[string]float64),
+		Thresholds: make(map[string]float64),
+		Strict:     def.Strict,
+		Rules:      def.Rules,
+	}
+	for _, p := range []*conf.VerdictPolicy{def, team} {
+		for k, v := range p.Trust {
+			policy.Trust[k] = v
+		}
+		for k, v := range p.Thresholds {
+			policy.Thresholds[k] = v
+		}
+	}
+	if team.Strict != nil {
+		policy.Strict = team.Strict
+	}
+	if len(team.Rules) > 0 {
+		policy.Rules = team.Rules
+	}
+	return policy
+}
+
+func selectPolicy(policies *conf.VerdictPolicies, replyType int) *conf.VerdictPolicy {
+	switch replyType {
+	case domain.ReplyTypeHash:
+		return &policies.Hash
+	case domain.ReplyTypeURL:
+		return &policies.URL
+	case domain.ReplyTypeIP:
+		return &policies.IP
+	case domain.ReplyTypeDomain:
+		return &policies.Domain
+	}
+	return nil
+}
+
+func ruleResult(result string) int {
+	switch result {
+	case "clean":
+		return domain.ResultClean
+	case "dirty":
+		return domain.ResultDirty
+	}
+	return domain.ResultUnknown
+}
+
+// trust of the provider in the policy - providers not mentioned have a trust of 1
+func trust(policy *conf.VerdictPolicy, provider string) float64 {
+	if t, ok := policy.Trust[provider]; ok {
+		return t
+	}
+	return 1
+}
+
+// found checks if any of the providers found the indicator - a failed lookup may have found it as well
+func found(results map[string]domain.ProviderResult, providers []string) bool {
+	for _, name := range providers {
+		if res, ok := results[name]; ok && (res.Result != domain.ResultUnknown || res.Error != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// verdict applies the policy to the provider results and returns the result with the name of the rule that produced it.
+// Providers with a threshold in the policy have their result replaced based on their score.
+// A score at the threshold convicts unless the provider is strict.
+func verdict(policy *conf.VerdictPolicy, results map[string]domain.ProviderResult) (int, string) {
+	for name, res := range results {
+		threshold, ok := policy.Thresholds[name]
+		if !ok || res.Error != "" || res.Result == domain.ResultUnknown {
+			continue
+		}
+		res.Result = domain.ResultClean
+		if res.Score > threshold || res.Score == threshold && !util.In(policy.Strict, name) {
+			res.Result = domain.ResultDirty
+		}
+		results[name] = res
+	}
+	for _, rule := range policy.Rules {
+		if found(results, rule.NotFound) {
+			continue
+		}
+		result := ruleResult(rule.Result)
+		quorum, score := 0, 0.0
+		for name, res := range results {
+			if res.Result != result || len(rule.Providers) > 0 && !util.In(rule.Providers, name) {
+				continue
+			}
+			quorum++
+			score += trust(policy, name)
+		}
+		if quorum > 0 && quorum >= rule.Quorum && score >= rule.Score {
+			return result, rule.Name
+		}
+	}
+	return domain.ResultUnknown, ""
+}
+
+// teamVerdict for indicators on the team blocklist or allowlist. The team knows better than the providers so
+// there is no need to look these up - it also keeps the team intel from being sent to the public services.
+// Blocklisted indicators get the team intel as their provider result.
+func teamVerdict(lists *teamLists, indicator ioc.Indicator) (int, string, map[string]domain.ProviderResult, bool) {
+	for _, entry := range lists.blocklist {
+		if entry.Matches(indicator) {
+			summary := "On the team blocklist as " + entry.Value
+			if entry.Description != "" {
+				summary += " - " + entry.Description
+			}
+			providers := map[string]domain.ProviderResult{domain.ProviderTeamIntel: {Result: domain.ResultDirty, Summary: summary}}
+			return domain.ResultDirty, domain.RuleBlocklist + entry.Value, providers, true
+		}
+	}
+	for _, entry := range lists.allowlist {
+		if entry.Matches(indicator) {
+			return domain.ResultClean, domain.RuleAllowlist + entry.Value, nil, true
+		}
+	}
+	return domain.ResultUnknown, "", nil, false
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+)
+
+func TestDefaultVerdict(t *testing.T) {
+	conf.Load("", true)
+	policy := policyFor(&domain.WorkRequest{}, domain.ReplyTypeURL)
+	results := map[string]domain.ProviderResult{
+		"xfe": {Result: domain.ResultClean, Score: 1},
+		"vt":  {Result: domain.ResultClean, Score: 8},
+	}
+	if res, rule := verdict(policy, results); res != domain.ResultDirty || rule != "any-dirty" {
+		t.Errorf("Expected dirty by any-dirty but got %v by %s", res, rule)
+	}
+	if results["vt"].Result != domain.ResultDirty || results["xfe"].Result != domain.ResultClean {
+		t.Error("Thresholds were not applied to the provider results")
+	}
+	results = map[string]domain.ProviderResult{
+		"xfe": {Result: domain.ResultUnknown},
+		"vt":  {Result: domain.ResultClean, Score: 2},
+	}
+	if res, rule := verdict(policy, results); res != domain.ResultClean || rule != "any-clean" {
+		t.Errorf("Expected clean by any-clean but got %v by %s", res, rule)
+	}
+	// Cylance is strict and convicts only below a general score of -0.5
+	hashPolicy := policyFor(&domain.WorkRequest{}, domain.ReplyTypeHash)
+	results = map[string]domain.ProviderResult{"cy": {Result: domain.ResultClean, Score: 0.5}}
+	if res, rule := verdict(hashPolicy, results); res != domain.ResultClean || rule != "any-clean" {
+		t.Errorf("Expected clean by any-clean but got %v by %s", res, rule)
+	}
+	results = map[string]domain.ProviderResult{"cy": {Result: domain.ResultClean, Score: 0.51}}
+	if res, rule := verdict(hashPolicy, results); res != domain.ResultDirty || rule != "any-dirty" {
+		t.Errorf("Expected dirty by any-dirty but got %v by %s", res, rule)
+	}
+	results = map[string]domain.ProviderResult{"vt": {Result: domain.ResultClean, Score: 3}}
+	if res, rule := verdict(hashPolicy, results); res != domain.ResultDirty || rule != "any-dirty" {
+		t.Errorf("Expected dirty by any-dirty but got %v by %s", res, rule)
+	}
+	results = map[string]domain.ProviderResult{"vt": {Result: domain.ResultUnknown, Error: "failed"}}
+	if res, rule := verdict(policy, results); res != domain.ResultUnknown || rule != "" {
+		t.Errorf("Expected unknown but got %v by %s", res, rule)
+	}
+}
+
+func TestTeamVerdict(t *testing.T) {
+	conf.Load("", true)
+	request := &domain.WorkRequest{Policy: &conf.VerdictPolicies{URL: conf.VerdictPolicy{
+		Trust: map[string]float64{"xfe": 0.5},
+		Rules: []conf.VerdictRule{
+			{Name: "weighted", Result: "dirty", Quorum: 1, Score: 1},
+			{Name: "quorum", Result: "clean", Quorum: 2},
+		},
+	}}}
+	policy := policyFor(request, domain.ReplyTypeURL)
+	if policy.Thresholds["vt"] != conf.Options.Verdict.URL.Thresholds["vt"] {
+		t.Error("Team policy should keep the default thresholds")
+	}
+	results := map[string]domain.ProviderResult{
+		"xfe": {Result: domain.ResultClean, Score: 9},
+		"vt":  {Result: domain.ResultClean, Score: 1},
+	}
+	if res, rule := verdict(policy, results); res != domain.ResultUnknown || rule != "" {
+		t.Errorf("Expected unknown since xfe is not trusted enough but got %v by %s", res, rule)
+	}
+	results = map[string]domain.ProviderResult{
+		"xfe": {Result: domain.ResultClean, Score: 1},
+		"vt":  {Result: domain.ResultClean, Score: 1},
+	}
+	if res, rule := verdict(policy, results); res != domain.ResultClean || rule != "quorum" {
+		t.Errorf("Expected clean by quorum but got %v by %s", res, rule)
+	}
+	if request.Policy.URL.Thresholds != nil {
+		t.Error("The team policy should not be modified")
+	}
+}
+
+func TestTeamListsVerdict(t *testing.T) {
+	lists := &teamLists{
+		allowlist: newAllowlist([]string{".example.com"}),
+		blocklist: newBlocklist([]domain.BlocklistEntry{{Indicator: "10.0.0.0/8", Description: "IR-1"}, {Indicator: "bad.example.com"}}),
+	}
+	tests := []struct {
+		indicator ioc.Indicator
+		result    int
+		rule      string
+	}{
+		{ioc.Indicator{Type: ioc.Domain, Value: "cdn.example.com"}, domain.ResultClean, domain.RuleAllowlist + ".example.com"},
+		{ioc.Indicator{Type: ioc.Domain, Value: "bad.example.com"}, domain.ResultDirty, domain.RuleBlocklist + "bad.example.com"},
+		{ioc.Indicator{Type: ioc.URL, Value: "http://10.1.2.3/payload"}, domain.ResultDirty, domain.RuleBlocklist + "10.0.0.0/8"},
+	}
+	for _, test := range tests {
+		result, rule, providers, ok := teamVerdict(lists, test.indicator)
+		if !ok || result != test.result || rule != test.rule {
+			t.Errorf("Expected %v by %s for %s but got %v by %s", test.result, test.rule, test.indicator.Value, result, rule)
+		}
+		if intel, found := providers[domain.ProviderTeamIntel]; found != (result == domain.ResultDirty) || found && intel.Result != domain.ResultDirty {
+			t.Errorf("Unexpected team intel %+v for %s", providers, test.indicator.Value)
+		}
+	}
+	if _, _, _, ok := teamVerdict(lists, ioc.Indicator{Type: ioc.Domain, Value: "evil.com"}); ok {
+		t.Error("Expected evil.com not to be on the team lists")
+	}
+}
@@ -0,0 +1,58 @@
+// Package cef formats events in the ArcSight Common Event Format and forwards them to a SIEM over syslog
+package cef
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// Vendor of the events
+	Vendor = "Demisto"
+	// Product of the events
+	Product = "DBot"
+	// Version of the product in the events
+	Version = "1.0"
+)
+
+// Extension is a key and value pair of the event. Keys should be CEF dictionary keys.
+type Extension struct {
+	Key   string
+	Value string
+}
+
+// Event in the Common Event Format
+type Event struct {
+	SignatureID string
+	Name        string
+	Severity    int // 0 to 10
+	Extensions  []Extension
+}
+
+var (
+	headerEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	extensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// Add an extension to the event - empty values are skipped
+func (e *Event) Add(key, value string) {
+	if value != "" {
+		e.Extensions = append(e.Extensions, Extension{Key: key, Value: value})
+	}
+}
+
+// String of the event in CEF
+func (e *Event) String() string {
+	severity := e.Severity
+	if severity < 0 {
+		severity = 0
+	} else if severity > 10 {
+		severity = 10
+	}
+	parts := []string{"CEF:0", Vendor, Product, Version, headerEscaper.Replace(e.SignatureID), headerEscaper.Replace(e.Name), strconv.Itoa(severity)}
+	extensions := make([]string, len(e.Extensions))
+	for i := range e.Extensions {
+		extensions[i] = e.Extensions[i].Key + "=" + extensionEscaper.Replace(e.Extensions[i].Value)
+	}
+	return strings.Join(parts, "|") + "|" + strings.Join(extensions, " ")
+}
@@ -0,0 +1,94 @@
+package cef
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestString(t *testing.T) {
+	e := &Event{SignatureID: "url-dirty", Name: "Malicious URL | phishing", Severity: 8}
+	e.Add("cs1Label", "team")
+	e.Add("cs1", "T1")
+	e.Add("suser", "")
+	e.Add("request", `http://evil.com/a?b=c\d`)
+	e.Add("msg", "line1\nline2")
+	expected := `CEF:0|Demisto|DBot|1.0|url-dirty|Malicious URL \| phishing|8|cs1Label=team cs1=T1 request=http://evil.com/a?b\=c\\d msg=line1\nline2`
+	if s := e.String(); s != expected {
+		t.Errorf("Expected %s but got %s", expected, s)
+	}
+}
+
+func testEvent() *Event {
+	e := &Event{SignatureID: "domain-dirty", Name: "Malicious domain", Severity: 8}
+	e.Add("cs6Label", "indicator")
+	e.Add("cs6", "evil.com")
+	return e
+}
+
+func checkMessage(t *testing.T, msg string) {
+	if !strings.HasPrefix(msg, "<131>1 ") || !strings.HasSuffix(msg, " dbot - - - "+testEvent().String()) {
+		t.Errorf("Unexpected syslog message %q", msg)
+	}
+}
+
+func TestForwardUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	f, err := NewForwarder("udp", conn.LocalAddr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !f.Send(testEvent()) {
+		t.Fatal("Event was dropped")
+	}
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMessage(t, string(buf[:n]))
+}
+
+func TestForwardTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := NewForwarder("tcp", l.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Send(testEvent())
+	f.Send(testEvent())
+	f.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	scanner := bufio.NewScanner(conn)
+	lines := 0
+	for scanner.Scan() {
+		checkMessage(t, scanner.Text())
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("Expected 2 messages but got %d", lines)
+	}
+}
+
+func TestNewForwarderNetwork(t *testing.T) {
+	if _, err := NewForwarder("unix", "/dev/log", nil); err == nil {
+		t.Error("Expected an error for an unknown network")
+	}
+}
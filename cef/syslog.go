@@ -0,0 +1,128 @@
+package cef
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// facility of the syslog messages - local0
+	facility = 16
+	// bufferSize of the events waiting to be sent. Events are dropped when the SIEM cannot keep up.
+	bufferSize = 1000
+	// ioTimeout of connecting and writing to the SIEM
+	ioTimeout = 10 * time.Second
+)
+
+// Forwarder sends events to a syslog server in the background
+type Forwarder struct {
+	network  string
+	address  string
+	tls      *tls.Config
+	hostname string
+	conn     net.Conn
+	events   chan string
+	wg       sync.WaitGroup
+}
+
+// NewForwarder to the syslog server at address over udp, tcp or tls. The connection is made when the first event is sent
+// so a SIEM that is down does not stop us from starting.
+func NewForwarder(network, address string, tlsConfig *tls.Config) (*Forwarder, error) {
+	switch network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("cef: unknown syslog network %s - it must be udp, tcp or tls", network)
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	f := &Forwarder{network: network, address: address, tls: tlsConfig, hostname: hostname, events: make(chan string, bufferSize)}
+	f.wg.Add(1)
+	go f.run()
+	return f, nil
+}
+
+// syslogSeverity of the CEF severity
+func syslogSeverity(severity int) int {
+	switch {
+	case severity >= 9:
+		return 2 // Critical
+	case severity >= 7:
+		return 3 // Error
+	case severity >= 4:
+		return 4 // Warning
+	}
+	return 6 // Informational
+}
+
+// message of the event as an RFC 5424 syslog message
+func (f *Forwarder) message(e *Event, t time.Time) string {
+	msg := fmt.Sprintf("<%d>1 %s %s dbot - - - %s", facility*8+syslogSeverity(e.Severity), t.UTC().Format(time.RFC3339), f.hostname, e.String())
+	// Streams are framed by new lines
+	if f.network != "udp" {
+		msg += "\n"
+	}
+	return msg
+}
+
+// Send the event in the background. Returns false if the event was dropped because the buffer is full.
+func (f *Forwarder) Send(e *Event) bool {
+	select {
+	case f.events <- f.message(e, time.Now()):
+		return true
+	default:
+		return false
+	}
+}
+
+// Close the forwarder after the pending events are sent
+func (f *Forwarder) Close() {
+	close(f.events)
+	f.wg.Wait()
+}
+
+func (f *Forwarder) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: ioTimeout}
+	if f.network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", f.address, f.tls)
+	}
+	return dialer.Dial(f.network, f.address)
+}
+
+// write the message reconnecting once if the connection broke
+func (f *Forwarder) write(msg string) error {
+	var err error
+	for i := 0; i < 2; i++ {
+		if f.conn == nil {
+			if f.conn, err = f.dial(); err != nil {
+				return err
+			}
+		}
+		f.conn.SetWriteDeadline(time.Now().Add(ioTimeout))
+		if _, err = f.conn.Write([]byte(msg)); err == nil {
+			return nil
+		}
+		f.conn.Close()
+		f.conn = nil
+	}
+	return err
+}
+
+func (f *Forwarder) run() {
+	defer f.wg.Done()
+	for msg := range f.events {
+		if err := f.write(msg); err != nil {
+			logrus.WithError(err).Warnf("Unable to forward event to syslog at %s", f.address)
+		}
+	}
+	if f.conn != nil {
+		f.conn.Close()
+	}
+}
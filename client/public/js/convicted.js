@@ -0,0 +1,113 @@
+// Conviction history
+// -----------------------------------
+
+(function ($) {
+  'use strict';
+
+  if (!$('#convicted-filter').length) {
+    return;
+  }
+  var page = 1;
+  var limit = 50;
+  var types = {1: 'Hash', 2: 'URL', 4: 'IP', 8: 'File', 16: 'Domain'};
+  var providers = {vt: 'VT', xfe: 'XFE', clamav: 'ClamAV', cy: 'Cylance', af: 'AutoFocus', intel: 'Intel'};
+
+  var params = function(format) {
+    var p = {page: page, limit: limit};
+    $.each($('#convicted-filter').serializeArray(), function(i, field) {
+      if (field.value) {
+        p[field.name] = field.value;
+      }
+    });
+    if (format) {
+      p.format = format;
+    }
+    return p;
+  };
+
+  var unauthorized = function(xhr) {
+    if (xhr.status === 401) {
+      $('#unauthmodal').modal('show');
+      window.setTimeout(function() {
+        window.location.href = '/';
+      }, 3000);
+    }
+  };
+
+  var load = function() {
+    $.getJSON('/api/convicted', params(), function(data) {
+      var rows = $('#convicted-rows').empty();
+      $.each(data.convicted, function(i, c) {
+        var scores = [];
+        $.each(providers, function(key, name) {
+          if (c[key]) {
+            scores.push(name + ': ' + c[key]);
+          }
+        });
+        var content = c.file_name ? c.file_name + ' (' + c.content + ')' : c.content;
+        $('<tr>')
+          .append($('<td>').text(new Date(c.ts).toLocaleString()))
+          .append($('<td>').text(c.channel))
+          .append($('<td>').text(types[c.content_type] || c.content_type))
+          .append($('<td>').text(content))
+          .append($('<td>').text(scores.join(', ')))
+          .appendTo(rows);
+      });
+      var pages = Math.max(Math.ceil(data.total / data.limit), 1);
+      $('#convicted-page').text('Page ' + data.page + ' of ' + pages + ' (' + data.total + ' convictions)');
+      $('#convicted-prev').prop('disabled', data.page <= 1);
+      $('#convicted-next').prop('disabled', data.page >= pages);
+    }).fail(unauthorized);
+  };
+
+  var loadStats = function() {
+    var from = new Date(Date.now() - 6 * 24 * 60 * 60 * 1000).toISOString().substring(0, 10);
+    $.getJSON('/api/stats', {interval: 'day', from: from}, function(data) {
+      var rows = $('#stats-rows').empty();
+      $.each(data.buckets, function(i, s) {
+        $('<tr>')
+          .append($('<td>').text(s.ts.substring(0, 10)))
+          .append($('<td>').text(s.messages))
+          .append($('<td>').text(s.urls_dirty))
+          .append($('<td>').text(s.ips_dirty))
+          .append($('<td>').text(s.domains_dirty))
+          .append($('<td>').text(s.hashes_dirty))
+          .append($('<td>').text(s.files_dirty))
+          .appendTo(rows);
+      });
+    }).fail(unauthorized);
+  };
+
+  $('#convicted-filter').submit(function(event) {
+    event.preventDefault();
+    page = 1;
+    load();
+  });
+  $('#convicted-prev').click(function() {
+    page--;
+    load();
+  });
+  $('#convicted-next').click(function() {
+    page++;
+    load();
+  });
+  // The API only talks JSON to the browser so the CSV is downloaded through a blob.
+  // Without page and limit the export has all the matching convictions.
+  $('#convicted-csv').click(function() {
+    var p = params('csv');
+    delete p.page;
+    delete p.limit;
+    $.ajax({url: '/api/convicted', data: p, dataType: 'text', headers: {'Accept': 'application/json, text/csv'}})
+      .done(function(csv) {
+        var link = document.createElement('a');
+        link.href = URL.createObjectURL(new Blob([csv], {type: 'text/csv'}));
+        link.download = 'convicted.csv';
+        document.body.appendChild(link);
+        link.click();
+        document.body.removeChild(link);
+      })
+      .fail(unauthorized);
+  });
+  loadStats();
+  load();
+})(window.jQuery);
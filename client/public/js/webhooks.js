@@ -0,0 +1,90 @@
+// Conviction webhooks
+// -----------------------------------
+
+(function ($) {
+  'use strict';
+
+  if (!$('#webhook-add').length) {
+    return;
+  }
+
+  var unauthorized = function(xhr) {
+    if (xhr.status === 401) {
+      $('#unauthmodal').modal('show');
+      window.setTimeout(function() {
+        window.location.href = '/';
+      }, 3000);
+    }
+  };
+
+  var time = function(t) {
+    return t ? new Date(t).toLocaleString() : '';
+  };
+
+  var loadDeliveries = function() {
+    $.getJSON('/webhooks/deliveries', function(data) {
+      var rows = $('#delivery-rows').empty();
+      $.each(data.deliveries, function(i, d) {
+        $('<tr>')
+          .append($('<td>').text(time(d.created)))
+          .append($('<td>').text(d.url))
+          .append($('<td>').text(d.status))
+          .append($('<td>').text(d.attempts))
+          .append($('<td>').text(d.last_error))
+          .append($('<td>').text(time(d.next_attempt)))
+          .appendTo(rows);
+      });
+    }).fail(unauthorized);
+  };
+
+  var remove = function(id) {
+    $.ajax({
+      type: 'DELETE',
+      url: '/webhooks?id=' + id,
+      headers: {'X-XSRF-TOKEN': Cookies.get('XSRF'), 'Accept': 'application/json'}
+    }).done(load).fail(unauthorized);
+  };
+
+  var load = function() {
+    $.getJSON('/webhooks', function(data) {
+      var rows = $('#webhook-rows').empty();
+      $.each(data.webhooks, function(i, w) {
+        var button = $('<button type="button" class="btn btn-default">').text('Remove').click(function() {
+          remove(w.id);
+        });
+        $('<tr>')
+          .append($('<td>').text(w.url))
+          .append($('<td>').text(w.user))
+          .append($('<td>').text(time(w.created)))
+          .append($('<td>').append(button))
+          .appendTo(rows);
+      });
+    }).fail(unauthorized);
+    loadDeliveries();
+  };
+
+  $('#webhook-add').submit(function(event) {
+    event.preventDefault();
+    var form = $(this);
+    $.ajax({
+      type: 'POST',
+      url: '/webhooks',
+      data: JSON.stringify({url: form.find('[name=url]').val(), secret: form.find('[name=secret]').val()}),
+      headers: {'X-XSRF-TOKEN': Cookies.get('XSRF')},
+      dataType: 'json',
+      contentType: 'application/json; charset=utf-8'
+    }).done(function(data) {
+      form[0].reset();
+      $('#webhook-secret').text('Keep the secret of the new webhook - it is not shown again: ' + data.secret);
+      load();
+    }).fail(function(xhr) {
+      unauthorized(xhr);
+      if (xhr.responseJSON && xhr.responseJSON.errors && xhr.responseJSON.errors[0]) {
+        $('#webhook-secret').text(xhr.responseJSON.errors[0].detail);
+      }
+    });
+  });
+
+  load();
+  window.setInterval(loadDeliveries, 30000);
+})(window.jQuery);
@@ -15,6 +15,8 @@ var DefaultHelpMessage = `Here are the commands I understand when you send me a
 *join all/#channel1,#channel2...*: I will join all/specified public channels and start monitoring them.
 *verbose on/off #channel1,#channel2,private1...* - turn on verbose mode on the specified channels or private groups
 verbose mode is usually used by security professionals. When in verbose mode, dbot will display reputation details about any URL, IP or file including clean ones.
+*allow add/remove entry1 entry2...*: add or remove indicators, domain suffixes like *.example.com or CIDRs like 10.0.0.0/8 on the team allowlist. Allowlisted items are considered clean and are not looked up.
+*allow list*: show the team allowlist.
 
 *af the-api-key-you-got-from-autofocus*: add your own AutoFocus credentials to use. Accepts "-" to return to default. 
 *vt the-api-key-you-got-from-vt*: add your own VirusTotal key to use. Accepts "-" to return to default. You can get a key at https://www.virustotal.com/en/documentation/public-api/
@@ -55,6 +57,10 @@ var Options struct {
 		ClientID string
 		// ClientSecret is used to verify Slack reply
 		ClientSecret string
+		// SigningSecret is used to verify the signature of the events Slack sends us
+		SigningSecret string
+		// LegacyAttachments posts the replies as legacy attachments instead of Block Kit blocks
+		LegacyAttachments bool
 	}
 	// VT token
 	VT string
@@ -69,6 +75,19 @@ var Options struct {
 	Cy string
 	// AF key
 	AF string
+	// Verdict policies for each indicator type - can be overridden per team
+	Verdict VerdictPolicies
+	// Cache of reputation results shared by all workers
+	Cache struct {
+		// Size of the in-process LRU in front of the database
+		Size int
+		// TTL in minutes of clean results - 0 to disable
+		Clean int
+		// TTL in minutes of dirty results - 0 to disable
+		Dirty int
+		// TTL in minutes of unknown results - 0 to disable
+		Unknown int
+	}
 	// DB properties
 	DB struct {
 		// ConnectString how to connect to DB
@@ -97,10 +116,67 @@ var Options struct {
 			Type         string `json:"type"`
 		}
 	}
+	// Queue properties
+	Queue struct {
+		// Type of the queue - db or redis
+		Type string
+		// Lease in seconds of a claimed work request before it is delivered again
+		Lease int
+		// Retries of a work request before it moves to the dead letters
+		Retries int
+		// Redis connection when the type is redis
+		Redis struct {
+			// Address of the Redis server as host:port
+			Address string
+			// Password for Redis
+			Password string
+			// DB number to use
+			DB int
+		}
+	}
+	// TAXII 2.1 collections polled into the team intel
+	TAXII struct {
+		// Interval in minutes between polls - 0 disables polling
+		Interval int
+		// Collections to poll
+		Collections []TAXIICollection
+	}
+	// Syslog server CEF events of the verdicts are forwarded to
+	Syslog struct {
+		// Network is udp, tcp or tls
+		Network string
+		// Address of the server as host:port - empty disables forwarding
+		Address string
+		// ServerCA PEM to verify the server with over TLS - the system roots if empty
+		ServerCA string
+		// MinSeverity of the forwarded events - 1 for clean, 3 for unknown and 8 for malicious verdicts
+		MinSeverity int
+	}
+	// MISP instance convicted content is pushed to by the export tool
+	MISP struct {
+		// URL of the MISP instance
+		URL string
+		// Key is the automation key of the MISP user
+		Key string
+	}
 	Web       bool
 	Worker    bool
 	ClamCtl   string
 	QueuePoll int
+	// WorkTimeout in seconds to wait for the worker when showing details on the web
+	WorkTimeout int
+}
+
+// TAXIICollection is polled for STIX indicators that are added to the intel of a team
+type TAXIICollection struct {
+	// Team is the Slack ID of the team the intel is for
+	Team string
+	// URL of the collection - https://host/api-root/collections/id/
+	URL string
+	// Username for basic authentication if needed
+	Username string
+	// Password for basic authentication if needed
+	Password string
 }
 
 // The pipe writer to wrap around standard logger. It is configured in main.
@@ -128,6 +204,53 @@ func Load(filename string, useDefault bool) error {
 	"Worker": true,
 	"ClamCtl": "/var/run/clamav/clamd.ctl",
 	"QueuePoll": 10,
+	"WorkTimeout": 60,
+	"Queue": {
+		"Type": "db",
+		"Lease": 300,
+		"Retries": 3,
+		"Redis": {
+			"Address": "localhost:6379"
+		}
+	},
+	"Cache": {
+		"Size": 10000,
+		"Clean": 1440,
+		"Dirty": 1440,
+		"Unknown": 60
+	},
+	"Verdict": {
+		"Hash": {
+			"Thresholds": {"vt": 3, "cy": 0.5},
+			"Strict": ["cy"],
+			"Rules": [
+				{"name": "any-dirty", "result": "dirty", "quorum": 1},
+				{"name": "any-clean", "result": "clean", "quorum": 1}
+			]
+		},
+		"URL": {
+			"Thresholds": {"vt": 7, "xfe": 7},
+			"Rules": [
+				{"name": "any-dirty", "result": "dirty", "quorum": 1},
+				{"name": "any-clean", "result": "clean", "quorum": 1}
+			]
+		},
+		"IP": {
+			"Thresholds": {"vt": 7, "xfe": 7},
+			"Rules": [
+				{"name": "xfe-dirty", "result": "dirty", "providers": ["xfe"], "quorum": 1},
+				{"name": "vt-dirty", "result": "dirty", "providers": ["vt"], "not_found": ["xfe"], "quorum": 1},
+				{"name": "any-clean", "result": "clean", "quorum": 1}
+			]
+		},
+		"Domain": {
+			"Thresholds": {"vt": 7, "xfe": 7},
+			"Rules": [
+				{"name": "any-dirty", "result": "dirty", "quorum": 1},
+				{"name": "any-clean", "result": "clean", "quorum": 1}
+			]
+		}
+	},
 	"Security": {
 		"SessionKey": "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx",
 		"Timeout": 525600,
@@ -158,6 +281,9 @@ func Load(filename string, useDefault bool) error {
 		logrus.Warn("no file provided and we are not using default")
 		return errors.New("no file and no default")
 	}
+	if err = Options.Verdict.Validate(); err != nil {
+		return err
+	}
 	finalOptions, err := json.MarshalIndent(&Options, "", "  ")
 	if err != nil {
 		return err
@@ -0,0 +1,59 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VerdictRule is a single rule of a verdict policy. The rules of a policy are checked in order and the first
+// rule that matches decides the result.
+type VerdictRule struct {
+	Name      string   `json:"name"`
+	Result    string   `json:"result"`    // The result of the rule - clean or dirty
+	Providers []string `json:"providers"` // Only count the results of these providers - all if empty
+	NotFound  []string `json:"not_found"` // Only apply the rule if these providers did not find the indicator
+	Quorum    int      `json:"quorum"`    // Minimal number of providers agreeing on the result
+	Score     float64  `json:"score"`     // Minimal sum of the trust of the providers agreeing on the result
+}
+
+// VerdictPolicy decides the result of an indicator from the results of the reputation providers
+type VerdictPolicy struct {
+	Trust      map[string]float64 `json:"trust"`      // The weight of each provider - 1 if missing
+	Thresholds map[string]float64 `json:"thresholds"` // The provider score from which the provider convicts
+	Strict     []string           `json:"strict"`     // Providers that convict only above their threshold and not at it
+	Rules      []VerdictRule      `json:"rules"`
+}
+
+// VerdictPolicies holds the policy for each indicator type
+type VerdictPolicies struct {
+	Hash   VerdictPolicy `json:"hash"`
+	URL    VerdictPolicy `json:"url"`
+	IP     VerdictPolicy `json:"ip"`
+	Domain VerdictPolicy `json:"domain"`
+}
+
+// Validate the policy rules
+func (p *VerdictPolicy) Validate() error {
+	for _, rule := range p.Rules {
+		if rule.Name == "" {
+			return errors.New("verdict rule without a name")
+		}
+		if rule.Result != "clean" && rule.Result != "dirty" {
+			return fmt.Errorf("verdict rule %s has invalid result %s", rule.Name, rule.Result)
+		}
+		if rule.Quorum < 0 || rule.Score < 0 {
+			return fmt.Errorf("verdict rule %s has a negative quorum or score", rule.Name)
+		}
+	}
+	return nil
+}
+
+// Validate all the policies
+func (p *VerdictPolicies) Validate() error {
+	for _, policy := range []*VerdictPolicy{&p.Hash, &p.URL, &p.IP, &p.Domain} {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
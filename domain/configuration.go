@@ -4,20 +4,31 @@ import (
 	"regexp"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/conf"
 	"github.com/demisto/alfred/util"
 )
 
 // Configuration holds the user configuration
 type Configuration struct {
-	Team            string   `json:"team"`
-	Channels        []string `json:"channels"`
-	Groups          []string `json:"groups"`
-	IM              bool     `json:"im"`
-	Regexp          string   `json:"regexp"`
-	All             bool     `json:"all"`
-	VerboseChannels []string `json:"verbose_channels"`
-	VerboseGroups   []string `json:"verbose_groups"`
-	VerboseIM       bool     `json:"verbose_im"`
+	Team            string                `json:"team"`
+	Channels        []string              `json:"channels"`
+	Groups          []string              `json:"groups"`
+	IM              bool                  `json:"im"`
+	Regexp          string                `json:"regexp"`
+	All             bool                  `json:"all"`
+	VerboseChannels []string              `json:"verbose_channels"`
+	VerboseGroups   []string              `json:"verbose_groups"`
+	VerboseIM       bool                  `json:"verbose_im"`
+	Providers       []string              `json:"providers"` // The saved providers are kept if nil - empty for all providers
+	Policy          *conf.VerdictPolicies `json:"policy"`    // The saved policy is kept if nil - empty for the default policy
+	Allowlist       []string              `json:"allowlist"` // Indicators the team considers clean
+	Blocklist       []BlocklistEntry      `json:"blocklist"` // Indicators from the team own intel that are malicious
+}
+
+// BlocklistEntry is an indicator, domain suffix or CIDR the team knows to be malicious
+type BlocklistEntry struct {
+	Indicator   string `json:"indicator"`
+	Description string `json:"description"`
 }
 
 // IsActive returns true if there is at least one active part for the user
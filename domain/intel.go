@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// IntelIndicator is a malicious indicator from the threat intel a team imported.
+// Type is one of the reply types - ReplyTypeHash, ReplyTypeURL, ReplyTypeIP or ReplyTypeDomain.
+// IP values can be CIDRs.
+type IntelIndicator struct {
+	Team       string     `json:"team"`
+	STIXID     string     `json:"stix_id" db:"stix_id"`
+	Type       int        `json:"type" db:"indicator_type"`
+	Value      string     `json:"value"`
+	Name       string     `json:"name"`
+	ValidFrom  time.Time  `json:"valid_from" db:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until" db:"valid_until"` // Nil if the indicator does not expire
+}
+
+// Active if the indicator is valid at the given time
+func (i *IntelIndicator) Active(t time.Time) bool {
+	return !t.Before(i.ValidFrom) && (i.ValidUntil == nil || t.Before(*i.ValidUntil))
+}
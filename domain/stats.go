@@ -4,21 +4,24 @@ import "time"
 
 // Statistics holds message and detection statistics for a team
 type Statistics struct {
-	Team          string    `json:"team"`
-	Timestamp     time.Time `json:"ts" db:"ts"`
-	Messages      int64     `json:"messages"`
-	FilesClean    int64     `json:"files_clean" db:"files_clean"`
-	FilesDirty    int64     `json:"files_dirty" db:"files_dirty"`
-	FilesUnknown  int64     `json:"files_unknown" db:"files_unknown"`
-	URLsClean     int64     `json:"urls_clean" db:"urls_clean"`
-	URLsDirty     int64     `json:"urls_dirty" db:"urls_dirty"`
-	URLsUnknown   int64     `json:"urls_unknown" db:"urls_unknown"`
-	HashesClean   int64     `json:"hashes_clean" db:"hashes_clean"`
-	HashesDirty   int64     `json:"hashes_dirty" db:"hashes_dirty"`
-	HashesUnknown int64     `json:"hashes_unknown" db:"hashes_unknown"`
-	IPsClean      int64     `json:"ips_clean" db:"ips_clean"`
-	IPsDirty      int64     `json:"ips_dirty" db:"ips_dirty"`
-	IPsUnknown    int64     `json:"ips_unknown" db:"ips_unknown"`
+	Team           string    `json:"team"`
+	Timestamp      time.Time `json:"ts" db:"ts"`
+	Messages       int64     `json:"messages"`
+	FilesClean     int64     `json:"files_clean" db:"files_clean"`
+	FilesDirty     int64     `json:"files_dirty" db:"files_dirty"`
+	FilesUnknown   int64     `json:"files_unknown" db:"files_unknown"`
+	URLsClean      int64     `json:"urls_clean" db:"urls_clean"`
+	URLsDirty      int64     `json:"urls_dirty" db:"urls_dirty"`
+	URLsUnknown    int64     `json:"urls_unknown" db:"urls_unknown"`
+	HashesClean    int64     `json:"hashes_clean" db:"hashes_clean"`
+	HashesDirty    int64     `json:"hashes_dirty" db:"hashes_dirty"`
+	HashesUnknown  int64     `json:"hashes_unknown" db:"hashes_unknown"`
+	IPsClean       int64     `json:"ips_clean" db:"ips_clean"`
+	IPsDirty       int64     `json:"ips_dirty" db:"ips_dirty"`
+	IPsUnknown     int64     `json:"ips_unknown" db:"ips_unknown"`
+	DomainsClean   int64     `json:"domains_clean" db:"domains_clean"`
+	DomainsDirty   int64     `json:"domains_dirty" db:"domains_dirty"`
+	DomainsUnknown int64     `json:"domains_unknown" db:"domains_unknown"`
 }
 
 // Reset all the counters
@@ -36,6 +39,9 @@ func (s *Statistics) Reset() {
 	s.IPsClean = 0
 	s.IPsDirty = 0
 	s.IPsUnknown = 0
+	s.DomainsClean = 0
+	s.DomainsDirty = 0
+	s.DomainsUnknown = 0
 }
 
 // HasSomething that is not 0 in the statistics
@@ -52,5 +58,50 @@ func (s *Statistics) HasSomething() bool {
 		s.HashesUnknown != 0 ||
 		s.IPsClean != 0 ||
 		s.IPsDirty != 0 ||
-		s.IPsUnknown != 0
+		s.IPsUnknown != 0 ||
+		s.DomainsClean != 0 ||
+		s.DomainsDirty != 0 ||
+		s.DomainsUnknown != 0
+}
+
+// Add the counters of the other statistics
+func (s *Statistics) Add(o *Statistics) {
+	s.Messages += o.Messages
+	s.FilesClean += o.FilesClean
+	s.FilesDirty += o.FilesDirty
+	s.FilesUnknown += o.FilesUnknown
+	s.URLsClean += o.URLsClean
+	s.URLsDirty += o.URLsDirty
+	s.URLsUnknown += o.URLsUnknown
+	s.HashesClean += o.HashesClean
+	s.HashesDirty += o.HashesDirty
+	s.HashesUnknown += o.HashesUnknown
+	s.IPsClean += o.IPsClean
+	s.IPsDirty += o.IPsDirty
+	s.IPsUnknown += o.IPsUnknown
+	s.DomainsClean += o.DomainsClean
+	s.DomainsDirty += o.DomainsDirty
+	s.DomainsUnknown += o.DomainsUnknown
+}
+
+const (
+	// StatsHour buckets the statistics by hour
+	StatsHour = "hour"
+	// StatsDay buckets the statistics by day
+	StatsDay = "day"
+	// StatsWeek buckets the statistics by week starting on Monday
+	StatsWeek = "week"
+)
+
+// StatsBucket is the start of the bucket of the interval the time falls in. Buckets are in UTC.
+func StatsBucket(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case StatsHour:
+		return t.Truncate(time.Hour)
+	case StatsWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
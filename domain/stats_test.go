@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsBucket(t *testing.T) {
+	// A Wednesday afternoon
+	ts := time.Date(2020, 1, 8, 15, 42, 7, 0, time.UTC)
+	for interval, expected := range map[string]time.Time{
+		StatsHour: time.Date(2020, 1, 8, 15, 0, 0, 0, time.UTC),
+		StatsDay:  time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC),
+		StatsWeek: time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+	} {
+		if bucket := StatsBucket(ts, interval); !bucket.Equal(expected) {
+			t.Errorf("Expected %v bucket %v but got %v", interval, expected, bucket)
+		}
+	}
+	// Sunday belongs to the week that started on Monday
+	if bucket := StatsBucket(time.Date(2020, 1, 12, 23, 0, 0, 0, time.UTC), StatsWeek); !bucket.Equal(time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected week bucket %v", bucket)
+	}
+}
+
+func TestStatisticsAdd(t *testing.T) {
+	s := &Statistics{Messages: 1, URLsDirty: 2}
+	s.Add(&Statistics{Messages: 3, URLsDirty: 1, IPsClean: 5, DomainsUnknown: 2})
+	if s.Messages != 4 || s.URLsDirty != 3 || s.IPsClean != 5 || s.DomainsUnknown != 2 {
+		t.Errorf("Unexpected sum %+v", s)
+	}
+}
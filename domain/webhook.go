@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// Webhook of a team that receives its convictions signed with the secret
+type Webhook struct {
+	ID      int64     `json:"id"`
+	Team    string    `json:"-"`
+	URL     string    `json:"url"`
+	Secret  string    `json:"-"`
+	User    string    `json:"user"`
+	Created time.Time `json:"created" db:"ts"`
+}
+
+const (
+	// DeliveryPending deliveries are waiting for their next attempt
+	DeliveryPending = "pending"
+	// DeliveryDelivered deliveries were accepted by the webhook
+	DeliveryDelivered = "delivered"
+	// DeliveryFailed deliveries ran out of attempts
+	DeliveryFailed = "failed"
+)
+
+// EventConvicted is the event type of convictions sent to the webhooks
+const EventConvicted = "convicted"
+
+// WebhookDelivery of a single event to a webhook
+type WebhookDelivery struct {
+	ID          int64      `json:"id"`
+	Webhook     int64      `json:"webhook"`
+	URL         string     `json:"url"`
+	Secret      string     `json:"-"`
+	Team        string     `json:"-"`
+	EventType   string     `json:"event_type" db:"event_type"`
+	Event       string     `json:"event"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error" db:"last_error"`
+	NextAttempt *time.Time `json:"next_attempt" db:"next_attempt"`
+	Created     time.Time  `json:"created" db:"ts"`
+	Delivered   *time.Time `json:"delivered"`
+}
+
+// ConvictedEvent is posted to the webhooks of the team when content is convicted
+type ConvictedEvent struct {
+	Event     string            `json:"event"`
+	TeamID    string            `json:"team_id"` // The Slack ID of the team
+	Convicted *MaliciousContent `json:"convicted"`
+}
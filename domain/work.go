@@ -6,6 +6,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/demisto/alfred/autofocus"
+	"github.com/demisto/alfred/conf"
 	"github.com/demisto/alfred/util"
 	"github.com/demisto/goxforce"
 	"github.com/demisto/infinigo"
@@ -19,16 +20,26 @@ type Context struct {
 	OriginalUser string `json:"original_user"`
 	Channel      string `json:"channel"`
 	Type         string `json:"type"`
+	Thread       string `json:"thread,omitempty"`
+	Action       string `json:"action,omitempty"`       // The button that triggered the request
+	ResponseURL  string `json:"response_url,omitempty"` // Where to send the reply to a slash command
 }
 
 // contextFromMap ...
 func contextFromMap(c map[string]interface{}) *Context {
+	// Messages pushed by older versions have no thread, action or response URL
+	thread, _ := c["thread"].(string)
+	action, _ := c["action"].(string)
+	responseURL, _ := c["response_url"].(string)
 	return &Context{
 		Team:         c["team"].(string),
 		User:         c["user"].(string),
 		OriginalUser: c["original_user"].(string),
 		Channel:      c["channel"].(string),
 		Type:         c["type"].(string),
+		Thread:       thread,
+		Action:       action,
+		ResponseURL:  responseURL,
 	}
 }
 
@@ -57,22 +68,41 @@ type File struct {
 
 // WorkRequest contains the relevant fields for a work request
 type WorkRequest struct {
-	MessageID  string      `json:"message_id"`
-	Type       string      `json:"type"`
-	Text       string      `json:"text"`
-	File       File        `json:"file"`
-	ReplyQueue string      `json:"reply_queue"`
-	Context    interface{} `json:"context"`
-	Online     bool        `json:"online"`   // Are we running this request from online details page
-	VTKey      string      `json:"vt_key"`   // This team has his own vt key
-	XFEKey     string      `json:"xfe_key"`  // This team has his own xfe key
-	XFEPass    string      `json:"xfe_pass"` // This team has his own xfe pass
-	AFKey      string      `json:"af_key"`   // This team has his own AutoFocus key pass
-}
-
-// WorkRequestFromMessage converts a message to a work request
-func WorkRequestFromMessage(msg util.Object, token, vtKey, xfeKey, xfePass, afKey string) *WorkRequest {
-	req := &WorkRequest{VTKey: vtKey, XFEKey: xfeKey, XFEPass: xfePass, AFKey: afKey}
+	MessageID  string                `json:"message_id"`
+	Team       string                `json:"team"` // The team ID for the team intel
+	Type       string                `json:"type"`
+	Text       string                `json:"text"`
+	File       File                  `json:"file"`
+	ReplyQueue string                `json:"reply_queue"`
+	Context    interface{}           `json:"context"`
+	Online     bool                  `json:"online"`    // Are we running this request from online details page
+	VTKey      string                `json:"vt_key"`    // This team has his own vt key
+	XFEKey     string                `json:"xfe_key"`   // This team has his own xfe key
+	XFEPass    string                `json:"xfe_pass"`  // This team has his own xfe pass
+	AFKey      string                `json:"af_key"`    // This team has his own AutoFocus key pass
+	Providers  []string              `json:"providers"` // The reputation providers enabled for this team - all if empty
+	Policy     *conf.VerdictPolicies `json:"policy"`    // The verdict policies of this team - defaults if nil
+	Priority   int                   `json:"priority"`  // PriorityHigh for requests someone is waiting on
+	Rescan     bool                  `json:"rescan"`    // Query the providers even if we have cached results
+	Receipt    string                `json:"-"`         // Set by the queue on delivery to ack or nack the request
+}
+
+// NewWorkRequest for the team with its own keys and its providers and policy
+func NewWorkRequest(team *Team, configuration *Configuration) *WorkRequest {
+	return &WorkRequest{
+		Team:      team.ID,
+		VTKey:     team.VTKey,
+		XFEKey:    team.XFEKey,
+		XFEPass:   team.XFEPass,
+		AFKey:     team.AFKey,
+		Providers: configuration.Providers,
+		Policy:    configuration.Policy,
+	}
+}
+
+// WorkRequestFromMessage converts a message of the team to a work request
+func WorkRequestFromMessage(msg util.Object, team *Team, configuration *Configuration) *WorkRequest {
+	req, token := NewWorkRequest(team, configuration), team.BotToken
 	switch msg.S("type") {
 	case "message":
 		switch msg.S("subtype") {
@@ -110,6 +140,13 @@ func WorkRequestFromMessage(msg util.Object, token, vtKey, xfeKey, xfePass, afKe
 	return req
 }
 
+const (
+	// PriorityNormal for channel traffic
+	PriorityNormal int = iota
+	// PriorityHigh for the details page and direct messages - they are handled before normal work
+	PriorityHigh
+)
+
 const (
 	// ReplyTypeHash for hash replies
 	ReplyTypeHash int = 1 << iota
@@ -119,6 +156,8 @@ const (
 	ReplyTypeIP
 	// ReplyTypeFile for File replies
 	ReplyTypeFile
+	// ReplyTypeDomain for domain replies
+	ReplyTypeDomain
 )
 
 const (
@@ -130,6 +169,29 @@ const (
 	ResultUnknown
 )
 
+const (
+	// RuleAllowlist prefixes the rule of indicators on the team allowlist - the rest of the rule is the matching entry
+	RuleAllowlist = "allowlist:"
+	// RuleBlocklist prefixes the rule of indicators on the team blocklist - the rest of the rule is the matching entry
+	RuleBlocklist = "blocklist:"
+	// ProviderTeamIntel is the provider result of indicators on the team blocklist
+	ProviderTeamIntel = "intel"
+	// RuleSTIX prefixes the rule of indicators found in the team STIX intel - the rest of the rule is the STIX ID
+	RuleSTIX = "stix:"
+	// ProviderSTIX is the provider result of indicators found in the team STIX intel
+	ProviderSTIX = "stix"
+)
+
+// ProviderResult holds the answer of a single reputation provider for an indicator
+type ProviderResult struct {
+	Result  int         `json:"result"`            // The provider view - ResultClean, ResultDirty or ResultUnknown
+	Score   float64     `json:"score"`             // Provider specific score where higher is worse
+	Summary string      `json:"summary"`           // Human readable summary of the result
+	Link    string      `json:"link"`              // Link to the provider page about the indicator
+	Error   string      `json:"error"`             // Error if the lookup failed
+	Details interface{} `json:"details,omitempty"` // Raw details for providers without a dedicated reply section
+}
+
 // XfeHashReply ...
 type XfeHashReply struct {
 	NotFound bool             `json:"notFound"`
@@ -157,12 +219,15 @@ type AFHashReply struct {
 
 // HashReply holds the information about a hash
 type HashReply struct {
-	Details string       `json:"details"`
-	Result  int          `json:"result"`
-	XFE     XfeHashReply `json:"xfe"`
-	VT      VtHashReply  `json:"vt"`
-	Cy      CyHashReply  `json:"cy"`
-	AF      AFHashReply  `json:"af"`
+	Details   string                    `json:"details"`
+	Original  string                    `json:"original"` // What the user actually typed
+	Result    int                       `json:"result"`
+	Rule      string                    `json:"rule"` // The verdict rule that produced the result
+	XFE       XfeHashReply              `json:"xfe"`
+	VT        VtHashReply               `json:"vt"`
+	Cy        CyHashReply               `json:"cy"`
+	AF        AFHashReply               `json:"af"`
+	Providers map[string]ProviderResult `json:"providers"`
 }
 
 type XfeURLReply struct {
@@ -180,10 +245,13 @@ type VtURLReply struct {
 
 // URLReply holds the information about a URL
 type URLReply struct {
-	Details string      `json:"details"`
-	Result  int         `json:"result"`
-	XFE     XfeURLReply `json:"xfe"`
-	VT      VtURLReply  `json:"vt"`
+	Details   string                    `json:"details"`
+	Original  string                    `json:"original"` // What the user actually typed
+	Result    int                       `json:"result"`
+	Rule      string                    `json:"rule"` // The verdict rule that produced the result
+	XFE       XfeURLReply               `json:"xfe"`
+	VT        VtURLReply                `json:"vt"`
+	Providers map[string]ProviderResult `json:"providers"`
 }
 
 // XfeIPReply ...
@@ -202,16 +270,45 @@ type VtIPReply struct {
 
 // IPReply holds the information about an IP
 type IPReply struct {
-	Details string     `json:"details"`
-	Result  int        `json:"result"`
-	Private bool       `json:"isPrivate"`
-	XFE     XfeIPReply `json:"xfe"`
-	VT      VtIPReply  `json:"vt"`
+	Details   string                    `json:"details"`
+	Original  string                    `json:"original"` // What the user actually typed
+	Result    int                       `json:"result"`
+	Rule      string                    `json:"rule"` // The verdict rule that produced the result
+	Private   bool                      `json:"isPrivate"`
+	XFE       XfeIPReply                `json:"xfe"`
+	VT        VtIPReply                 `json:"vt"`
+	Providers map[string]ProviderResult `json:"providers"`
+}
+
+// XfeDomainReply ...
+type XfeDomainReply struct {
+	NotFound   bool                 `json:"notFound"`
+	Error      string               `json:"error"`
+	Resolve    goxforce.ResolveResp `json:"resolve"`
+	URLDetails goxforce.URL         `json:"urlDetails"`
+}
+
+// VtDomainReply ...
+type VtDomainReply struct {
+	Error        string            `json:"error"`
+	DomainReport govt.DomainReport `json:"domainReport"`
+}
+
+// DomainReply holds the information about a domain
+type DomainReply struct {
+	Details   string                    `json:"details"`
+	Original  string                    `json:"original"` // What the user actually typed
+	Result    int                       `json:"result"`
+	Rule      string                    `json:"rule"` // The verdict rule that produced the result
+	XFE       XfeDomainReply            `json:"xfe"`
+	VT        VtDomainReply             `json:"vt"`
+	Providers map[string]ProviderResult `json:"providers"`
 }
 
 // FileReply holds the information about a File
 type FileReply struct {
 	Result       int    `json:"result"`
+	Rule         string `json:"rule"` // The verdict rule that produced the result
 	FileTooLarge bool   `json:"file_too_large"`
 	Virus        string `json:"virus"`
 	Error        string `json:"error"`
@@ -220,28 +317,31 @@ type FileReply struct {
 
 // WorkReply to a work request being done
 type WorkReply struct {
-	Type      int         `json:"type"`
-	MessageID string      `json:"message_id"`
-	Hashes    []HashReply `json:"hashes"`
-	URLs      []URLReply  `json:"urls"`
-	IPs       []IPReply   `json:"ips"`
-	File      FileReply   `json:"file"`
-	Context   interface{} `json:"context"`
+	Type      int           `json:"type"`
+	MessageID string        `json:"message_id"`
+	Hashes    []HashReply   `json:"hashes"`
+	URLs      []URLReply    `json:"urls"`
+	IPs       []IPReply     `json:"ips"`
+	Domains   []DomainReply `json:"domains"`
+	File      FileReply     `json:"file"`
+	Context   interface{}   `json:"context"`
 }
 
 // MaliciousContent holds info about convicted content
 type MaliciousContent struct {
-	Team        string `json:"team"`
-	Channel     string `json:"channel"`
-	MessageID   string `json:"message_id" db:"message_id"`
-	ContentType int    `json:"content_type" db:"content_type"`
-	Content     string `json:"content"`
-	FileName    string `json:"file_name" db:"file_name"`
-	VT          string `json:"vt"`
-	XFE         string `json:"xfe"`
-	Cy          string `json:"cy"`
-	ClamAV      string `json:"clamav"`
-	AF          string `json:"af"`
+	Team        string    `json:"team"`
+	Channel     string    `json:"channel"`
+	MessageID   string    `json:"message_id" db:"message_id"`
+	ContentType int       `json:"content_type" db:"content_type"`
+	Content     string    `json:"content"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	VT          string    `json:"vt"`
+	XFE         string    `json:"xfe"`
+	Cy          string    `json:"cy"`
+	ClamAV      string    `json:"clamav"`
+	AF          string    `json:"af"`
+	Intel       string    `json:"intel"`
+	Timestamp   time.Time `json:"ts" db:"ts"` // When it was convicted - set by the repository
 }
 
 // UniqueID of the message
@@ -249,6 +349,20 @@ func (mc *MaliciousContent) UniqueID() string {
 	return mc.Team + "," + mc.Channel + "," + mc.MessageID
 }
 
+// ConvictedProviders are the columns of MaliciousContent the convictions can be filtered by
+var ConvictedProviders = []string{"vt", "xfe", "clamav", "cy", "af", ProviderTeamIntel}
+
+// ConvictedQuery filters the convicted content of a team - zero values do not filter
+type ConvictedQuery struct {
+	Channel     string
+	ContentType int       // One of the ReplyType values
+	Provider    string    // One of ConvictedProviders - only content with a score from the provider
+	From        time.Time // Convicted at or after
+	To          time.Time // Convicted before
+	Offset      int
+	Limit       int // All of the matching content if 0
+}
+
 // DBQueueMessage holds a message passed via the database
 type DBQueueMessage struct {
 	ID          int64     `json:"id"`
@@ -256,4 +370,49 @@ type DBQueueMessage struct {
 	MessageType string    `json:"message_type" db:"message_type"`
 	Message     string    `json:"message"`
 	Timestamp   time.Time `json:"ts" db:"ts"`
+	Retries     int       `json:"retries"` // How many times the message was retried
+	Priority    int       `json:"priority"`
+}
+
+// DeadLetter holds a queue message that could not be handled
+type DeadLetter struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	MessageType string    `json:"message_type" db:"message_type"`
+	Message     string    `json:"message"`
+	Retries     int       `json:"retries"`
+	Reason      string    `json:"reason"`
+	Timestamp   time.Time `json:"ts" db:"ts"`
+}
+
+// BotReply maps a Slack message to the reply the bot posted for it so edits update the same reply
+type BotReply struct {
+	Team      string    `json:"team"`
+	Channel   string    `json:"channel"`
+	MessageID string    `json:"message_id" db:"message_id"`
+	ReplyTS   string    `json:"reply_ts" db:"reply_ts"`
+	Timestamp time.Time `json:"ts" db:"ts"`
+}
+
+// Feedback of a user on a verdict we gave
+type Feedback struct {
+	ID            int64     `json:"id"`
+	Team          string    `json:"team"`
+	User          string    `json:"user"`
+	Channel       string    `json:"channel"`
+	MessageID     string    `json:"message_id" db:"message_id"`
+	Indicator     string    `json:"indicator"`
+	IndicatorType string    `json:"indicator_type" db:"indicator_type"`
+	Kind          string    `json:"kind"`
+	Timestamp     time.Time `json:"ts" db:"ts"`
+}
+
+// CachedResult holds the result of a reputation provider for an indicator shared by all workers
+type CachedResult struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Indicator string    `json:"indicator"`
+	Online    bool      `json:"online"` // Was the lookup done with the online details
+	Result    string    `json:"result"` // The provider result and reply section in JSON
+	Expires   time.Time `json:"expires"`
 }
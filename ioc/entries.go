@@ -0,0 +1,129 @@
+package ioc
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidEntry is returned for allowlist and blocklist entries that are not an indicator, a domain suffix or a CIDR
+var ErrInvalidEntry = errors.New("entry must be a single indicator, a domain suffix like *.example.com or a CIDR")
+
+// unlink replaces the Slack links in the text with what the user typed - the domain label or the link target
+func unlink(text string) string {
+	return linkReg.ReplaceAllStringFunc(text, func(link string) string {
+		parts := linkReg.FindStringSubmatch(link)
+		if isDomain(parts[2]) {
+			return parts[2]
+		}
+		return parts[1]
+	})
+}
+
+// ParseEntry normalizes an allowlist or blocklist entry. Entries are either a single indicator (URL, IP, domain or hash),
+// a domain suffix written as *.example.com or .example.com, or a CIDR like 10.1.0.0/16. Domains and suffixes can be
+// internal ones like *.corp.local without a public TLD.
+// Entries can be defanged or formatted as Slack links.
+func ParseEntry(entry string) (string, error) {
+	entry = strings.TrimSpace(unlink(Refang(entry)))
+	if _, ipnet, err := net.ParseCIDR(entry); err == nil {
+		return ipnet.String(), nil
+	}
+	if suffix := strings.TrimPrefix(strings.TrimPrefix(entry, "*"), "."); len(suffix) < len(entry) {
+		if !isHostName(suffix) {
+			return "", ErrInvalidEntry
+		}
+		return "." + strings.ToLower(suffix), nil
+	}
+	if indicators := Extract(entry); len(indicators) == 1 && indicators[0].Original == entry {
+		return indicators[0].Value, nil
+	}
+	// Internal hosts like intranet.corp.local are not extracted from text without a known TLD but can still be listed
+	if strings.Contains(entry, ".") && net.ParseIP(entry) == nil && isHostName(entry) {
+		return strings.ToLower(entry), nil
+	}
+	return "", ErrInvalidEntry
+}
+
+// isHostName checks the syntax of the host name. Unlike the extraction it does not require a known TLD.
+func isHostName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			if !isLabelChar(label[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// host of the indicator for domains, IPs and URLs - empty for hashes
+func host(indicator Indicator) string {
+	switch indicator.Type {
+	case Domain, IP:
+		return strings.ToLower(indicator.Value)
+	case URL:
+		if u, err := url.Parse(indicator.Value); err == nil {
+			return strings.ToLower(u.Hostname())
+		}
+	}
+	return ""
+}
+
+// Entry is an allowlist or blocklist entry with its network parsed once so it can be matched against many indicators
+type Entry struct {
+	Value   string
+	network *net.IPNet
+}
+
+// NewEntry from an entry normalized by ParseEntry
+func NewEntry(value string) Entry {
+	e := Entry{Value: value}
+	if _, ipnet, err := net.ParseCIDR(value); err == nil {
+		e.network = ipnet
+	}
+	return e
+}
+
+// Matches checks if the entry matches the indicator.
+// URLs match by their value or by their host so listing a domain or a network lists the URLs on it as well.
+func (e Entry) Matches(indicator Indicator) bool {
+	if strings.EqualFold(e.Value, indicator.Value) {
+		return true
+	}
+	h := host(indicator)
+	switch {
+	case h == "":
+		return false
+	case strings.HasPrefix(e.Value, "."):
+		return h == e.Value[1:] || strings.HasSuffix(h, e.Value)
+	case e.network != nil:
+		ip := net.ParseIP(h)
+		return ip != nil && e.network.Contains(ip)
+	case strings.Contains(e.Value, "/"):
+		return false
+	}
+	return strings.EqualFold(e.Value, h)
+}
+
+// Matches checks if the entry matches the indicator
+func Matches(entry string, indicator Indicator) bool {
+	return NewEntry(entry).Matches(indicator)
+}
+
+// Match returns the first entry that matches the indicator
+func Match(entries []string, indicator Indicator) (string, bool) {
+	for _, entry := range entries {
+		if Matches(entry, indicator) {
+			return entry, true
+		}
+	}
+	return "", false
+}
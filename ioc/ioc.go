@@ -0,0 +1,296 @@
+// Package ioc extracts indicators of compromise from chat messages.
+// Indicators are often pasted defanged (hxxp://evil[.]com) so the text is refanged before extraction while keeping
+// track of what the user actually typed.
+package ioc
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Type of the indicator
+type Type int
+
+const (
+	// URL with http or https scheme
+	URL Type = iota
+	// IP address - IPv4 or IPv6
+	IP
+	// Domain name
+	Domain
+	// Hash is an MD5, SHA1 or SHA256 hash
+	Hash
+)
+
+// Indicator found in the text
+type Indicator struct {
+	Type     Type
+	Value    string // The refanged and normalized value
+	Original string // What the user actually typed
+	Start    int    // Start of the indicator in the original text
+	End      int    // End of the indicator in the original text
+}
+
+var (
+	linkReg   = regexp.MustCompile(`<([^<>|]*)(?:\|([^<>]*))?>`)
+	urlReg    = regexp.MustCompile("(?i)\\bhttps?://[^\\s<>\"'`]+")
+	ipv4Reg   = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
+	ipv6Reg   = regexp.MustCompile(`(?i)[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}(?:\.\d{1,3}){0,3}`)
+	md5Reg    = regexp.MustCompile(`\b[a-fA-F\d]{32}\b`)
+	sha1Reg   = regexp.MustCompile(`\b[a-fA-F\d]{40}\b`)
+	sha256Reg = regexp.MustCompile(`\b[a-fA-F\d]{64}\b`)
+	domainReg = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,24}\b`)
+)
+
+// refangs are the defanged forms we understand - longer forms first.
+// Words like dot and at are common in plain text so they are only refanged between host name characters
+// and hxxp only as the scheme of a URL.
+var refangs = []struct {
+	from, to string
+	label    bool // Only between host name characters
+	scheme   bool // Only at the start of a word and followed by ://
+}{
+	{from: "hxxps", to: "https", scheme: true},
+	{from: "hxxp", to: "http", scheme: true},
+	{from: "[://]", to: "://"},
+	{from: "[:]", to: ":"},
+	{from: "[/]", to: "/"},
+	{from: "[.]", to: "."},
+	{from: "(.)", to: "."},
+	{from: "{.}", to: "."},
+	{from: "[dot]", to: ".", label: true},
+	{from: "(dot)", to: ".", label: true},
+	{from: "{dot}", to: ".", label: true},
+	{from: "[@]", to: "@"},
+	{from: "[at]", to: "@", label: true},
+	{from: "(at)", to: "@", label: true},
+}
+
+// tlds we consider when extracting domains from plain text. Without the filter every file name like
+// report.pdf would be a domain. Extensions that are also popular TLDs (like .zip) are deliberately left out.
+var tlds = map[string]bool{
+	"com": true, "net": true, "org": true, "info": true, "biz": true, "name": true, "pro": true, "mobi": true,
+	"edu": true, "gov": true, "mil": true, "int": true, "io": true, "co": true, "me": true, "tv": true,
+	"cc": true, "ws": true, "pw": true, "su": true, "ru": true, "ua": true, "by": true, "kz": true,
+	"cn": true, "hk": true, "tw": true, "jp": true, "kr": true, "in": true, "id": true, "vn": true,
+	"th": true, "my": true, "sg": true, "ph": true, "au": true, "nz": true, "us": true, "ca": true,
+	"mx": true, "br": true, "ar": true, "cl": true, "uk": true, "de": true, "fr": true, "nl": true,
+	"be": true, "ch": true, "at": true, "it": true, "es": true, "pt": true, "pl": true, "cz": true,
+	"sk": true, "hu": true, "ro": true, "bg": true, "gr": true, "tr": true, "ir": true, "il": true,
+	"se": true, "no": true, "dk": true, "fi": true, "ie": true, "eu": true, "za": true, "ng": true,
+	"tk": true, "ml": true, "ga": true, "cf": true, "gq": true, "xyz": true, "top": true, "club": true,
+	"online": true, "site": true, "website": true, "space": true, "tech": true, "store": true, "shop": true,
+	"live": true, "icu": true, "link": true, "click": true, "work": true, "app": true, "dev": true,
+	"cloud": true, "buzz": true, "fun": true, "host": true, "press": true, "news": true, "today": true,
+}
+
+// Refang the text so defanged indicators can be extracted
+func Refang(text string) string {
+	refanged, _ := refang(text)
+	return refanged
+}
+
+// refang returns the refanged text and for each byte of it the position in the original text.
+// The extra last position is the length of the original text.
+func refang(text string) (string, []int) {
+	buf := make([]byte, 0, len(text))
+	pos := make([]int, 0, len(text)+1)
+	for i := 0; i < len(text); {
+		matched := false
+		for _, r := range refangs {
+			end := i + len(r.from)
+			if end > len(text) || !strings.EqualFold(text[i:end], r.from) {
+				continue
+			}
+			if r.label && (i == 0 || end == len(text) || !isLabelChar(text[i-1]) || !isLabelChar(text[end])) {
+				continue
+			}
+			if r.scheme && (i > 0 && isWordChar(text[i-1]) || !hasSchemeSeparator(text[end:])) {
+				continue
+			}
+			for j := 0; j < len(r.to); j++ {
+				buf = append(buf, r.to[j])
+				pos = append(pos, i)
+			}
+			i = end
+			matched = true
+			break
+		}
+		if !matched {
+			buf = append(buf, text[i])
+			pos = append(pos, i)
+			i++
+		}
+	}
+	return string(buf), append(pos, len(text))
+}
+
+// Defang the indicator so chat clients do not turn it into a link.
+// It handles URLs, domains, emails and both IPv4 and IPv6 addresses.
+func Defang(indicator string) string {
+	if ip := net.ParseIP(indicator); ip != nil && ip.To4() == nil {
+		return strings.Replace(indicator, ":", "[:]", -1)
+	}
+	res := strings.Replace(indicator, "://", "[://]", 1)
+	res = strings.Replace(res, ".", "[.]", -1)
+	return strings.Replace(res, "@", "[@]", -1)
+}
+
+func hasKnownTLD(name string) bool {
+	return tlds[strings.ToLower(name[strings.LastIndex(name, ".")+1:])]
+}
+
+// isDomain checks if the whole text is a domain with a known TLD
+func isDomain(text string) bool {
+	loc := domainReg.FindStringIndex(text)
+	return loc != nil && loc[0] == 0 && loc[1] == len(text) && hasKnownTLD(text)
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// isLabelChar checks if the character can be part of a host name label
+func isLabelChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
+
+// hasSchemeSeparator checks if the text starts with :// in any of the forms we refang
+func hasSchemeSeparator(text string) bool {
+	return strings.HasPrefix(text, "://") || strings.HasPrefix(text, "[://]") || strings.HasPrefix(text, "[:]//")
+}
+
+// extractor collects the indicators from the refanged text, masking what was already found
+type extractor struct {
+	original string
+	text     []byte
+	pos      []int
+	seen     map[Type]map[string]bool
+	res      []Indicator
+}
+
+func (e *extractor) add(t Type, value string, start, end int, original string) {
+	if e.seen[t] == nil {
+		e.seen[t] = make(map[string]bool)
+	}
+	if !e.seen[t][value] {
+		e.seen[t][value] = true
+		if original == "" {
+			original = e.original[e.pos[start]:e.pos[end]]
+		}
+		e.res = append(e.res, Indicator{Type: t, Value: value, Original: original, Start: e.pos[start], End: e.pos[end]})
+	}
+	e.mask(start, end)
+}
+
+// mask the part of the text so it will not be extracted again as a different indicator
+func (e *extractor) mask(start, end int) {
+	for i := start; i < end; i++ {
+		e.text[i] = ' '
+	}
+}
+
+func (e *extractor) links() {
+	for _, loc := range linkReg.FindAllSubmatchIndex(e.text, -1) {
+		target := string(e.text[loc[2]:loc[3]])
+		label := ""
+		if loc[4] >= 0 {
+			label = string(e.text[loc[4]:loc[5]])
+		}
+		lower := strings.ToLower(target)
+		switch {
+		case !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://"):
+			// Channels, users, emails, etc.
+			e.mask(loc[0], loc[1])
+		case isDomain(label):
+			// Slack turns domains written in plain text into links with the domain as the label
+			e.add(Domain, strings.ToLower(label), loc[0], loc[1], e.original[e.pos[loc[4]]:e.pos[loc[5]]])
+		case label != "":
+			e.add(URL, target, loc[0], loc[1], e.original[e.pos[loc[4]]:e.pos[loc[5]]])
+		default:
+			e.add(URL, target, loc[0], loc[1], e.original[e.pos[loc[2]]:e.pos[loc[3]]])
+		}
+	}
+}
+
+func (e *extractor) urls() {
+	for _, loc := range urlReg.FindAllIndex(e.text, -1) {
+		end := loc[1]
+		for end > loc[0] && strings.ContainsRune(".,;:!?)]}", rune(e.text[end-1])) {
+			end--
+		}
+		e.add(URL, string(e.text[loc[0]:end]), loc[0], end, "")
+	}
+}
+
+func (e *extractor) ips() {
+	for _, loc := range ipv4Reg.FindAllIndex(e.text, -1) {
+		if ip := net.ParseIP(string(e.text[loc[0]:loc[1]])); ip != nil {
+			e.add(IP, ip.String(), loc[0], loc[1], "")
+		}
+	}
+	for _, loc := range ipv6Reg.FindAllIndex(e.text, -1) {
+		// Go regexp has no look behind so make sure the candidate is not part of a word like :smile::heart:
+		if loc[0] > 0 && isWordChar(e.text[loc[0]-1]) || loc[1] < len(e.text) && isWordChar(e.text[loc[1]]) {
+			continue
+		}
+		end := loc[1]
+		for end > loc[0] && e.text[end-1] == '.' {
+			end--
+		}
+		ip := net.ParseIP(string(e.text[loc[0]:end]))
+		// IPv4 mapped addresses are already handled as IPv4
+		if ip == nil || ip.To4() != nil {
+			continue
+		}
+		e.add(IP, ip.String(), loc[0], end, "")
+	}
+}
+
+func (e *extractor) hashes() {
+	for _, reg := range []*regexp.Regexp{sha256Reg, sha1Reg, md5Reg} {
+		for _, loc := range reg.FindAllIndex(e.text, -1) {
+			e.add(Hash, strings.ToLower(string(e.text[loc[0]:loc[1]])), loc[0], loc[1], "")
+		}
+	}
+}
+
+func (e *extractor) domains() {
+	for _, loc := range domainReg.FindAllIndex(e.text, -1) {
+		// Skip the domain part of email addresses
+		if loc[0] > 0 && e.text[loc[0]-1] == '@' {
+			continue
+		}
+		name := strings.ToLower(string(e.text[loc[0]:loc[1]]))
+		if hasKnownTLD(name) {
+			e.add(Domain, name, loc[0], loc[1], "")
+		}
+	}
+}
+
+// Extract the indicators from the text ordered by their position.
+// Each indicator appears once even if it was mentioned several times.
+func Extract(text string) []Indicator {
+	refanged, pos := refang(text)
+	e := &extractor{original: text, text: []byte(refanged), pos: pos, seen: make(map[Type]map[string]bool)}
+	e.links()
+	e.urls()
+	e.ips()
+	e.hashes()
+	e.domains()
+	sort.SliceStable(e.res, func(i, j int) bool { return e.res[i].Start < e.res[j].Start })
+	return e.res
+}
+
+// Filter the indicators of the given type
+func Filter(indicators []Indicator, t Type) []Indicator {
+	var res []Indicator
+	for _, indicator := range indicators {
+		if indicator.Type == t {
+			res = append(res, indicator)
+		}
+	}
+	return res
+}
@@ -0,0 +1,143 @@
+package ioc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func values(indicators []Indicator) []string {
+	var res []string
+	for _, indicator := range indicators {
+		res = append(res, indicator.Value)
+	}
+	return res
+}
+
+func TestRefang(t *testing.T) {
+	if res := Refang("hXXps[://]evil[.]com and 1.2.3[.]4 and evil(dot)com"); res != "https://evil.com and 1.2.3.4 and evil.com" {
+		t.Errorf("Wrong refang - %s", res)
+	}
+	for _, text := range []string{"meet (at) 5 (dot) points", "the hxxp scheme and (AT)", "hxxpd://x and ahxxp://x"} {
+		if res := Refang(text); res != text {
+			t.Errorf("Expected prose to stay the same but got %s", res)
+		}
+	}
+	if res := Refang("kuku(at)evil[dot]com"); res != "kuku@evil.com" {
+		t.Errorf("Wrong refang - %s", res)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	text := "see hxxp://evil[.]com/path, <http://good.com/a|good.com/a> and <http://site.ru|site.ru> from 1.2.3[.]4"
+	expected := []struct {
+		t        Type
+		value    string
+		original string
+		span     string
+	}{
+		{URL, "http://evil.com/path", "hxxp://evil[.]com/path", "hxxp://evil[.]com/path"},
+		{URL, "http://good.com/a", "good.com/a", "<http://good.com/a|good.com/a>"},
+		{Domain, "site.ru", "site.ru", "<http://site.ru|site.ru>"},
+		{IP, "1.2.3.4", "1.2.3[.]4", "1.2.3[.]4"},
+	}
+	indicators := Extract(text)
+	if len(indicators) != len(expected) {
+		t.Fatalf("Expected %d indicators but got %+v", len(expected), indicators)
+	}
+	for i, e := range expected {
+		indicator := indicators[i]
+		if indicator.Type != e.t || indicator.Value != e.value || indicator.Original != e.original || text[indicator.Start:indicator.End] != e.span {
+			t.Errorf("Expected %+v but got %+v", e, indicator)
+		}
+	}
+}
+
+func TestExtractTypes(t *testing.T) {
+	tests := []struct {
+		text   string
+		t      Type
+		values []string
+	}{
+		{"check evil-site.ru and evil(dot)site and sub{.}Example[.]com twice example.com", Domain, []string{"evil-site.ru", "evil.site", "sub.example.com", "example.com"}},
+		{"mail <mailto:kuku@example.com|kuku@example.com> and kuku@example.com", Domain, nil},
+		{"files report.pdf and setup.exe", Domain, nil},
+		{"v4 8.8.8.8 and 999.1.1.1 and 8.8.8.8", IP, []string{"8.8.8.8"}},
+		{"v6 2001:4860:4860::8888 and 2001:4860:4860:0:0:0:0:8888.", IP, []string{"2001:4860:4860::8888"}},
+		{"mapped ::ffff:1.1.1.1 and fe80::1", IP, []string{"1.1.1.1", "fe80::1"}},
+		{"not IPs :smile::heart: at 12:30:45", IP, nil},
+		{"md5 D41D8CD98F00B204E9800998ECF8427E and d41d8cd98f00b204e9800998ecf8427e", Hash, []string{"d41d8cd98f00b204e9800998ecf8427e"}},
+		{"<http://1.2.3.4/x> has no separate IP", IP, nil},
+	}
+	for _, test := range tests {
+		if res := values(Filter(Extract(test.text), test.t)); !reflect.DeepEqual(res, test.values) {
+			t.Errorf("Extracting from %q expected %v but got %v", test.text, test.values, res)
+		}
+	}
+}
+
+func TestDefang(t *testing.T) {
+	tests := map[string]string{
+		"http://evil.com/a": "http[://]evil[.]com/a",
+		"evil.com":          "evil[.]com",
+		"1.2.3.4":           "1[.]2[.]3[.]4",
+		"2001:db8::1":       "2001[:]db8[:][:]1",
+		"kuku@evil.com":     "kuku[@]evil[.]com",
+	}
+	for indicator, expected := range tests {
+		if res := Defang(indicator); res != expected {
+			t.Errorf("Defang of %s expected %s but got %s", indicator, expected, res)
+		}
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := map[string]string{
+		"Corp.example.com":                 "corp.example.com",
+		"*.Example.com":                    ".example.com",
+		".example.com":                     ".example.com",
+		"10.1.2.3/16":                      "10.1.0.0/16",
+		"hxxp://cdn[.]example.com/lib.js":  "http://cdn.example.com/lib.js",
+		"D41D8CD98F00B204E9800998ECF8427E": "d41d8cd98f00b204e9800998ecf8427e",
+		"1.2.3.4":                          "1.2.3.4",
+		"*.corp.local":                     ".corp.local",
+		"*.internal":                       ".internal",
+		".LAN":                             ".lan",
+		"Intranet.Corp.Local":              "intranet.corp.local",
+		"kuku":                             "",
+		"*.-kuku":                          "",
+		"*.ku_ku":                          "",
+		"example.com and example.net":      "",
+	}
+	for entry, expected := range tests {
+		res, err := ParseEntry(entry)
+		if expected == "" && err == nil || expected != "" && res != expected {
+			t.Errorf("Parsing %q expected %q but got %q - %v", entry, expected, res, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	allowlist := []string{".example.com", "10.1.0.0/16", "good.com", "d41d8cd98f00b204e9800998ecf8427e", ".corp.local"}
+	tests := []struct {
+		indicator Indicator
+		entry     string
+	}{
+		{Indicator{Type: Domain, Value: "example.com"}, ".example.com"},
+		{Indicator{Type: Domain, Value: "cdn.example.com"}, ".example.com"},
+		{Indicator{Type: Domain, Value: "badexample.com"}, ""},
+		{Indicator{Type: URL, Value: "https://cdn.example.com/lib.js"}, ".example.com"},
+		{Indicator{Type: URL, Value: "http://good.com/a"}, "good.com"},
+		{Indicator{Type: URL, Value: "http://10.1.5.5:8080/a"}, "10.1.0.0/16"},
+		{Indicator{Type: IP, Value: "10.1.200.1"}, "10.1.0.0/16"},
+		{Indicator{Type: IP, Value: "10.2.0.1"}, ""},
+		{Indicator{Type: Hash, Value: "d41d8cd98f00b204e9800998ecf8427e"}, "d41d8cd98f00b204e9800998ecf8427e"},
+		{Indicator{Type: Domain, Value: "evil.com"}, ""},
+		{Indicator{Type: URL, Value: "http://wiki.corp.local/page"}, ".corp.local"},
+	}
+	for _, test := range tests {
+		entry, ok := Match(allowlist, test.indicator)
+		if entry != test.entry || ok != (test.entry != "") {
+			t.Errorf("Expected %+v to match %q but got %q", test.indicator, test.entry, entry)
+		}
+	}
+}
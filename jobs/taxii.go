@@ -0,0 +1,91 @@
+// Package jobs runs the background jobs of the web tier on top of the repository - polling TAXII and delivering webhooks.
+// Every web node starts them and they coordinate through the repository so the work is not done twice.
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/repo"
+	"github.com/demisto/alfred/taxii"
+)
+
+// taxiiLock is held by the node polling the collections
+const taxiiLock = "taxii"
+
+// TAXIIPoller polls the configured TAXII collections into the team intel
+type TAXIIPoller struct {
+	r        *repo.MySQL
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+// NewTAXIIPoller that stores the polled indicators in the repository
+func NewTAXIIPoller(r *repo.MySQL) *TAXIIPoller {
+	return &TAXIIPoller{r: r, stop: make(chan bool)}
+}
+
+// Start polling every configured interval until closed - returns right away if there is nothing to poll
+func (p *TAXIIPoller) Start() {
+	if conf.Options.TAXII.Interval <= 0 || len(conf.Options.TAXII.Collections) == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(conf.Options.TAXII.Interval) * time.Minute)
+	defer ticker.Stop()
+	for {
+		p.poll()
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops the polling
+func (p *TAXIIPoller) Close() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	return nil
+}
+
+// poll all the collections unless another node is already polling them
+func (p *TAXIIPoller) poll() {
+	unlock, ok, err := p.r.Lock(taxiiLock)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to lock the TAXII poll")
+		return
+	}
+	if !ok {
+		logrus.Debug("Another node is polling the TAXII collections")
+		return
+	}
+	defer unlock()
+	for i := range conf.Options.TAXII.Collections {
+		if err := p.pollCollection(&conf.Options.TAXII.Collections[i]); err != nil {
+			logrus.WithError(err).Warnf("Unable to poll TAXII collection %s", conf.Options.TAXII.Collections[i].URL)
+		}
+	}
+}
+
+func (p *TAXIIPoller) pollCollection(c *conf.TAXIICollection) error {
+	team, err := p.r.TeamByExternalID(c.Team)
+	if err != nil {
+		return err
+	}
+	addedAfter, err := p.r.TAXIIAddedAfter(team.ID, c.URL)
+	if err != nil {
+		return err
+	}
+	client := &taxii.Client{URL: c.URL, Username: c.Username, Password: c.Password}
+	indicators, last, err := client.Poll(addedAfter)
+	if err != nil {
+		return err
+	}
+	if err = p.r.StoreIntel(team.ID, indicators); err != nil {
+		return err
+	}
+	logrus.Debugf("Polled %d indicators from TAXII collection %s", len(indicators), c.URL)
+	return p.r.SetTAXIIAddedAfter(team.ID, c.URL, last)
+}
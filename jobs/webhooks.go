@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/repo"
+	"github.com/demisto/alfred/webhook"
+)
+
+const (
+	// webhookLease is how long claimed deliveries are ours before another web node can retry them
+	webhookLease = 5 * time.Minute
+	// webhookMaxAttempts of a delivery before it is marked as failed
+	webhookMaxAttempts = 10
+	// webhookPoll is how often we look for due deliveries
+	webhookPoll = 10 * time.Second
+	// webhookWorkers post the claimed deliveries in parallel
+	webhookWorkers = 4
+	// webhookBatch of deliveries we claim - no more than the workers can post within the lease even if every post
+	// times out, with a few seconds for each to record the outcome
+	webhookBatch = webhookWorkers * int(webhookLease/(webhook.Timeout+5*time.Second))
+)
+
+// WebhookDeliverer posts the due webhook deliveries. Deliveries are leased in the repository so all the web nodes
+// can run it without posting the same delivery twice.
+type WebhookDeliverer struct {
+	r        *repo.MySQL
+	client   *http.Client
+	stop     chan bool
+	stopOnce sync.Once
+}
+
+// NewWebhookDeliverer for the deliveries in the repository - internal webhook addresses are only allowed in development
+func NewWebhookDeliverer(r *repo.MySQL) *WebhookDeliverer {
+	return &WebhookDeliverer{r: r, client: webhook.NewClient(conf.IsDev()), stop: make(chan bool)}
+}
+
+// Start delivering until closed
+func (d *WebhookDeliverer) Start() {
+	ticker := time.NewTicker(webhookPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+		d.deliver()
+	}
+}
+
+// Close stops the deliveries once the current ones are done
+func (d *WebhookDeliverer) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	return nil
+}
+
+// deliver a batch of due deliveries with the workers
+func (d *WebhookDeliverer) deliver() {
+	claimed := time.Now()
+	deliveries, err := d.r.ClaimWebhookDeliveries(webhookLease, webhookBatch)
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to claim webhook deliveries")
+		return
+	}
+	c := make(chan *domain.WebhookDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < webhookWorkers && i < len(deliveries); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range c {
+				d.post(delivery, claimed)
+			}
+		}()
+	}
+	for i := range deliveries {
+		c <- &deliveries[i]
+	}
+	close(c)
+	wg.Wait()
+}
+
+// post the delivery and record the outcome. A delivery that might not be done before the lease expires
+// is left for the next claim so it is not posted twice.
+func (d *WebhookDeliverer) post(delivery *domain.WebhookDelivery, claimed time.Time) {
+	if time.Since(claimed)+webhook.Timeout > webhookLease {
+		logrus.Debugf("Leaving webhook delivery %d for the next claim", delivery.ID)
+		return
+	}
+	err := webhook.Post(d.client, delivery.URL, delivery.Secret, delivery.EventType, delivery.ID, []byte(delivery.Event))
+	if err != nil {
+		logrus.WithError(err).Debugf("Webhook delivery %d to %s failed", delivery.ID, delivery.URL)
+		err = d.r.WebhookAttemptFailed(delivery, err.Error(), nextAttempt(delivery.Attempts+1))
+	} else {
+		err = d.r.WebhookDelivered(delivery.ID)
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("Unable to update webhook delivery %d", delivery.ID)
+	}
+}
+
+// nextAttempt after the given number of failed attempts - nil once the delivery should be marked as failed
+func nextAttempt(attempts int) *time.Time {
+	if attempts >= webhookMaxAttempts {
+		return nil
+	}
+	next := time.Now().Add(webhook.Backoff(attempts))
+	return &next
+}
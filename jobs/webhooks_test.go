@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/webhook"
+)
+
+func TestWebhookBatch(t *testing.T) {
+	// Even if every post times out the workers are done with the batch before the lease expires
+	if perWorker := (webhookBatch + webhookWorkers - 1) / webhookWorkers; time.Duration(perWorker)*webhook.Timeout >= webhookLease {
+		t.Errorf("A batch of %d deliveries does not fit in the lease of %v", webhookBatch, webhookLease)
+	}
+}
+
+func TestNextAttempt(t *testing.T) {
+	if next := nextAttempt(1); next == nil || next.Before(time.Now().Add(webhook.Backoff(1)-time.Second)) {
+		t.Errorf("Expected a retry after the backoff but got %v", next)
+	}
+	if next := nextAttempt(webhookMaxAttempts); next != nil {
+		t.Errorf("Expected the delivery to fail after %d attempts but got %v", webhookMaxAttempts, next)
+	}
+}
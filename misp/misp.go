@@ -0,0 +1,151 @@
+// Package misp exports convicted content as MISP events and pushes them to a MISP instance
+package misp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+// Attribute of a MISP event
+type Attribute struct {
+	Type      string `json:"type"`
+	Category  string `json:"category"`
+	Value     string `json:"value"`
+	Comment   string `json:"comment"`
+	ToIDS     bool   `json:"to_ids"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Event in the MISP format
+type Event struct {
+	Info          string      `json:"info"`
+	Date          string      `json:"date"`
+	ThreatLevelID string      `json:"threat_level_id"`
+	Analysis      string      `json:"analysis"`
+	Distribution  string      `json:"distribution"`
+	Attribute     []Attribute `json:"Attribute"`
+}
+
+// Export wraps the event as MISP expects it in files and in the REST API
+type Export struct {
+	Event *Event `json:"Event"`
+}
+
+// hashType of the hash by its length
+func hashType(hash string) string {
+	switch len(hash) {
+	case 40:
+		return "sha1"
+	case 64:
+		return "sha256"
+	}
+	return "md5"
+}
+
+// attributeType and category of the convicted content. The content of files is their MD5.
+func attributeType(content *domain.MaliciousContent) (string, string, string) {
+	switch content.ContentType {
+	case domain.ReplyTypeFile:
+		if content.FileName != "" {
+			return "filename|md5", "Payload delivery", content.FileName + "|" + content.Content
+		}
+		return "md5", "Payload delivery", content.Content
+	case domain.ReplyTypeHash:
+		return hashType(content.Content), "Payload delivery", content.Content
+	case domain.ReplyTypeURL:
+		return "url", "Network activity", content.Content
+	case domain.ReplyTypeIP:
+		return "ip-dst", "Network activity", content.Content
+	case domain.ReplyTypeDomain:
+		return "domain", "Network activity", content.Content
+	}
+	return "", "", ""
+}
+
+// comment with the scores of the providers that convicted the content
+func comment(content *domain.MaliciousContent) string {
+	var parts []string
+	for _, score := range []struct{ name, value string }{
+		{"VT", content.VT}, {"XFE", content.XFE}, {"Cylance", content.Cy}, {"AutoFocus", content.AF},
+		{"ClamAV", content.ClamAV}, {"Intel", content.Intel},
+	} {
+		if score.value != "" {
+			parts = append(parts, score.name+": "+score.value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// NewEvent with an attribute for each of the convicted content. The same value is only added once.
+func NewEvent(info string, date time.Time, contents []domain.MaliciousContent) *Event {
+	e := &Event{
+		Info:          info,
+		Date:          date.Format("2006-01-02"),
+		ThreatLevelID: "2", // Medium
+		Analysis:      "2", // Completed
+		Distribution:  "0", // Your organization only
+		Attribute:     []Attribute{},
+	}
+	seen := make(map[string]bool)
+	for i := range contents {
+		t, category, value := attributeType(&contents[i])
+		if t == "" || value == "" || seen[t+value] {
+			continue
+		}
+		seen[t+value] = true
+		e.Attribute = append(e.Attribute, Attribute{
+			Type:      t,
+			Category:  category,
+			Value:     value,
+			Comment:   comment(&contents[i]),
+			ToIDS:     true,
+			Timestamp: strconv.FormatInt(contents[i].Timestamp.Unix(), 10),
+		})
+	}
+	return e
+}
+
+// Client pushes events to the MISP REST API
+type Client struct {
+	URL  string       // Base URL of the MISP instance. Required.
+	Key  string       // The automation key of the MISP user. Required.
+	HTTP *http.Client // The HTTP client to use - a client with a timeout if nil
+}
+
+// Push the event to MISP as a new event
+func (c *Client) Push(e *Event) error {
+	body, err := json.Marshal(&Export{Event: e})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(c.URL, "/")+"/events/add", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", c.Key)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("misp: unexpected status %s - %s", resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package misp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+func testContents() []domain.MaliciousContent {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []domain.MaliciousContent{
+		{ContentType: domain.ReplyTypeFile, Content: "44d88612fea8a8f36de82e1278abb02f", FileName: "eicar.com", VT: "55 / 60", ClamAV: "Eicar-Test-Signature", Timestamp: ts},
+		{ContentType: domain.ReplyTypeHash, Content: "3395856ce81f2b7382dee72602f798b642f14140", VT: "40 / 60", Timestamp: ts},
+		{ContentType: domain.ReplyTypeURL, Content: "http://evil.com/a", XFE: "7", Timestamp: ts},
+		{ContentType: domain.ReplyTypeIP, Content: "198.51.100.7", Intel: "On the team blocklist as 198.51.100.0/24", Timestamp: ts},
+		{ContentType: domain.ReplyTypeDomain, Content: "evil.com", Timestamp: ts},
+		{ContentType: domain.ReplyTypeDomain, Content: "evil.com", Timestamp: ts},
+	}
+}
+
+func TestNewEvent(t *testing.T) {
+	e := NewEvent("DBot convictions", time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC), testContents())
+	expected := []struct{ t, value, comment string }{
+		{"filename|md5", "eicar.com|44d88612fea8a8f36de82e1278abb02f", "VT: 55 / 60, ClamAV: Eicar-Test-Signature"},
+		{"sha1", "3395856ce81f2b7382dee72602f798b642f14140", "VT: 40 / 60"},
+		{"url", "http://evil.com/a", "XFE: 7"},
+		{"ip-dst", "198.51.100.7", "Intel: On the team blocklist as 198.51.100.0/24"},
+		{"domain", "evil.com", ""},
+	}
+	if e.Date != "2020-02-01" || len(e.Attribute) != len(expected) {
+		t.Fatalf("Unexpected event %+v", e)
+	}
+	for i, a := range expected {
+		attr := e.Attribute[i]
+		if attr.Type != a.t || attr.Value != a.value || attr.Comment != a.comment || !attr.ToIDS || attr.Timestamp != "1577934245" {
+			t.Errorf("Expected %+v but got %+v", a, attr)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	var got Export
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events/add" || r.Header.Get("Authorization") != "key" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"Event": {"id": "1"}}`))
+	}))
+	defer server.Close()
+	e := NewEvent("DBot convictions", time.Now(), testContents())
+	if err := (&Client{URL: server.URL + "/", Key: "key"}).Push(e); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event == nil || len(got.Event.Attribute) != len(e.Attribute) {
+		t.Errorf("Unexpected pushed event %+v", got.Event)
+	}
+	if err := (&Client{URL: server.URL, Key: "wrong"}).Push(e); err == nil {
+		t.Error("Expected an error for a wrong key")
+	}
+}
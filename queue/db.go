@@ -1,7 +1,9 @@
 package queue
 
 import (
+	"context"
 	"encoding/json"
+	"strconv"
 	"sync"
 	"time"
 
@@ -18,6 +20,7 @@ type dbQueue struct {
 	done         chan bool
 	conf         chan string
 	work         chan *domain.WorkRequest
+	workHigh     chan *domain.WorkRequest // High priority work is popped first
 	workReply    chan *domain.WorkReply
 	webWorkReply map[string]chan *domain.WorkReply
 	mux          sync.Mutex
@@ -29,6 +32,7 @@ func NewDBQueue(r *repo.MySQL) *dbQueue {
 		d:            r,
 		conf:         make(chan string, 1000),
 		work:         make(chan *domain.WorkRequest, 1000),
+		workHigh:     make(chan *domain.WorkRequest, 100),
 		workReply:    make(chan *domain.WorkReply, 1000),
 		webWorkReply: make(map[string]chan *domain.WorkReply),
 		done:         make(chan bool),
@@ -44,13 +48,17 @@ func (dq *dbQueue) PushConf(team string) error {
 }
 
 // PopConf ...
-func (dq *dbQueue) PopConf(timeout time.Duration) (string, error) {
-	team := <-dq.conf
-	// If someone closed the channel
-	if team == "" {
-		return "", ErrClosed
+func (dq *dbQueue) PopConf(ctx context.Context) (string, error) {
+	select {
+	case team := <-dq.conf:
+		// If someone closed the channel
+		if team == "" {
+			return "", ErrClosed
+		}
+		return team, nil
+	case <-ctx.Done():
+		return "", ctxErr(ctx)
 	}
-	return team, nil
 }
 
 // PushWork ...
@@ -59,19 +67,63 @@ func (dq *dbQueue) PushWork(work *domain.WorkRequest) error {
 	if err != nil {
 		return err
 	}
-	m := domain.DBQueueMessage{MessageType: "work", Message: util.ToJSONStringNoIndent(work), Name: work.ReplyQueue}
+	m := domain.DBQueueMessage{MessageType: "work", Message: util.ToJSONStringNoIndent(work), Name: work.ReplyQueue, Priority: work.Priority}
 	return dq.d.PostMessage(&m)
 }
 
 // PopWork ...
-func (dq *dbQueue) PopWork(timeout time.Duration) (*domain.WorkRequest, error) {
-	work := <-dq.work
+func (dq *dbQueue) PopWork(ctx context.Context) (*domain.WorkRequest, error) {
+	var work *domain.WorkRequest
+	select {
+	case work = <-dq.workHigh:
+	default:
+		select {
+		case work = <-dq.workHigh:
+		case work = <-dq.work:
+		case <-ctx.Done():
+			return nil, ctxErr(ctx)
+		}
+	}
 	if work == nil {
 		return nil, ErrClosed
 	}
 	return work, nil
 }
 
+func receiptID(work *domain.WorkRequest) (int64, error) {
+	return strconv.ParseInt(work.Receipt, 10, 64)
+}
+
+// Ack ...
+func (dq *dbQueue) Ack(work *domain.WorkRequest) error {
+	if work.Receipt == "" {
+		return nil
+	}
+	id, err := receiptID(work)
+	if err != nil {
+		return err
+	}
+	return dq.d.AckMessage(id)
+}
+
+// Nack ...
+func (dq *dbQueue) Nack(work *domain.WorkRequest) error {
+	if work.Receipt == "" {
+		return nil
+	}
+	id, err := receiptID(work)
+	if err != nil {
+		return err
+	}
+	return dq.d.NackMessage(id)
+}
+
+func (dq *dbQueue) deadLetter(m *domain.DBQueueMessage, reason string) {
+	if err := dq.d.DeadLetter(m, reason); err != nil {
+		logrus.WithError(err).Errorf("Unable to dead letter message %d", m.ID)
+	}
+}
+
 // PushWorkReply ...
 func (dq *dbQueue) PushWorkReply(replyQueue string, reply *domain.WorkReply) error {
 	_, err := domain.GetContext(reply.Context)
@@ -83,11 +135,9 @@ func (dq *dbQueue) PushWorkReply(replyQueue string, reply *domain.WorkReply) err
 }
 
 // PopWorkReply ...
-func (dq *dbQueue) PopWorkReply(replyQueue string, timeout time.Duration) (work *domain.WorkReply, err error) {
-	if replyQueue == util.Hostname {
-		work = <-dq.workReply
-	} else {
-		var ch chan *domain.WorkReply
+func (dq *dbQueue) PopWorkReply(ctx context.Context, replyQueue string) (work *domain.WorkReply, err error) {
+	ch := dq.workReply
+	if replyQueue != util.Hostname {
 		var ok bool
 		dq.mux.Lock()
 		if ch, ok = dq.webWorkReply[replyQueue]; !ok {
@@ -95,11 +145,17 @@ func (dq *dbQueue) PopWorkReply(replyQueue string, timeout time.Duration) (work
 			dq.webWorkReply[replyQueue] = ch
 		}
 		dq.mux.Unlock()
-		work = <-ch
-		close(ch)
-		dq.mux.Lock()
-		delete(dq.webWorkReply, replyQueue)
-		dq.mux.Unlock()
+		// Stop polling for the reply queue whether we got the reply or gave up on it
+		defer func() {
+			dq.mux.Lock()
+			delete(dq.webWorkReply, replyQueue)
+			dq.mux.Unlock()
+		}()
+	}
+	select {
+	case work = <-ch:
+	case <-ctx.Done():
+		return nil, ctxErr(ctx)
 	}
 	if work == nil {
 		return nil, ErrClosed
@@ -113,6 +169,7 @@ func (dq *dbQueue) Close() error {
 		dq.closed = true
 		close(dq.conf)
 		close(dq.work)
+		close(dq.workHigh)
 		close(dq.workReply)
 		dq.mux.Lock()
 		for _, ch := range dq.webWorkReply {
@@ -123,6 +180,33 @@ func (dq *dbQueue) Close() error {
 	return nil
 }
 
+// claimWork of the given priority to the channel
+func (dq *dbQueue) claimWork(priority int, ch chan *domain.WorkRequest) {
+	// Claim only what we have room for so the leases do not expire while waiting in the channel
+	limit := cap(ch) - len(ch)
+	if limit <= 0 {
+		return
+	}
+	messages, err := dq.d.ClaimMessages(nil, "work", priority, time.Duration(conf.Options.Queue.Lease)*time.Second, limit)
+	if err != nil {
+		logrus.WithError(err).Error("Unable to load worker messages - going to retry")
+	}
+	for _, m := range messages {
+		if m.Retries > conf.Options.Queue.Retries {
+			dq.deadLetter(m, "too many retries")
+			continue
+		}
+		wr := &domain.WorkRequest{}
+		if err := json.Unmarshal([]byte(m.Message), wr); err != nil {
+			logrus.WithError(err).Error("Unable to parse work request message")
+			dq.deadLetter(m, "unable to parse - "+err.Error())
+			continue
+		}
+		wr.Receipt = strconv.FormatInt(m.ID, 10)
+		ch <- wr
+	}
+}
+
 func (dq *dbQueue) getMessages() {
 	t := time.NewTicker(time.Duration(conf.Options.QueuePoll) * time.Second)
 	defer t.Stop()
@@ -132,18 +216,8 @@ func (dq *dbQueue) getMessages() {
 			return
 		case <-t.C:
 			if conf.Options.Worker {
-				messages, err := dq.d.QueueMessages(nil, "work")
-				if err != nil {
-					logrus.WithError(err).Error("Unable to load worker messages - going to retry")
-				}
-				for _, m := range messages {
-					wr := &domain.WorkRequest{}
-					if err := json.Unmarshal([]byte(m.Message), wr); err != nil {
-						logrus.WithError(err).Error("Unable to parse work request message")
-						continue
-					}
-					dq.work <- wr
-				}
+				dq.claimWork(domain.PriorityHigh, dq.workHigh)
+				dq.claimWork(domain.PriorityNormal, dq.work)
 			}
 			if conf.Options.Web {
 				names := []string{util.Hostname}
@@ -160,22 +234,27 @@ func (dq *dbQueue) getMessages() {
 					wr := &domain.WorkReply{}
 					if err := json.Unmarshal([]byte(m.Message), wr); err != nil {
 						logrus.WithError(err).Errorf("Unable to parse work reply message. got message - %s", m.Message)
+						dq.deadLetter(m, "unable to parse - "+err.Error())
 						continue
 					}
 					// If this is a reply to Slack just push it to generic queue
 					if m.Name == util.Hostname {
 						dq.workReply <- wr
 					} else {
-						// Otherwise, make sure to push to the specific web waiter
-						var ch chan *domain.WorkReply
-						var ok bool
+						// Otherwise, make sure to push to the specific web waiter if it is still waiting
 						dq.mux.Lock()
-						if ch, ok = dq.webWorkReply[m.Name]; !ok {
-							ch = make(chan *domain.WorkReply, 1)
-							dq.webWorkReply[m.Name] = ch
+						ch, ok := dq.webWorkReply[m.Name]
+						if ok {
+							select {
+							case ch <- wr:
+							default:
+								ok = false
+							}
 						}
 						dq.mux.Unlock()
-						ch <- wr
+						if !ok {
+							logrus.Warnf("Dropping work reply for %s - nobody is waiting for it", m.Name)
+						}
 					}
 				}
 			}
@@ -2,32 +2,54 @@
 package queue
 
 import (
+	"context"
 	"errors"
-	"time"
+	"fmt"
 
+	"github.com/demisto/alfred/conf"
 	"github.com/demisto/alfred/domain"
 	"github.com/demisto/alfred/repo"
 )
 
 var (
-	// ErrTimeout is returned if receive encounters a timeout
+	// ErrTimeout is returned if the context deadline passes before anything is received
 	ErrTimeout = errors.New("timeout occurred")
 	// ErrClosed is returned if you try to access a closed queue
 	ErrClosed = errors.New("queue is already closed")
 )
 
-// Queue abstracts the external / internal queues
+// Queue abstracts the external / internal queues.
+// Pops block until there is a message, the queue is closed or the context is done.
+// Work requests are delivered at least once - a request that is not acked in time is delivered again.
 type Queue interface {
 	PushConf(team string) error
-	PopConf(timeout time.Duration) (string, error)
+	PopConf(ctx context.Context) (string, error)
 	PushWork(work *domain.WorkRequest) error
-	PopWork(timeout time.Duration) (*domain.WorkRequest, error)
+	PopWork(ctx context.Context) (*domain.WorkRequest, error)
+	// Ack the work request once it is handled so it is not delivered again
+	Ack(work *domain.WorkRequest) error
+	// Nack the work request so it is delivered again or moved to the dead letters if it ran out of retries
+	Nack(work *domain.WorkRequest) error
 	PushWorkReply(replyQueue string, reply *domain.WorkReply) error
-	PopWorkReply(replyQueue string, timeout time.Duration) (*domain.WorkReply, error)
+	PopWorkReply(ctx context.Context, replyQueue string) (*domain.WorkReply, error)
 	Close() error
 }
 
-// New queue is returned depending on environment
+// ctxErr converts the error of a done context to the queue errors
+func ctxErr(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
+
+// New queue is returned depending on the configured type
 func New(r *repo.MySQL) (Queue, error) {
-	return NewDBQueue(r), nil
+	switch conf.Options.Queue.Type {
+	case "", "db":
+		return NewDBQueue(r), nil
+	case "redis":
+		return NewRedisQueue(r)
+	}
+	return nil, fmt.Errorf("unknown queue type %s", conf.Options.Queue.Type)
 }
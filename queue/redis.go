@@ -0,0 +1,401 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/repo"
+	"github.com/demisto/alfred/util"
+	"gopkg.in/redis.v5"
+)
+
+const (
+	redisPrefix        = "alfred:"
+	redisWorkKey       = redisPrefix + "work"
+	redisWorkHighKey   = redisPrefix + "work:high"
+	redisProcessingKey = redisPrefix + "work:processing"
+	redisLeasesKey     = redisPrefix + "work:leases"
+	// redisBlock is the longest we block on a single pop so that closing the queue is noticed
+	redisBlock = 5 * time.Second
+	// redisTTL removes reply and conf lists nobody is waiting for anymore
+	redisTTL = time.Hour
+)
+
+// deadLetters stores the messages we could not handle
+type deadLetters interface {
+	DeadLetter(message *domain.DBQueueMessage, reason string) error
+}
+
+// redisQueue implements the queue functionality using Redis lists with blocking pops.
+// Work requests are moved to a processing list and leased until they are acked.
+type redisQueue struct {
+	c      *redis.Client
+	dl     deadLetters
+	mux    sync.Mutex
+	closed bool
+	// orphans are the work requests in processing without a lease and when we first saw them
+	omux    sync.Mutex
+	orphans map[string]time.Time
+}
+
+// NewRedisQueue connects to the Redis server in the configuration. Dead letters are stored in the database.
+func NewRedisQueue(r *repo.MySQL) (Queue, error) {
+	return newRedisQueue(r)
+}
+
+func newRedisQueue(dl deadLetters) (*redisQueue, error) {
+	c := redis.NewClient(&redis.Options{
+		Addr:     conf.Options.Queue.Redis.Address,
+		Password: conf.Options.Queue.Redis.Password,
+		DB:       conf.Options.Queue.Redis.DB,
+	})
+	if err := c.Ping().Err(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	q := &redisQueue{c: c, dl: dl}
+	// Conf messages go to all the bots so each bot registers to receive them
+	if conf.Options.Web {
+		if err := c.SAdd(redisPrefix+"bots", util.Hostname).Err(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func (rq *redisQueue) isClosed() bool {
+	rq.mux.Lock()
+	defer rq.mux.Unlock()
+	return rq.closed
+}
+
+// pop blocks on the list until there is a message, the queue is closed or the context is done.
+// If dest is given the message is atomically moved there so it is not lost if we crash.
+func (rq *redisQueue) pop(ctx context.Context, key, dest string) (string, error) {
+	for {
+		if rq.isClosed() {
+			return "", ErrClosed
+		}
+		if ctx.Err() != nil {
+			return "", ctxErr(ctx)
+		}
+		block := redisBlock
+		if deadline, ok := ctx.Deadline(); ok {
+			// The deadline might pass before the context notices
+			left := time.Until(deadline)
+			if left <= 0 {
+				return "", ErrTimeout
+			}
+			// Redis blocks for whole seconds and zero means forever so round up
+			if left < block {
+				block = (left + time.Second - 1).Truncate(time.Second)
+			}
+		}
+		var res string
+		var err error
+		if dest == "" {
+			var kv []string
+			if kv, err = rq.c.BRPop(block, key).Result(); err == nil {
+				// The result is the key and the value
+				res = kv[1]
+			}
+		} else {
+			res, err = rq.c.BRPopLPush(key, dest, block).Result()
+		}
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if rq.isClosed() {
+				return "", ErrClosed
+			}
+			return "", err
+		}
+		return res, nil
+	}
+}
+
+// PushConf ...
+func (rq *redisQueue) PushConf(team string) error {
+	bots, err := rq.c.SMembers(redisPrefix + "bots").Result()
+	if err != nil {
+		return err
+	}
+	for _, bot := range bots {
+		key := redisPrefix + "conf:" + bot
+		if err = rq.c.LPush(key, team).Err(); err != nil {
+			return err
+		}
+		// Bots that are gone should not leave their messages behind forever
+		if err = rq.c.Expire(key, redisTTL).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PopConf ...
+func (rq *redisQueue) PopConf(ctx context.Context) (string, error) {
+	return rq.pop(ctx, redisPrefix+"conf:"+util.Hostname, "")
+}
+
+// redisWork wraps the work request with the number of times it was retried.
+// The ID keeps the same work pushed twice apart since the whole message is the receipt.
+type redisWork struct {
+	ID       string `json:"id"`
+	Retries  int    `json:"retries"`
+	Priority int    `json:"priority"`
+	Work     string `json:"work"`
+}
+
+// workKey of the list for work with the priority
+func workKey(priority int) string {
+	if priority == domain.PriorityHigh {
+		return redisWorkHighKey
+	}
+	return redisWorkKey
+}
+
+func (rq *redisQueue) pushWork(rw *redisWork) error {
+	return rq.c.LPush(workKey(rw.Priority), util.ToJSONStringNoIndent(rw)).Err()
+}
+
+// PushWork ...
+func (rq *redisQueue) PushWork(work *domain.WorkRequest) error {
+	_, err := domain.GetContext(work.Context)
+	if err != nil {
+		return err
+	}
+	return rq.pushWork(&redisWork{ID: util.SecureRandomString(16, false), Priority: work.Priority, Work: util.ToJSONStringNoIndent(work)})
+}
+
+// requeueScript removes the message from the processing list and pushes the retry in one step so the work cannot be
+// lost in between. Nothing is pushed if another worker already removed the message.
+var requeueScript = redis.NewScript(`
+if redis.call("LREM", KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call("LPUSH", KEYS[2], ARGV[2])
+return 1
+`)
+
+// release the leased message and push it back to the queue or to the dead letters if it ran out of retries
+func (rq *redisQueue) release(m string, reason string) error {
+	removed, err := rq.c.ZRem(redisLeasesKey, m).Result()
+	if err != nil || removed == 0 {
+		// Someone else already released it
+		return err
+	}
+	return rq.requeue(m, reason)
+}
+
+// requeue the message from the processing list - back to the queue or to the dead letters if it ran out of retries
+func (rq *redisQueue) requeue(m string, reason string) error {
+	rw := &redisWork{}
+	if err := json.Unmarshal([]byte(m), rw); err != nil {
+		return rq.deadLetterProcessing(m, m, 0, "unable to parse - "+err.Error())
+	}
+	if rw.Retries >= conf.Options.Queue.Retries {
+		return rq.deadLetterProcessing(m, rw.Work, rw.Retries, reason)
+	}
+	rw.Retries++
+	return requeueScript.Run(rq.c, []string{redisProcessingKey, workKey(rw.Priority)}, m, util.ToJSONStringNoIndent(rw)).Err()
+}
+
+// deadLetterProcessing removes the message from the processing list and stores the work as a dead letter.
+// If storing fails the message goes back to the processing list where the orphan sweep finds it again.
+func (rq *redisQueue) deadLetterProcessing(m, work string, retries int, reason string) error {
+	removed, err := rq.c.LRem(redisProcessingKey, 1, m).Result()
+	if err != nil || removed == 0 {
+		return err
+	}
+	if err = rq.deadLetter(work, retries, reason); err != nil {
+		if pushErr := rq.c.LPush(redisProcessingKey, m).Err(); pushErr != nil {
+			logrus.WithError(pushErr).Warn("Unable to return work request to processing")
+		}
+	}
+	return err
+}
+
+func (rq *redisQueue) deadLetter(work string, retries int, reason string) error {
+	m := &domain.DBQueueMessage{MessageType: "work", Message: work, Retries: retries}
+	wr := &domain.WorkRequest{}
+	if json.Unmarshal([]byte(work), wr) == nil {
+		m.Name = wr.ReplyQueue
+	}
+	return rq.dl.DeadLetter(m, reason)
+}
+
+// releaseExpired leases of work requests whose worker did not ack or nack in time
+func (rq *redisQueue) releaseExpired() {
+	expired, err := rq.c.ZRangeByScore(redisLeasesKey, redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(time.Now().Unix(), 10)}).Result()
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to load expired leases")
+		return
+	}
+	for _, m := range expired {
+		if err = rq.release(m, "lease expired"); err != nil {
+			logrus.WithError(err).Warn("Unable to release expired lease")
+		}
+	}
+	rq.releaseOrphans()
+}
+
+// releaseOrphans requeues work in the processing list that has no lease - the worker crashed or failed between
+// moving the work and leasing it. Work counts as orphaned only after it had no lease for a whole lease period
+// so work another worker is leasing right now is left alone.
+func (rq *redisQueue) releaseOrphans() {
+	processing, err := rq.c.LRange(redisProcessingKey, 0, -1).Result()
+	if err != nil {
+		logrus.WithError(err).Warn("Unable to load work in processing")
+		return
+	}
+	rq.omux.Lock()
+	defer rq.omux.Unlock()
+	grace := time.Duration(conf.Options.Queue.Lease) * time.Second
+	orphans := make(map[string]time.Time)
+	for _, m := range processing {
+		if err = rq.c.ZScore(redisLeasesKey, m).Err(); err != redis.Nil {
+			// Leased or we cannot tell
+			continue
+		}
+		seen, ok := rq.orphans[m]
+		if !ok || time.Since(seen) < grace {
+			if !ok {
+				seen = time.Now()
+			}
+			orphans[m] = seen
+			continue
+		}
+		if err = rq.requeue(m, "not leased"); err != nil {
+			logrus.WithError(err).Warn("Unable to release work without a lease")
+		}
+	}
+	rq.orphans = orphans
+}
+
+// popWork moves the next work request to the processing list - high priority work first
+func (rq *redisQueue) popWork(ctx context.Context) (string, error) {
+	for {
+		if rq.isClosed() {
+			return "", ErrClosed
+		}
+		rq.releaseExpired()
+		m, err := rq.c.RPopLPush(redisWorkHighKey, redisProcessingKey).Result()
+		if err != redis.Nil {
+			return m, err
+		}
+		// Wait only a little for normal work so high priority work that arrives meanwhile is not delayed
+		short, cancel := context.WithTimeout(ctx, time.Second)
+		m, err = rq.pop(short, redisWorkKey, redisProcessingKey)
+		cancel()
+		if err != ErrTimeout || ctx.Err() != nil {
+			return m, err
+		}
+	}
+}
+
+// PopWork ...
+func (rq *redisQueue) PopWork(ctx context.Context) (*domain.WorkRequest, error) {
+	for {
+		m, err := rq.popWork(ctx)
+		if err != nil {
+			return nil, err
+		}
+		lease := time.Now().Add(time.Duration(conf.Options.Queue.Lease) * time.Second).Unix()
+		if err = rq.c.ZAdd(redisLeasesKey, redis.Z{Score: float64(lease), Member: m}).Err(); err != nil {
+			return nil, err
+		}
+		work := &domain.WorkRequest{}
+		rw := &redisWork{}
+		err = json.Unmarshal([]byte(m), rw)
+		if err == nil {
+			err = json.Unmarshal([]byte(rw.Work), work)
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Unable to parse work request message")
+			// It will never parse so there is no point in retrying
+			reason := "unable to parse - " + err.Error()
+			if err = rq.remove(m); err == nil {
+				err = rq.deadLetter(m, rw.Retries, reason)
+			}
+			if err != nil {
+				logrus.WithError(err).Warn("Unable to dead letter work request")
+			}
+			continue
+		}
+		work.Receipt = m
+		return work, nil
+	}
+}
+
+// remove the leased message for good
+func (rq *redisQueue) remove(m string) error {
+	if err := rq.c.ZRem(redisLeasesKey, m).Err(); err != nil {
+		return err
+	}
+	return rq.c.LRem(redisProcessingKey, 1, m).Err()
+}
+
+// Ack ...
+func (rq *redisQueue) Ack(work *domain.WorkRequest) error {
+	if work.Receipt == "" {
+		return nil
+	}
+	return rq.remove(work.Receipt)
+}
+
+// Nack ...
+func (rq *redisQueue) Nack(work *domain.WorkRequest) error {
+	if work.Receipt == "" {
+		return nil
+	}
+	return rq.release(work.Receipt, "nacked")
+}
+
+// PushWorkReply ...
+func (rq *redisQueue) PushWorkReply(replyQueue string, reply *domain.WorkReply) error {
+	_, err := domain.GetContext(reply.Context)
+	if err != nil {
+		return err
+	}
+	key := redisPrefix + "workr:" + replyQueue
+	if err = rq.c.LPush(key, util.ToJSONStringNoIndent(reply)).Err(); err != nil {
+		return err
+	}
+	return rq.c.Expire(key, redisTTL).Err()
+}
+
+// PopWorkReply ...
+func (rq *redisQueue) PopWorkReply(ctx context.Context, replyQueue string) (*domain.WorkReply, error) {
+	m, err := rq.pop(ctx, redisPrefix+"workr:"+replyQueue, "")
+	if err != nil {
+		return nil, err
+	}
+	reply := &domain.WorkReply{}
+	if err = json.Unmarshal([]byte(m), reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (rq *redisQueue) Close() error {
+	rq.mux.Lock()
+	if rq.closed {
+		rq.mux.Unlock()
+		return nil
+	}
+	rq.closed = true
+	rq.mux.Unlock()
+	if conf.Options.Web {
+		rq.c.SRem(redisPrefix+"bots", util.Hostname)
+	}
+	return rq.c.Close()
+}
@@ -0,0 +1,281 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type testDeadLetters struct {
+	messages []*domain.DBQueueMessage
+	reasons  []string
+}
+
+func (dl *testDeadLetters) DeadLetter(message *domain.DBQueueMessage, reason string) error {
+	dl.messages, dl.reasons = append(dl.messages, message), append(dl.reasons, reason)
+	return nil
+}
+
+func getTestRedisWithDeadLetters(t *testing.T) (*miniredis.Miniredis, *redisQueue, *testDeadLetters) {
+	conf.Load("", true)
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Options.Queue.Type, conf.Options.Queue.Redis.Address = "redis", s.Addr()
+	dl := &testDeadLetters{}
+	q, err := newRedisQueue(dl)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	return s, q, dl
+}
+
+func getTestRedis(t *testing.T) (*miniredis.Miniredis, *redisQueue) {
+	s, q, _ := getTestRedisWithDeadLetters(t)
+	return s, q
+}
+
+func testContext() *domain.Context {
+	return &domain.Context{Team: "kuku", User: "kuku", Channel: "C1", Type: "message"}
+}
+
+func TestRedisQueue_Work(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer s.Close()
+	defer q.Close()
+	assert.Error(t, q.PushWork(&domain.WorkRequest{Text: "kuku", Type: "message"}))
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "first", Type: "message", Context: testContext()}))
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "second", Type: "message", Context: testContext()}))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	work, err := q.PopWork(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", work.Text)
+	workCtx, err := domain.GetContext(work.Context)
+	assert.NoError(t, err)
+	assert.Equal(t, "kuku", workCtx.Team)
+	work, err = q.PopWork(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", work.Text)
+	_, err = q.PopWork(ctx)
+	assert.Equal(t, ErrTimeout, err)
+}
+
+func TestRedisQueue_WorkReply(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer s.Close()
+	defer q.Close()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		q.PushWorkReply("web1", &domain.WorkReply{MessageID: "1", Context: testContext()})
+	}()
+	reply, err := q.PopWorkReply(context.Background(), "web1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", reply.MessageID)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = q.PopWorkReply(ctx, util.Hostname)
+	assert.Equal(t, ErrTimeout, err)
+}
+
+func TestRedisQueue_Conf(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer s.Close()
+	defer q.Close()
+	assert.NoError(t, q.PushConf("kuku"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	team, err := q.PopConf(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "kuku", team)
+}
+
+func TestRedisQueue_Close(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer func() {
+		// miniredis hangs on close while the blocking pop of the closed client still waits on the server
+		time.Sleep(1500 * time.Millisecond)
+		s.Close()
+	}()
+	done := make(chan error)
+	go func() {
+		_, err := q.PopWork(context.Background())
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, q.Close())
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrClosed, err)
+	case <-time.After(2 * redisBlock):
+		t.Fatal("Pop did not return after close")
+	}
+}
+
+func TestRedisQueue_Cancel(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer s.Close()
+	defer q.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := q.PopWork(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+// expiredContext has a deadline that passed without the context noticing yet
+type expiredContext struct {
+	context.Context
+}
+
+func (expiredContext) Deadline() (time.Time, bool) {
+	return time.Now().Add(-time.Millisecond), true
+}
+
+func TestRedisQueue_Expired(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer s.Close()
+	defer q.Close()
+	done := make(chan error)
+	go func() {
+		_, err := q.PopWorkReply(expiredContext{context.Background()}, "web1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrTimeout, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Pop blocked after the deadline")
+	}
+}
+
+func TestRedisQueue_AckNack(t *testing.T) {
+	s, q, dl := getTestRedisWithDeadLetters(t)
+	defer s.Close()
+	defer q.Close()
+	conf.Options.Queue.Retries = 1
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "acked", Type: "message", Context: testContext(), ReplyQueue: "web1"}))
+	work, err := q.PopWork(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Ack(work))
+	assert.False(t, s.Exists(redisProcessingKey))
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "nacked", Type: "message", Context: testContext(), ReplyQueue: "web1"}))
+	work, err = q.PopWork(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Nack(work))
+	work, err = q.PopWork(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "nacked", work.Text)
+	assert.NoError(t, q.Nack(work))
+	assert.Len(t, dl.messages, 1)
+	assert.Equal(t, "web1", dl.messages[0].Name)
+	assert.Equal(t, 1, dl.messages[0].Retries)
+	assert.Equal(t, "nacked", dl.reasons[0])
+	assert.False(t, s.Exists(redisWorkKey))
+}
+
+func TestRedisQueue_SameWork(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer s.Close()
+	defer q.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var works []*domain.WorkRequest
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "same", Type: "message", Context: testContext()}))
+		work, err := q.PopWork(ctx)
+		assert.NoError(t, err)
+		works = append(works, work)
+	}
+	assert.NotEqual(t, works[0].Receipt, works[1].Receipt)
+	assert.NoError(t, q.Ack(works[1]))
+	leases, err := s.ZMembers(redisLeasesKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{works[0].Receipt}, leases)
+	processing, err := s.List(redisProcessingKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{works[0].Receipt}, processing)
+}
+
+func TestRedisQueue_LeaseExpired(t *testing.T) {
+	s, q, dl := getTestRedisWithDeadLetters(t)
+	defer s.Close()
+	defer q.Close()
+	conf.Options.Queue.Lease = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "crashed", Type: "message", Context: testContext()}))
+	_, err := q.PopWork(ctx)
+	assert.NoError(t, err)
+	// The worker crashed so the next pop gets the same request
+	work, err := q.PopWork(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "crashed", work.Text)
+	assert.NoError(t, q.Ack(work))
+	assert.Empty(t, dl.messages)
+}
+
+func TestRedisQueue_NotLeased(t *testing.T) {
+	s, q, dl := getTestRedisWithDeadLetters(t)
+	defer s.Close()
+	defer q.Close()
+	conf.Options.Queue.Lease = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "orphan", Type: "message", Context: testContext()}))
+	popped, err := q.PopWork(ctx)
+	assert.NoError(t, err)
+	// The worker crashed after moving the work to processing and before leasing it
+	s.ZRem(redisLeasesKey, popped.Receipt)
+	work, err := q.PopWork(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "orphan", work.Text)
+	assert.NoError(t, q.Ack(work))
+	processing, err := s.List(redisProcessingKey)
+	assert.Error(t, err)
+	assert.Empty(t, processing)
+	assert.Empty(t, dl.messages)
+}
+
+func TestRedisQueue_DeadLetterUnparsable(t *testing.T) {
+	s, q, dl := getTestRedisWithDeadLetters(t)
+	defer s.Close()
+	defer q.Close()
+	s.Lpush(redisWorkKey, "kuku")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := q.PopWork(ctx)
+	assert.Equal(t, ErrTimeout, err)
+	assert.Len(t, dl.messages, 1)
+	assert.Equal(t, "kuku", dl.messages[0].Message)
+}
+
+func TestRedisQueue_Priority(t *testing.T) {
+	s, q := getTestRedis(t)
+	defer s.Close()
+	defer q.Close()
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "normal", Type: "message", Context: testContext()}))
+	assert.NoError(t, q.PushWork(&domain.WorkRequest{Text: "high", Type: "message", Context: testContext(), Priority: domain.PriorityHigh}))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for _, expected := range []string{"high", "normal"} {
+		work, err := q.PopWork(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, work.Text)
+		assert.NoError(t, q.Nack(work))
+		// Nacked work keeps its priority
+		work, err = q.PopWork(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, work.Text)
+		assert.NoError(t, q.Ack(work))
+	}
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/demisto/alfred/util"
+)
+
+// IDs of the buttons on the warnings
+const (
+	ActionFalsePositive = "false_positive"
+	ActionRescan        = "rescan"
+	ActionDetails       = "details"
+	ActionAllowlist     = "allowlist"
+)
+
+// ActionValue is carried by the buttons to identify the indicator they act on
+type ActionValue struct {
+	Type      string `json:"type"`
+	Indicator string `json:"indicator"`
+}
+
+// ParseActionValue of a button we rendered
+func ParseActionValue(value string) (*ActionValue, error) {
+	v := &ActionValue{}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return nil, err
+	}
+	if v.Type == "" || v.Indicator == "" {
+		return nil, errors.New("action value is missing the indicator")
+	}
+	return v, nil
+}
+
+// button with the value - buttons that only open a URL have no value
+func button(actionID, text, value string) map[string]interface{} {
+	b := map[string]interface{}{
+		"type":      "button",
+		"action_id": actionID,
+		"text":      map[string]interface{}{"type": "plain_text", "text": text},
+	}
+	if value != "" {
+		b["value"] = value
+	}
+	return b
+}
+
+// actions block for a warning on an indicator. Slack rejects the whole message if a button value or URL is too long
+// so buttons for very long indicators are left out - nil if no button is left.
+func actions(s *Section) map[string]interface{} {
+	value := util.ToJSONStringNoIndent(&ActionValue{Type: s.IndicatorType, Indicator: s.Indicator})
+	var elements []map[string]interface{}
+	if len(value) <= maxButtonValue {
+		if s.Color == Danger {
+			elements = append(elements, button(ActionFalsePositive, "Report false positive", value))
+		}
+		// Files cannot be scanned again without the file itself
+		if s.IndicatorType != TypeFile {
+			elements = append(elements, button(ActionRescan, "Rescan now", value))
+		}
+	}
+	if len(s.DetailsLink) <= maxButtonURL {
+		details := button(ActionDetails, "Show details", "")
+		details["url"] = s.DetailsLink
+		elements = append(elements, details)
+	}
+	if len(value) <= maxButtonValue {
+		allowlist := button(ActionAllowlist, "Add to team allowlist", value)
+		allowlist["confirm"] = map[string]interface{}{
+			"title":   map[string]interface{}{"type": "plain_text", "text": "Add to team allowlist?"},
+			"text":    map[string]interface{}{"type": "mrkdwn", "text": "The indicator will be considered clean for the whole team."},
+			"confirm": map[string]interface{}{"type": "plain_text", "text": "Add"},
+			"deny":    map[string]interface{}{"type": "plain_text", "text": "Cancel"},
+		}
+		elements = append(elements, allowlist)
+	}
+	if len(elements) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"type": "actions", "elements": elements}
+}
@@ -0,0 +1,31 @@
+package render
+
+// Attachments renders the message as legacy Slack attachments
+func Attachments(m *Message) []map[string]interface{} {
+	attachments := make([]map[string]interface{}, 0, len(m.Sections))
+	for _, s := range m.Sections {
+		attachment := map[string]interface{}{"fallback": s.Fallback, "color": s.Color}
+		if s.Title != "" {
+			attachment["title"] = s.Title
+		}
+		if s.TitleLink != "" {
+			attachment["title_link"] = s.TitleLink
+		}
+		if s.Text != "" {
+			attachment["text"] = s.Text
+		}
+		if len(s.Fields) > 0 {
+			fields := make([]map[string]interface{}, 0, len(s.Fields))
+			for _, f := range s.Fields {
+				field := map[string]interface{}{"title": f.Title, "value": f.Value}
+				if f.Short {
+					field["short"] = true
+				}
+				fields = append(fields, field)
+			}
+			attachment["fields"] = fields
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments
+}
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Block Kit limits - see https://api.slack.com/reference/block-kit/blocks
+const (
+	maxBlocks      = 50
+	maxTextLength  = 3000
+	maxFields      = 10
+	maxFieldText   = 2000
+	maxButtonValue = 2000
+	maxButtonURL   = 3000
+)
+
+var colorEmoji = map[string]string{
+	Good:    ":white_check_mark:",
+	Warning: ":warning:",
+	Danger:  ":red_circle:",
+}
+
+func mrkdwn(text string, max int) map[string]interface{} {
+	if len(text) > max {
+		text = strings.ToValidUTF8(text[:max-3], "") + "..."
+	}
+	return map[string]interface{}{"type": "mrkdwn", "text": text}
+}
+
+func sectionText(s *Section) string {
+	parts := []string{colorEmoji[s.Color]}
+	switch {
+	case s.TitleLink != "":
+		parts = append(parts, fmt.Sprintf("*<%s|%s>*", s.TitleLink, s.Title))
+	case s.Title != "":
+		parts = append(parts, "*"+s.Title+"*")
+	}
+	text := strings.Join(parts, " ")
+	if s.Text != "" {
+		if s.Title != "" {
+			text += "\n"
+		} else {
+			text += " "
+		}
+		text += s.Text
+	}
+	return text
+}
+
+// Blocks renders the message as Block Kit blocks.
+// Each indicator is separated by a divider, warnings carry buttons to act on them and the footer is shown as a context block.
+func Blocks(m *Message) []map[string]interface{} {
+	var blocks []map[string]interface{}
+	for i := range m.Sections {
+		s := &m.Sections[i]
+		if s.Verdict && len(blocks) > 0 {
+			blocks = append(blocks, map[string]interface{}{"type": "divider"})
+		}
+		block := map[string]interface{}{"type": "section", "text": mrkdwn(sectionText(s), maxTextLength)}
+		if len(s.Fields) > 0 {
+			fields := make([]map[string]interface{}, 0, len(s.Fields))
+			for j, f := range s.Fields {
+				if j == maxFields {
+					break
+				}
+				fields = append(fields, mrkdwn(fmt.Sprintf("*%s*\n%s", f.Title, f.Value), maxFieldText))
+			}
+			block["fields"] = fields
+		}
+		blocks = append(blocks, block)
+		if s.Verdict && s.Indicator != "" && s.Color != Good {
+			if a := actions(s); a != nil {
+				blocks = append(blocks, a)
+			}
+		}
+	}
+	limit := maxBlocks
+	if m.Footer != "" {
+		limit--
+	}
+	// Slack rejects the whole message if there are too many blocks so drop the last sections
+	if len(blocks) > limit {
+		blocks = blocks[:limit]
+	}
+	if m.Footer != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type":     "context",
+			"elements": []map[string]interface{}{mrkdwn(m.Footer, maxTextLength)},
+		})
+	}
+	return blocks
+}
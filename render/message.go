@@ -0,0 +1,465 @@
+// Package render turns work replies into Slack messages.
+// A reply is first converted to a neutral Message which is then rendered as Block Kit blocks or as legacy attachments.
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+	"github.com/demisto/alfred/util"
+	"github.com/slavikm/govt"
+)
+
+// Colors of the sections
+const (
+	Good    = "good"
+	Warning = "warning"
+	Danger  = "danger"
+)
+
+const (
+	fileCommentGood      = "File (%s) is clean. Click %s for more details."
+	fileCommentBig       = "File (%s) is too large to scan. Click %s for more details."
+	fileCommentBad       = "Warning: File (%s) is malicious. Click %s for more details."
+	fileCommentWarning   = "Unable to find details regarding this file (%s). Click %s for more details."
+	urlCommentGood       = "URL (%s) is clean: %s."
+	urlCommentBad        = "Warning: URL (%s) is malicious: %s."
+	urlCommentWarning    = "Unable to find details regarding this URL (%s): %s."
+	ipCommentGood        = "IP (%s) is clean: %s."
+	ipCommentBad         = "Warning: IP (%s) is malicious: %s."
+	ipCommentWarning     = "Unable to find details regarding this IP (%s): %s."
+	ipCommentPrivate     = "IP (%s) is a private (internal) IP so we cannot provide reputation information: %s."
+	domainCommentGood    = "Domain (%s) is clean: %s."
+	domainCommentBad     = "Warning: domain (%s) is malicious: %s."
+	domainCommentWarning = "Unable to find details regarding this domain (%s): %s."
+	hashCommentGood      = "Hash (%s) is clean: %s."
+	hashCommentBad       = "Warning: hash (%s) is malicious: %s."
+	hashCommentWarning   = "Unable to find details regarding this hash (%s): %s."
+	urlCommentAllowed    = "URL (%s) is on the team allowlist: %s."
+	ipCommentAllowed     = "IP (%s) is on the team allowlist: %s."
+	domainCommentAllowed = "Domain (%s) is on the team allowlist: %s."
+	hashCommentAllowed   = "Hash (%s) is on the team allowlist: %s."
+	urlCommentBlocked    = "Warning: URL (%s) is on the team blocklist: %s."
+	ipCommentBlocked     = "Warning: IP (%s) is on the team blocklist: %s."
+	domainCommentBlocked = "Warning: domain (%s) is on the team blocklist: %s."
+	hashCommentBlocked   = "Warning: hash (%s) is on the team blocklist: %s."
+	// maxDetectedURLs we list for IPs and domains
+	maxDetectedURLs = 20
+)
+
+// Types of the indicators in the sections
+const (
+	TypeURL    = "url"
+	TypeIP     = "ip"
+	TypeDomain = "domain"
+	TypeHash   = "hash"
+	TypeFile   = "file"
+)
+
+// builtinProviders have their own sections in the replies
+var builtinProviders = []string{"xfe", "vt", "cy", "af"}
+
+// providerTitles of the providers whose name is not good enough as the section title
+var providerTitles = map[string]string{domain.ProviderTeamIntel: "Team intel", domain.ProviderSTIX: "Team STIX intel"}
+
+// Field is a short title and value pair in a section
+type Field struct {
+	Title string
+	Value string
+	Short bool
+}
+
+// Section of the message - the verdict on an indicator or the details of a single provider
+type Section struct {
+	// Verdict is set for the section that opens each indicator
+	Verdict bool
+	// Indicator the verdict is about with its type and details page for the actions on it
+	Indicator     string
+	IndicatorType string
+	DetailsLink   string
+	Color         string
+	Title         string
+	TitleLink     string
+	Text          string
+	// Fallback is the plain text shown where the section cannot be displayed
+	Fallback string
+	Fields   []Field
+}
+
+// Message is the view of a reply independent of how Slack displays it
+type Message struct {
+	Sections []Section
+	// Footer is shown at the bottom of the message
+	Footer string
+}
+
+// Clean is true if none of the sections has a warning
+func (m *Message) Clean() bool {
+	for i := range m.Sections {
+		if m.Sections[i].Color != Good {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Message) add(sections ...Section) {
+	m.Sections = append(m.Sections, sections...)
+}
+
+func verdict(result int, clean, dirty, unknown string) (string, string) {
+	switch result {
+	case domain.ResultDirty:
+		return Danger, dirty
+	case domain.ResultClean:
+		return Good, clean
+	}
+	return Warning, unknown
+}
+
+func allowlisted(rule string) bool {
+	return strings.HasPrefix(rule, domain.RuleAllowlist)
+}
+
+func blocklisted(rule string) bool {
+	return strings.HasPrefix(rule, domain.RuleBlocklist)
+}
+
+// local verdicts come from the team lists and intel. These indicators were not looked up so the only
+// provider details to show are of the team intel.
+func local(rule string) bool {
+	return allowlisted(rule) || blocklisted(rule) || strings.HasPrefix(rule, domain.RuleSTIX)
+}
+
+// providerColor of the section based on the provider result after applying the verdict policy
+func providerColor(results map[string]domain.ProviderResult, name string) string {
+	if results[name].Result == domain.ResultDirty {
+		return Danger
+	}
+	return Good
+}
+
+// providerSections for the providers that do not have their own section in the reply
+func providerSections(results map[string]domain.ProviderResult) []Section {
+	var names []string
+	for name, res := range results {
+		if !util.In(builtinProviders, name) && res.Error == "" && res.Result != domain.ResultUnknown {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	sections := make([]Section, 0, len(names))
+	for _, name := range names {
+		title, ok := providerTitles[name]
+		if !ok {
+			title = name
+		}
+		sections = append(sections, Section{
+			Color:     providerColor(results, name),
+			Title:     title,
+			TitleLink: results[name].Link,
+			Text:      results[name].Summary,
+			Fallback:  results[name].Summary,
+		})
+	}
+	return sections
+}
+
+// byDate sorts the detected URLs by their scan date
+type byDate []govt.DetectedUrl
+
+func (a byDate) Len() int           { return len(a) }
+func (a byDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byDate) Less(i, j int) bool { return a[i].ScanDate < a[j].ScanDate }
+
+// detectedURLs lists the latest URLs VirusTotal detected on an IP or a domain
+func detectedURLs(urls []govt.DetectedUrl) string {
+	sort.Sort(sort.Reverse(byDate(urls)))
+	list := ""
+	for j := range urls {
+		if j < maxDetectedURLs {
+			list += fmt.Sprintf("URL: %s, Positives: %v, Total: %v, Date: %s", ioc.Defang(urls[j].Url), urls[j].Positives, urls[j].Total, urls[j].ScanDate) + "\n"
+		}
+	}
+	return list
+}
+
+// quote the indicator as the user wrote it if it was defanged, otherwise defang it so Slack does not link it
+func quote(details, original string) string {
+	if original != "" && original != details {
+		return "`" + original + "`"
+	}
+	return ioc.Defang(details)
+}
+
+func detailsURL(link, text string) string {
+	return link + "&text=" + url.QueryEscape(text)
+}
+
+func detailsLink(link, text string) string {
+	return fmt.Sprintf("<%s|Details>", detailsURL(link, text))
+}
+
+func hashSections(h *domain.HashReply) []Section {
+	var sections []Section
+	if h.AF.Error == "" {
+		sections = append(sections, Section{
+			Color:     providerColor(h.Providers, "af"),
+			Title:     "Palo Alto Networks AutoFocus",
+			TitleLink: "https://www.paloaltonetworks.com/products/secure-the-network/autofocus",
+			Fallback:  h.AF.Result.String(),
+			Fields: []Field{
+				{Title: "Malware", Value: strconv.FormatBool(h.AF.Result.Malware)},
+				{Title: "Created", Value: fmt.Sprintf("%v", h.AF.Result.Created)},
+				{Title: "Tags", Value: strings.Join(h.AF.Result.Tags, ", ")},
+				{Title: "Tag Groups", Value: strings.Join(h.AF.Result.TagGroups, ", ")},
+				{Title: "Regions", Value: strings.Join(h.AF.Result.Regions, ", ")},
+				{Title: "File Type", Value: h.AF.Result.FileType},
+			},
+		})
+	}
+	sections = append(sections, fileSections(h)...)
+	return sections
+}
+
+// fileSections are the hash details that are shown for uploaded files as well
+func fileSections(h *domain.HashReply) []Section {
+	var sections []Section
+	if h.Cy.Error == "" && h.Cy.Result.StatusCode == 1 {
+		sections = append(sections, Section{
+			Color:     providerColor(h.Providers, "cy"),
+			Title:     "Cylance Infinity",
+			TitleLink: "https://www.cylance.com",
+			Fallback:  fmt.Sprintf("Score: %v, Classifiers: %v", h.Cy.Result.GeneralScore, h.Cy.Result.Classifiers),
+			Fields: []Field{
+				{Title: "Score", Value: fmt.Sprintf("%v", h.Cy.Result.GeneralScore), Short: true},
+				{Title: "Classifiers", Value: util.JoinMapFloat32(h.Cy.Result.Classifiers), Short: true},
+			},
+		})
+	}
+	if !h.XFE.NotFound && h.XFE.Error == "" {
+		sections = append(sections, Section{
+			Color:     providerColor(h.Providers, "xfe"),
+			Title:     "IBM X-Force Exchange",
+			TitleLink: fmt.Sprintf("https://exchange.xforce.ibmcloud.com/malware/%s", h.Details),
+			Fallback:  fmt.Sprintf("Mime Type: %s, Family: %s", h.XFE.Malware.MimeType, strings.Join(h.XFE.Malware.Family, ",")),
+			Fields: []Field{
+				{Title: "Family", Value: strings.Join(h.XFE.Malware.Family, ","), Short: true},
+				{Title: "MIME Type", Value: h.XFE.Malware.MimeType, Short: true},
+				{Title: "Created", Value: h.XFE.Malware.Created.String(), Short: true},
+			},
+		})
+	}
+	if h.VT.FileReport.ResponseCode == 1 {
+		sections = append(sections, Section{
+			Color:     providerColor(h.Providers, "vt"),
+			Title:     "VirusTotal",
+			TitleLink: h.VT.FileReport.Permalink,
+			Fallback:  fmt.Sprintf("Scan Date: %s, Positives: %v, Total: %v", h.VT.FileReport.ScanDate, h.VT.FileReport.Positives, h.VT.FileReport.Total),
+			Fields: []Field{
+				{Title: "Scan Date", Value: h.VT.FileReport.ScanDate, Short: true},
+				{Title: "Positives", Value: fmt.Sprintf("%v", h.VT.FileReport.Positives), Short: true},
+				{Title: "Total", Value: fmt.Sprintf("%v", h.VT.FileReport.Total), Short: true},
+			},
+		})
+	}
+	return append(sections, providerSections(h.Providers)...)
+}
+
+// File converts the reply to an uploaded file. The reply must have exactly one hash and link is the details page of the file.
+func File(reply *domain.WorkReply, link string) *Message {
+	color, comment := verdict(reply.File.Result, fileCommentGood, fileCommentBad, fileCommentWarning)
+	if reply.File.FileTooLarge {
+		color, comment = Warning, fileCommentBig
+	}
+	text := fmt.Sprintf(comment, reply.File.Details.Name, detailsLink(link, reply.Hashes[0].Details))
+	m := &Message{}
+	m.add(Section{Verdict: true, Color: color, Text: text, Fallback: text,
+		Indicator: reply.Hashes[0].Details, IndicatorType: TypeFile, DetailsLink: detailsURL(link, reply.Hashes[0].Details)})
+	if local(reply.Hashes[0].Rule) {
+		m.add(providerSections(reply.Hashes[0].Providers)...)
+	} else {
+		m.add(fileSections(&reply.Hashes[0])...)
+	}
+	if reply.File.Virus != "" {
+		m.add(Section{
+			Color:    Danger,
+			Title:    "ClamAV",
+			Text:     fmt.Sprintf("Virus name: %s", reply.File.Virus),
+			Fallback: fmt.Sprintf("Virus name: %s", reply.File.Virus),
+		})
+	}
+	return m
+}
+
+// Indicators converts the reply to a message with indicators. link is the details page of the message.
+// Unless verbose, only the indicators that are not clean are shown, without the provider details.
+func Indicators(reply *domain.WorkReply, link string, verbose bool) *Message {
+	m := &Message{}
+	addVerdict := func(indicatorType, color, comment, original, details, linkText string) {
+		if verbose || color != Good {
+			text := fmt.Sprintf(comment, quote(details, original), detailsLink(link, linkText))
+			m.add(Section{Verdict: true, Color: color, Text: text, Fallback: text,
+				Indicator: details, IndicatorType: indicatorType, DetailsLink: detailsURL(link, linkText)})
+		}
+	}
+	for i := range reply.URLs {
+		u := &reply.URLs[i]
+		color, comment := verdict(u.Result, urlCommentGood, urlCommentBad, urlCommentWarning)
+		if allowlisted(u.Rule) {
+			comment = urlCommentAllowed
+		} else if blocklisted(u.Rule) {
+			comment = urlCommentBlocked
+		}
+		addVerdict(TypeURL, color, comment, u.Original, u.Details, "<"+u.Details+">")
+		if !verbose {
+			continue
+		}
+		if local(u.Rule) {
+			m.add(providerSections(u.Providers)...)
+			continue
+		}
+		if !u.XFE.NotFound && u.XFE.Error == "" {
+			s := Section{
+				Color:     providerColor(u.Providers, "xfe"),
+				Title:     "IBM X-Force Exchange",
+				TitleLink: fmt.Sprintf("https://exchange.xforce.ibmcloud.com/url/%s", u.Details),
+				Fallback: fmt.Sprintf("Score: %v, A Records: %s, Categories: %s",
+					u.XFE.URLDetails.Score, strings.Join(u.XFE.Resolve.A, ","), util.JoinMap(u.XFE.URLDetails.Cats)),
+				Fields: []Field{
+					{Title: "Score", Value: fmt.Sprintf("%v", u.XFE.URLDetails.Score), Short: true},
+					{Title: "A Records", Value: strings.Join(u.XFE.Resolve.A, ","), Short: true},
+					{Title: "Categories", Value: util.JoinMap(u.XFE.URLDetails.Cats), Short: true},
+				},
+			}
+			if len(u.XFE.Resolve.AAAA) > 0 {
+				s.Fields = append(s.Fields, Field{Title: "AAAA Records", Value: strings.Join(u.XFE.Resolve.AAAA, ","), Short: true})
+			}
+			m.add(s)
+		}
+		if u.VT.URLReport.ResponseCode == 1 {
+			m.add(Section{
+				Color:     providerColor(u.Providers, "vt"),
+				Title:     "VirusTotal",
+				TitleLink: u.VT.URLReport.Permalink,
+				Fallback:  fmt.Sprintf("Scan Date: %s, Positives: %v, Total: %v", u.VT.URLReport.ScanDate, u.VT.URLReport.Positives, u.VT.URLReport.Total),
+				Fields: []Field{
+					{Title: "Scan Date", Value: u.VT.URLReport.ScanDate, Short: true},
+					{Title: "Positives", Value: fmt.Sprintf("%v", u.VT.URLReport.Positives), Short: true},
+					{Title: "Total", Value: fmt.Sprintf("%v", u.VT.URLReport.Total), Short: true},
+				},
+			})
+		}
+		m.add(providerSections(u.Providers)...)
+	}
+	for i := range reply.IPs {
+		ip := &reply.IPs[i]
+		color, comment := verdict(ip.Result, ipCommentGood, ipCommentBad, ipCommentWarning)
+		if ip.Private {
+			color, comment = Good, ipCommentPrivate
+		} else if allowlisted(ip.Rule) {
+			comment = ipCommentAllowed
+		} else if blocklisted(ip.Rule) {
+			comment = ipCommentBlocked
+		}
+		addVerdict(TypeIP, color, comment, ip.Original, ip.Details, ip.Details)
+		if !verbose {
+			continue
+		}
+		if local(ip.Rule) {
+			m.add(providerSections(ip.Providers)...)
+			continue
+		}
+		if !ip.XFE.NotFound && ip.XFE.Error == "" {
+			country := util.NilOrUnknown(ip.XFE.IPReputation.Geo["country"])
+			m.add(Section{
+				Color:     providerColor(ip.Providers, "xfe"),
+				Title:     "IBM X-Force Exchange",
+				TitleLink: fmt.Sprintf("https://exchange.xforce.ibmcloud.com/ip/%s", ip.Details),
+				Fallback:  fmt.Sprintf("Score: %v, Categories: %s, Geo: %v", ip.XFE.IPReputation.Score, util.JoinMapInt(ip.XFE.IPReputation.Cats), country),
+				Fields: []Field{
+					{Title: "Score", Value: fmt.Sprintf("%v", ip.XFE.IPReputation.Score), Short: true},
+					{Title: "Categories", Value: util.JoinMapInt(ip.XFE.IPReputation.Cats), Short: true},
+					{Title: "Geo", Value: country, Short: true},
+				},
+			})
+		}
+		if ip.VT.IPReport.ResponseCode == 1 {
+			list := detectedURLs(ip.VT.IPReport.DetectedUrls)
+			m.add(Section{
+				Color:     providerColor(ip.Providers, "vt"),
+				Title:     "VirusTotal",
+				TitleLink: "https://www.virustotal.com/en/search?query=" + ip.Details,
+				Text:      list,
+				Fallback:  list,
+			})
+		}
+		m.add(providerSections(ip.Providers)...)
+	}
+	for i := range reply.Domains {
+		d := &reply.Domains[i]
+		color, comment := verdict(d.Result, domainCommentGood, domainCommentBad, domainCommentWarning)
+		if allowlisted(d.Rule) {
+			comment = domainCommentAllowed
+		} else if blocklisted(d.Rule) {
+			comment = domainCommentBlocked
+		}
+		addVerdict(TypeDomain, color, comment, d.Original, d.Details, d.Details)
+		if !verbose {
+			continue
+		}
+		if local(d.Rule) {
+			m.add(providerSections(d.Providers)...)
+			continue
+		}
+		if !d.XFE.NotFound && d.XFE.Error == "" {
+			m.add(Section{
+				Color:     providerColor(d.Providers, "xfe"),
+				Title:     "IBM X-Force Exchange",
+				TitleLink: fmt.Sprintf("https://exchange.xforce.ibmcloud.com/url/%s", d.Details),
+				Fallback: fmt.Sprintf("Score: %v, A Records: %s, Categories: %s",
+					d.XFE.URLDetails.Score, strings.Join(d.XFE.Resolve.A, ","), util.JoinMap(d.XFE.URLDetails.Cats)),
+				Fields: []Field{
+					{Title: "Score", Value: fmt.Sprintf("%v", d.XFE.URLDetails.Score), Short: true},
+					{Title: "A Records", Value: strings.Join(d.XFE.Resolve.A, ","), Short: true},
+					{Title: "Categories", Value: util.JoinMap(d.XFE.URLDetails.Cats), Short: true},
+				},
+			})
+		}
+		if d.VT.DomainReport.ResponseCode == 1 {
+			list := detectedURLs(d.VT.DomainReport.DetectedUrls)
+			m.add(Section{
+				Color:     providerColor(d.Providers, "vt"),
+				Title:     "VirusTotal",
+				TitleLink: d.Providers["vt"].Link,
+				Text:      list,
+				Fallback:  list,
+			})
+		}
+		m.add(providerSections(d.Providers)...)
+	}
+	// Hashes are shown only in verbose channels
+	if verbose {
+		for i := range reply.Hashes {
+			h := &reply.Hashes[i]
+			color, comment := verdict(h.Result, hashCommentGood, hashCommentBad, hashCommentWarning)
+			if allowlisted(h.Rule) {
+				comment = hashCommentAllowed
+			} else if blocklisted(h.Rule) {
+				comment = hashCommentBlocked
+			}
+			addVerdict(TypeHash, color, comment, h.Original, h.Details, h.Details)
+			if local(h.Rule) {
+				m.add(providerSections(h.Providers)...)
+				continue
+			}
+			m.add(hashSections(h)...)
+		}
+	}
+	return m
+}
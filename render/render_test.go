@@ -0,0 +1,188 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/goxforce"
+	"github.com/slavikm/govt"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const testLink = "https://alfred.test/details?c=C1&m=1.1&t=T1"
+
+func testIndicatorsReply() *domain.WorkReply {
+	return &domain.WorkReply{
+		MessageID: "1.1",
+		URLs: []domain.URLReply{{
+			Details:  "http://evil.com/path",
+			Original: "hxxp://evil[.]com/path",
+			Result:   domain.ResultDirty,
+			XFE: domain.XfeURLReply{
+				Resolve:    goxforce.ResolveResp{A: []string{"1.2.3.4"}},
+				URLDetails: goxforce.URL{Score: 7, Cats: map[string]bool{"Malware": true}},
+			},
+			VT: domain.VtURLReply{URLReport: govt.UrlReport{ResponseCode: 1, Positives: 5, Total: 60, ScanDate: "2016-01-02 03:04:05", Permalink: "https://vt.test/url"}},
+			Providers: map[string]domain.ProviderResult{
+				"xfe":      {Result: domain.ResultDirty},
+				"vt":       {Result: domain.ResultDirty},
+				"urlscan":  {Result: domain.ResultDirty, Summary: "Phishing", Link: "https://urlscan.test/evil"},
+				"unknowns": {Result: domain.ResultUnknown, Summary: "Not shown"},
+			},
+		}},
+		IPs: []domain.IPReply{{
+			Details: "10.0.0.1",
+			Private: true,
+		}, {
+			Details: "8.8.8.8",
+			Result:  domain.ResultUnknown,
+			XFE:     domain.XfeIPReply{NotFound: true},
+			VT: domain.VtIPReply{IPReport: govt.IpReport{ResponseCode: 1, DetectedUrls: []govt.DetectedUrl{
+				{Url: "http://old.com", Positives: 1, Total: 60, ScanDate: "2015-01-01 00:00:00"},
+				{Url: "http://new.com", Positives: 2, Total: 60, ScanDate: "2016-01-01 00:00:00"},
+			}}},
+		}},
+		Domains: []domain.DomainReply{{
+			Details: "good.com",
+			Result:  domain.ResultClean,
+			XFE:     domain.XfeDomainReply{NotFound: true},
+		}, {
+			Details:   "intel.example.com",
+			Result:    domain.ResultDirty,
+			Rule:      domain.RuleBlocklist + ".example.com",
+			Providers: map[string]domain.ProviderResult{domain.ProviderTeamIntel: {Result: domain.ResultDirty, Summary: "On the team blocklist as .example.com - IR-1"}},
+		}},
+		Hashes: []domain.HashReply{{
+			Details: "d41d8cd98f00b204e9800998ecf8427e",
+			Result:  domain.ResultClean,
+			AF:      domain.AFHashReply{Error: "no key"},
+			XFE:     domain.XfeHashReply{NotFound: true},
+			VT:      domain.VtHashReply{FileReport: govt.FileReport{ResponseCode: 1, Positives: 0, Total: 60, ScanDate: "2016-01-02 03:04:05", Permalink: "https://vt.test/file"}},
+		}},
+	}
+}
+
+func testFileReply() *domain.WorkReply {
+	return &domain.WorkReply{
+		Type: domain.ReplyTypeFile,
+		File: domain.FileReply{Result: domain.ResultDirty, Virus: "Eicar-Test-Signature", Details: domain.File{ID: "F1", Name: "eicar.com"}},
+		Hashes: []domain.HashReply{{
+			Details: "44d88612fea8a8f36de82e1278abb02f",
+			Result:  domain.ResultDirty,
+			XFE: domain.XfeHashReply{Malware: goxforce.Malware{
+				Family:   []string{"eicar"},
+				MimeType: "application/octet-stream",
+				Created:  time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC),
+			}},
+			VT:        domain.VtHashReply{FileReport: govt.FileReport{ResponseCode: 1, Positives: 55, Total: 60, ScanDate: "2016-01-02 03:04:05", Permalink: "https://vt.test/file"}},
+			Providers: map[string]domain.ProviderResult{"xfe": {Result: domain.ResultDirty}, "vt": {Result: domain.ResultDirty}},
+		}},
+	}
+}
+
+func checkGolden(t *testing.T, name string, v interface{}) {
+	path := filepath.Join("testdata", name+".golden")
+	// Do not escape the Slack links so the golden files are readable
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	actual := buf.Bytes()
+	if *update {
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read golden file %s - %v", path, err)
+	}
+	if string(expected) != string(actual) {
+		t.Errorf("%s does not match the golden file:\n%s", name, actual)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *Message
+	}{
+		{"indicators", Indicators(testIndicatorsReply(), testLink, false)},
+		{"indicators_verbose", Indicators(testIndicatorsReply(), testLink, true)},
+		{"file", File(testFileReply(), testLink)},
+	}
+	for _, test := range tests {
+		checkGolden(t, test.name+".attachments", Attachments(test.message))
+		test.message.Footer = "Security check by DBot"
+		checkGolden(t, test.name+".blocks", Blocks(test.message))
+	}
+}
+
+func TestClean(t *testing.T) {
+	reply := testIndicatorsReply()
+	reply.URLs, reply.IPs, reply.Domains = nil, reply.IPs[:1], reply.Domains[:1]
+	if m := Indicators(reply, testLink, false); !m.Clean() {
+		t.Errorf("Expected clean message but got %+v", m)
+	}
+	if m := Indicators(testIndicatorsReply(), testLink, false); m.Clean() {
+		t.Error("Expected the malicious URL to make the message not clean")
+	}
+}
+
+func TestBlocksLimits(t *testing.T) {
+	m := &Message{Footer: "footer"}
+	for i := 0; i < maxBlocks; i++ {
+		m.add(Section{Color: Good, Text: string(make([]byte, maxTextLength+1))})
+	}
+	blocks := Blocks(m)
+	if len(blocks) != maxBlocks {
+		t.Fatalf("Expected %d blocks but got %d", maxBlocks, len(blocks))
+	}
+	if blocks[len(blocks)-1]["type"] != "context" {
+		t.Errorf("Expected the footer to be kept but got %v", blocks[len(blocks)-1])
+	}
+	if text := blocks[0]["text"].(map[string]interface{})["text"].(string); len(text) != maxTextLength {
+		t.Errorf("Expected the text to be truncated to %d but got %d", maxTextLength, len(text))
+	}
+}
+
+func TestActionsLimits(t *testing.T) {
+	long := "http://evil.com/" + strings.Repeat("a", maxButtonURL)
+	s := &Section{Color: Danger, Verdict: true, Indicator: long, IndicatorType: TypeURL, DetailsLink: detailsURL("https://alfred.test/details?t=T1", long)}
+	if a := actions(s); a != nil {
+		t.Errorf("Expected no buttons for a long indicator but got %v", a)
+	}
+	s.DetailsLink = "https://alfred.test/details?t=T1"
+	a := actions(s)
+	if a == nil {
+		t.Fatal("Expected the details button")
+	}
+	elements := a["elements"].([]map[string]interface{})
+	if len(elements) != 1 || elements[0]["action_id"] != ActionDetails || elements[0]["value"] != nil {
+		t.Errorf("Expected only the details button without a value but got %v", elements)
+	}
+}
+
+func TestParseActionValue(t *testing.T) {
+	v, err := ParseActionValue(`{"type":"url","indicator":"http://evil.com"}`)
+	if err != nil || v.Type != TypeURL || v.Indicator != "http://evil.com" {
+		t.Errorf("Wrong action value %+v - %v", v, err)
+	}
+	for _, value := range []string{"kuku", `{"type":"url"}`} {
+		if _, err = ParseActionValue(value); err == nil {
+			t.Errorf("Expected error for %s", value)
+		}
+	}
+}
@@ -1,12 +1,15 @@
 package repo
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -98,9 +101,36 @@ CREATE TABLE IF NOT EXISTS team_statistics (
 	ips_clean BIGINT NOT NULL,
 	ips_dirty BIGINT NOT NULL,
 	ips_unknown BIGINT NOT NULL,
+	domains_clean BIGINT NOT NULL DEFAULT 0,
+	domains_dirty BIGINT NOT NULL DEFAULT 0,
+	domains_unknown BIGINT NOT NULL DEFAULT 0,
 	CONSTRAINT team_statistics_pk PRIMARY KEY (team),
 	CONSTRAINT team_statistics_team_fk FOREIGN KEY (team) REFERENCES teams (id)
 );
+CREATE TABLE IF NOT EXISTS statistics_buckets (
+	team VARCHAR(64) NOT NULL,
+	period VARCHAR(5) NOT NULL,
+	bucket DATETIME NOT NULL,
+	messages BIGINT NOT NULL,
+	files_clean BIGINT NOT NULL,
+	files_dirty BIGINT NOT NULL,
+	files_unknown BIGINT NOT NULL,
+	urls_clean BIGINT NOT NULL,
+	urls_dirty BIGINT NOT NULL,
+	urls_unknown BIGINT NOT NULL,
+	hashes_clean BIGINT NOT NULL,
+	hashes_dirty BIGINT NOT NULL,
+	hashes_unknown BIGINT NOT NULL,
+	ips_clean BIGINT NOT NULL,
+	ips_dirty BIGINT NOT NULL,
+	ips_unknown BIGINT NOT NULL,
+	domains_clean BIGINT NOT NULL DEFAULT 0,
+	domains_dirty BIGINT NOT NULL DEFAULT 0,
+	domains_unknown BIGINT NOT NULL DEFAULT 0,
+	CONSTRAINT statistics_buckets_pk PRIMARY KEY (team, period, bucket),
+	INDEX statistics_buckets_bucket_idx (bucket),
+	CONSTRAINT statistics_buckets_team_fk FOREIGN KEY (team) REFERENCES teams (id)
+);
 CREATE TABLE IF NOT EXISTS slack_invites (
 	email VARCHAR(128) NOT NULL,
 	ts TIMESTAMP NOT NULL,
@@ -108,6 +138,7 @@ CREATE TABLE IF NOT EXISTS slack_invites (
 	CONSTRAINT slack_invites_pk PRIMARY KEY (email)
 );
 CREATE TABLE IF NOT EXISTS convicted (
+	id BIGINT NOT NULL AUTO_INCREMENT,
 	team VARCHAR(64) NOT NULL,
 	channel VARCHAR(64) NOT NULL,
 	message_id VARCHAR(64) NOT NULL,
@@ -120,7 +151,9 @@ CREATE TABLE IF NOT EXISTS convicted (
 	clamav VARCHAR(128),
 	cy VARCHAR(128),
 	af VARCHAR(128),
-	CONSTRAINT convicted_pk PRIMARY KEY (team, channel, message_id),
+	intel VARCHAR(128),
+	CONSTRAINT convicted_pk PRIMARY KEY (id),
+	CONSTRAINT convicted_content_uk UNIQUE (team, channel, message_id, content_type, content),
 	CONSTRAINT convicted_team_fk FOREIGN KEY (team) REFERENCES teams (id)
 );
 CREATE TABLE IF NOT EXISTS queue (
@@ -130,17 +163,158 @@ CREATE TABLE IF NOT EXISTS queue (
 	message LONGTEXT NOT NULL,
 	ts TIMESTAMP NOT NULL,
 	CONSTRAINT queue_pk PRIMARY KEY (id)
+);
+CREATE TABLE IF NOT EXISTS verdict_policies (
+	team VARCHAR(64) NOT NULL,
+	policy LONGTEXT NOT NULL,
+	CONSTRAINT verdict_policies_pk PRIMARY KEY (team),
+	CONSTRAINT verdict_policies_team_fk FOREIGN KEY (team) REFERENCES teams (id)
+);
+CREATE TABLE IF NOT EXISTS reputation_cache (
+	id VARCHAR(64) NOT NULL,
+	provider VARCHAR(64) NOT NULL,
+	indicator TEXT NOT NULL,
+	online INT(1) NOT NULL,
+	result LONGTEXT NOT NULL,
+	expires TIMESTAMP NOT NULL,
+	CONSTRAINT reputation_cache_pk PRIMARY KEY (id)
+);
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	name VARCHAR(64) NOT NULL,
+	message_type VARCHAR(10) NOT NULL,
+	message LONGTEXT NOT NULL,
+	retries INT NOT NULL,
+	reason TEXT NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT dead_letters_pk PRIMARY KEY (id)
+);
+CREATE TABLE IF NOT EXISTS slack_events (
+	event_id VARCHAR(64) NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT slack_events_pk PRIMARY KEY (event_id)
+);
+CREATE TABLE IF NOT EXISTS bot_replies (
+	team VARCHAR(64) NOT NULL,
+	channel VARCHAR(64) NOT NULL,
+	message_id VARCHAR(64) NOT NULL,
+	reply_ts VARCHAR(64) NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT bot_replies_pk PRIMARY KEY (team, channel, message_id)
+);
+CREATE TABLE IF NOT EXISTS allowlist (
+	team VARCHAR(64) NOT NULL,
+	indicator VARCHAR(512) NOT NULL,
+	user VARCHAR(64) NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT allowlist_pk PRIMARY KEY (team, indicator),
+	CONSTRAINT allowlist_team_fk FOREIGN KEY (team) REFERENCES teams (id)
+);
+CREATE TABLE IF NOT EXISTS blocklist (
+	team VARCHAR(64) NOT NULL,
+	indicator VARCHAR(512) NOT NULL,
+	description VARCHAR(256) NOT NULL,
+	user VARCHAR(64) NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT blocklist_pk PRIMARY KEY (team, indicator),
+	CONSTRAINT blocklist_team_fk FOREIGN KEY (team) REFERENCES teams (id)
+);
+CREATE TABLE IF NOT EXISTS intel_indicators (
+	team VARCHAR(64) NOT NULL,
+	stix_id VARCHAR(128) NOT NULL,
+	indicator_type INT NOT NULL,
+	value VARCHAR(512) NOT NULL,
+	name VARCHAR(256) NOT NULL,
+	valid_from DATETIME NOT NULL,
+	valid_until DATETIME NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT intel_indicators_pk PRIMARY KEY (team, stix_id, value),
+	INDEX intel_indicators_value_idx (team, value),
+	CONSTRAINT intel_indicators_team_fk FOREIGN KEY (team) REFERENCES teams (id)
+);
+CREATE TABLE IF NOT EXISTS taxii_state (
+	team VARCHAR(64) NOT NULL,
+	url VARCHAR(512) NOT NULL,
+	added_after VARCHAR(64) NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT taxii_state_pk PRIMARY KEY (team, url),
+	CONSTRAINT taxii_state_team_fk FOREIGN KEY (team) REFERENCES teams (id)
+);
+CREATE TABLE IF NOT EXISTS webhooks (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	team VARCHAR(64) NOT NULL,
+	url VARCHAR(512) NOT NULL,
+	secret VARCHAR(512) NOT NULL,
+	user VARCHAR(64) NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT webhooks_pk PRIMARY KEY (id),
+	CONSTRAINT webhooks_team_fk FOREIGN KEY (team) REFERENCES teams (id)
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	webhook BIGINT NOT NULL,
+	team VARCHAR(64) NOT NULL,
+	event_type VARCHAR(20) NOT NULL,
+	event LONGTEXT NOT NULL,
+	status VARCHAR(10) NOT NULL,
+	attempts INT NOT NULL,
+	last_error VARCHAR(512) NOT NULL,
+	next_attempt TIMESTAMP NULL,
+	claimed_by VARCHAR(128),
+	claimed_at TIMESTAMP NULL,
+	ts TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	delivered TIMESTAMP NULL,
+	CONSTRAINT webhook_deliveries_pk PRIMARY KEY (id),
+	INDEX webhook_deliveries_due_idx (status, next_attempt),
+	CONSTRAINT webhook_deliveries_webhook_fk FOREIGN KEY (webhook) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS feedback (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	team VARCHAR(64) NOT NULL,
+	user VARCHAR(64) NOT NULL,
+	channel VARCHAR(64) NOT NULL,
+	message_id VARCHAR(64) NOT NULL,
+	indicator TEXT NOT NULL,
+	indicator_type VARCHAR(10) NOT NULL,
+	kind VARCHAR(20) NOT NULL,
+	ts TIMESTAMP NOT NULL,
+	CONSTRAINT feedback_pk PRIMARY KEY (id),
+	CONSTRAINT feedback_team_fk FOREIGN KEY (team) REFERENCES teams (id)
 )
 `
 
+// migrations of tables created by older versions - a duplicate column error means it was already applied.
+// Modifying a column that already has the new type changes nothing.
+const migrations = `
+ALTER TABLE queue ADD COLUMN claimed_by VARCHAR(128);
+ALTER TABLE queue ADD COLUMN claimed_at TIMESTAMP NULL;
+ALTER TABLE queue ADD COLUMN retries INT NOT NULL DEFAULT 0;
+ALTER TABLE queue ADD COLUMN priority INT NOT NULL DEFAULT 0;
+ALTER TABLE convicted ADD COLUMN intel VARCHAR(128);
+ALTER TABLE convicted DROP PRIMARY KEY, ADD COLUMN id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY FIRST,
+	ADD CONSTRAINT convicted_content_uk UNIQUE (team, channel, message_id, content_type, content);
+ALTER TABLE intel_indicators MODIFY valid_from DATETIME NOT NULL;
+ALTER TABLE intel_indicators MODIFY valid_until DATETIME NULL;
+ALTER TABLE team_statistics ADD COLUMN domains_clean BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE team_statistics ADD COLUMN domains_dirty BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE team_statistics ADD COLUMN domains_unknown BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE statistics_buckets ADD COLUMN domains_clean BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE statistics_buckets ADD COLUMN domains_dirty BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE statistics_buckets ADD COLUMN domains_unknown BIGINT NOT NULL DEFAULT 0
+`
+
 var (
 	// ErrNotFound is a not found error if Get does not retrieve a value
 	ErrNotFound = errors.New("not_found")
 )
 
 type MySQL struct {
-	db   *sqlx.DB
-	stop chan bool
+	db           *sqlx.DB
+	stop         chan bool
+	stopOnce     sync.Once
+	totalMu      sync.Mutex // Guards the cached total messages
+	total        int
+	totalExpires time.Time
 }
 
 // NewMySQL repo is returned
@@ -197,6 +371,14 @@ func NewMySQL() (*MySQL, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Schema changes are committed implicitly by MySQL so there is no point in a transaction
+	for _, migration := range strings.Split(migrations, ";") {
+		if _, err = db.Exec(migration); err != nil {
+			if mysqlErr, ok := err.(*mysql.MySQLError); !ok || mysqlErr.Number != 1060 {
+				return nil, err
+			}
+		}
+	}
 	r := &MySQL{
 		db:   db,
 		stop: make(chan bool, 1),
@@ -208,7 +390,8 @@ func NewMySQL() (*MySQL, error) {
 }
 
 func (r *MySQL) Close() error {
-	r.stop <- true
+	// Closing lets all the background jobs know
+	r.stopOnce.Do(func() { close(r.stop) })
 	return r.db.Close()
 }
 
@@ -413,7 +596,7 @@ af_key = ?`,
 		}
 		_, err = tx.Exec(`INSERT INTO users
 (id, team, name, type, status, real_name, email, is_bot, is_admin, is_owner, is_primary_owner, is_restricted, is_ultra_restricted, external_id, token, created)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON DUPLICATE KEY UPDATE
 team = ?,
 name = ?,
@@ -468,32 +651,36 @@ func (r *MySQL) cleanOAuthStateAndQueue() {
 	for {
 		select {
 		case <-r.stop:
-			break
+			return
 		case <-ticker.C:
-			oauthRes, err := r.db.Exec("DELETE FROM oauth_state WHERE ts < ?", time.Now().Add(-5*time.Minute))
-			if err != nil {
-				logrus.WithError(err).Warnln("Unable to delete OAuth state")
-				break
-			} else {
-				rows, err := oauthRes.RowsAffected()
-				if err == nil {
-					logrus.Debugf("Cleaned %v oauth states", rows)
-				}
-			}
-			qRes, err := r.db.Exec("DELETE FROM queue WHERE ts < ?", time.Now().Add(-1*time.Hour))
-			if err != nil {
-				logrus.WithError(err).Warnln("Unable to delete queue messages")
-				break
-			} else {
-				rows, err := qRes.RowsAffected()
-				if err == nil {
-					logrus.Debugf("Cleaned %v old messages", rows)
-				}
+			r.clean("oauth states", "DELETE FROM oauth_state WHERE ts < ?", time.Now().Add(-5*time.Minute))
+			// Work requests are leased, retried and dead lettered by the queue so only replies and conf messages
+			// nobody picked up are cleaned
+			r.clean("old messages", "DELETE FROM queue WHERE message_type <> 'work' AND ts < ?", time.Now().Add(-1*time.Hour))
+			r.clean("old Slack events", "DELETE FROM slack_events WHERE ts < ?", time.Now().Add(-eventsExpiry))
+			r.clean("old bot replies", "DELETE FROM bot_replies WHERE ts < ?", time.Now().Add(-repliesExpiry))
+			r.clean("expired intel indicators", "DELETE FROM intel_indicators WHERE valid_until < ?", time.Now())
+			if err := r.rollupStatistics(domain.StatsBucket(time.Now().Add(-hourlyStatsRetention), domain.StatsDay)); err != nil {
+				logrus.WithError(err).Warnln("Unable to roll up hourly statistics")
 			}
+			r.clean("old webhook deliveries", "DELETE FROM webhook_deliveries WHERE status <> ? AND ts < ?", domain.DeliveryPending, time.Now().Add(-deliveriesExpiry))
+			r.clean("expired cached results", "DELETE FROM reputation_cache WHERE expires < ?", time.Now())
 		}
 	}
 }
 
+// clean with the delete statement - a failure is logged and does not keep the other cleanups from running
+func (r *MySQL) clean(what, query string, args ...interface{}) {
+	res, err := r.db.Exec(query, args...)
+	if err != nil {
+		logrus.WithError(err).Warnf("Unable to delete %s", what)
+		return
+	}
+	if rows, err := res.RowsAffected(); err == nil {
+		logrus.Debugf("Cleaned %v %s", rows, what)
+	}
+}
+
 func (r *MySQL) ChannelsAndGroups(team string) (*domain.Configuration, error) {
 	res := &domain.Configuration{Team: team}
 	var all []string
@@ -516,8 +703,31 @@ func (r *MySQL) ChannelsAndGroups(team string) (*domain.Configuration, error) {
 			res.VerboseGroups = append(res.VerboseGroups, s[1:])
 		case 'Z':
 			res.VerboseIM = true
+		case 'P':
+			res.Providers = append(res.Providers, s[1:])
 		}
 	}
+	if err != nil {
+		return res, err
+	}
+	res.Allowlist, err = r.Allowlist(team)
+	if err != nil {
+		return res, err
+	}
+	res.Blocklist, err = r.Blocklist(team)
+	if err != nil {
+		return res, err
+	}
+	var policy string
+	err = r.db.Get(&policy, "SELECT policy FROM verdict_policies WHERE team = ?", team)
+	if err == sql.ErrNoRows {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	res.Policy = &conf.VerdictPolicies{}
+	err = json.Unmarshal([]byte(policy), res.Policy)
 	return res, err
 }
 
@@ -531,8 +741,12 @@ func (r *MySQL) SetChannelsAndGroups(configuration *domain.Configuration) error
 	var all []string
 	all = append(all, configuration.Channels...)
 	all = append(all, configuration.Groups...)
-	// First, delete the configuration for the user
-	_, err = tx.Exec("DELETE FROM configurations WHERE team = ?", configuration.Team)
+	// First, delete the configuration for the user - the providers only if they are given
+	if configuration.Providers != nil {
+		_, err = tx.Exec("DELETE FROM configurations WHERE team = ?", configuration.Team)
+	} else {
+		_, err = tx.Exec("DELETE FROM configurations WHERE team = ? AND channel NOT LIKE 'P%'", configuration.Team)
+	}
 	if err != nil {
 		return err
 	}
@@ -583,9 +797,180 @@ func (r *MySQL) SetChannelsAndGroups(configuration *domain.Configuration) error
 			return err
 		}
 	}
+	for i := range configuration.Providers {
+		_, err = stmt.Exec(configuration.Team, "P"+configuration.Providers[i])
+		if err != nil {
+			return err
+		}
+	}
+	// Without a policy we keep the one the team has
+	if configuration.Policy != nil {
+		_, err = tx.Exec("DELETE FROM verdict_policies WHERE team = ?", configuration.Team)
+		if err != nil {
+			return err
+		}
+		policy, err := json.Marshal(configuration.Policy)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO verdict_policies (team, policy) VALUES (?, ?)", configuration.Team, string(policy))
+		if err != nil {
+			return err
+		}
+	}
 	return tx.Commit()
 }
 
+// AddToAllowlist marks the indicator as clean for the team
+func (r *MySQL) AddToAllowlist(team, indicator, user string) error {
+	_, err := r.db.Exec("INSERT INTO allowlist (team, indicator, user, ts) VALUES (?, ?, ?, now())", team, indicator, user)
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+		// Someone already added it
+		return nil
+	}
+	return err
+}
+
+// RemoveFromAllowlist removes the entry from the team allowlist
+func (r *MySQL) RemoveFromAllowlist(team, indicator string) error {
+	_, err := r.db.Exec("DELETE FROM allowlist WHERE team = ? AND indicator = ?", team, indicator)
+	return err
+}
+
+// Allowlist of the team ordered by the entries
+func (r *MySQL) Allowlist(team string) ([]string, error) {
+	var res []string
+	err := r.db.Select(&res, "SELECT indicator FROM allowlist WHERE team = ? ORDER BY indicator", team)
+	return res, err
+}
+
+// AddToBlocklist adds the entries to the team blocklist replacing the description of existing ones
+func (r *MySQL) AddToBlocklist(team, user string, entries []domain.BlocklistEntry) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO blocklist (team, indicator, description, user, ts) VALUES (?, ?, ?, ?, now())
+ON DUPLICATE KEY UPDATE description = VALUES(description), user = VALUES(user), ts = VALUES(ts)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i := range entries {
+		if _, err = stmt.Exec(team, entries[i].Indicator, util.Substr(entries[i].Description, 0, 256), user); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveFromBlocklist removes the entry from the team blocklist
+func (r *MySQL) RemoveFromBlocklist(team, indicator string) error {
+	_, err := r.db.Exec("DELETE FROM blocklist WHERE team = ? AND indicator = ?", team, indicator)
+	return err
+}
+
+// Blocklist of the team ordered by the entries
+func (r *MySQL) Blocklist(team string) ([]domain.BlocklistEntry, error) {
+	var res []domain.BlocklistEntry
+	err := r.db.Select(&res, "SELECT indicator, description FROM blocklist WHERE team = ? ORDER BY indicator", team)
+	return res, err
+}
+
+// StoreIntel indicators of the team replacing the name and validity of the ones we already have
+func (r *MySQL) StoreIntel(team string, indicators []domain.IntelIndicator) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO intel_indicators (team, stix_id, indicator_type, value, name, valid_from, valid_until, ts) VALUES (?, ?, ?, ?, ?, ?, ?, now())
+ON DUPLICATE KEY UPDATE name = VALUES(name), valid_from = VALUES(valid_from), valid_until = VALUES(valid_until), ts = VALUES(ts)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i := range indicators {
+		_, err = stmt.Exec(team, indicators[i].STIXID, indicators[i].Type, indicators[i].Value, util.Substr(indicators[i].Name, 0, 256),
+			indicators[i].ValidFrom, indicators[i].ValidUntil)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const intelColumns = "team, stix_id, indicator_type, value, name, valid_from, valid_until"
+
+// IntelIndicator that is currently valid for the team with the given type and value
+func (r *MySQL) IntelIndicator(team string, indicatorType int, value string) (*domain.IntelIndicator, error) {
+	res := &domain.IntelIndicator{}
+	err := r.db.Get(res, "SELECT "+intelColumns+` FROM intel_indicators
+WHERE team = ? AND value = ? AND indicator_type = ? AND valid_from <= ? AND (valid_until IS NULL OR valid_until > ?) LIMIT 1`,
+		team, value, indicatorType, time.Now(), time.Now())
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return res, err
+}
+
+// IntelNetworks are the currently valid CIDR indicators of the team
+func (r *MySQL) IntelNetworks(team string) ([]domain.IntelIndicator, error) {
+	var res []domain.IntelIndicator
+	err := r.db.Select(&res, "SELECT "+intelColumns+` FROM intel_indicators
+WHERE team = ? AND indicator_type = ? AND value LIKE '%/%' AND valid_from <= ? AND (valid_until IS NULL OR valid_until > ?)`,
+		team, domain.ReplyTypeIP, time.Now(), time.Now())
+	return res, err
+}
+
+// TAXIIAddedAfter returns the time the last object we got from the collection was added - empty if we never polled it
+func (r *MySQL) TAXIIAddedAfter(team, url string) (string, error) {
+	var res string
+	err := r.db.Get(&res, "SELECT added_after FROM taxii_state WHERE team = ? AND url = ?", team, url)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return res, err
+}
+
+// SetTAXIIAddedAfter stores the time the last object we got from the collection was added
+func (r *MySQL) SetTAXIIAddedAfter(team, url, addedAfter string) error {
+	_, err := r.db.Exec(`INSERT INTO taxii_state (team, url, added_after, ts) VALUES (?, ?, ?, now())
+ON DUPLICATE KEY UPDATE added_after = VALUES(added_after), ts = VALUES(ts)`, team, url, addedAfter)
+	return err
+}
+
+// Lock takes the named MySQL lock without waiting so only one web node runs a background job at a time.
+// MySQL keeps the lock for the session so it is taken on a connection of its own - call unlock to release it.
+// ok is false if another node holds the lock.
+func (r *MySQL) Lock(name string) (unlock func(), ok bool, err error) {
+	ctx := context.Background()
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var res sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&res); err != nil || res.Int64 != 1 {
+		conn.Close()
+		return nil, false, err
+	}
+	unlock = func() {
+		if err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", name).Scan(&res); err != nil {
+			logrus.WithError(err).Warnf("Unable to release lock %s", name)
+		}
+		conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// StoreFeedback of a user on a verdict
+func (r *MySQL) StoreFeedback(feedback *domain.Feedback) error {
+	_, err := r.db.Exec(`INSERT INTO feedback (team, user, channel, message_id, indicator, indicator_type, kind, ts)
+VALUES (?, ?, ?, ?, ?, ?, ?, now())`, feedback.Team, feedback.User, feedback.Channel, feedback.MessageID, feedback.Indicator, feedback.IndicatorType, feedback.Kind)
+	return err
+}
+
 func (r *MySQL) IsVerboseChannel(team, channel string) (bool, error) {
 	var count int
 	if team == "" || channel == "" {
@@ -610,109 +995,368 @@ func (r *MySQL) BotHeartbeat() error {
 	return err
 }
 
-func (r *MySQL) updateStats(stats *domain.Statistics, oldTimestamp time.Time) error {
-	var rows int64
-	for count := 5; rows == 0 && count > 0; count-- {
-		res, err := r.db.Exec(`UPDATE team_statistics SET
-ts = now(),
-messages = messages + ?,
-files_clean = files_clean + ?,
-files_dirty = files_dirty + ?,
-files_unknown = files_unknown + ?,
-urls_clean = urls_clean + ?,
-urls_dirty = urls_dirty + ?,
-urls_unknown = urls_unknown + ?,
-hashes_clean = hashes_clean + ?,
-hashes_dirty = hashes_dirty + ?,
-hashes_unknown = hashes_unknown + ?,
-ips_clean = ips_clean + ?,
-ips_dirty = ips_dirty + ?,
-ips_unknown = ips_unknown + ?
-WHERE team = ? AND ts = ?`,
-			stats.Messages, stats.FilesClean, stats.FilesDirty, stats.FilesUnknown, stats.URLsClean, stats.URLsDirty, stats.URLsUnknown,
-			stats.HashesClean, stats.HashesDirty, stats.HashesUnknown, stats.IPsClean, stats.IPsDirty, stats.IPsUnknown, stats.Team, oldTimestamp)
-		if err != nil {
-			return err
-		}
-		rows, err = res.RowsAffected()
-		if err != nil {
-			return err
-		}
-		if rows == 0 {
-			err = r.db.Get(&oldTimestamp, "SELECT ts FROM team_statistics WHERE team = ?", stats.Team)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+// statsColumns are the counters of the statistics tables
+const statsColumns = "messages, files_clean, files_dirty, files_unknown, urls_clean, urls_dirty, urls_unknown, hashes_clean, hashes_dirty, hashes_unknown, ips_clean, ips_dirty, ips_unknown, domains_clean, domains_dirty, domains_unknown"
+
+// hourlyStatsRetention is how long the hourly buckets are kept before they are rolled up into daily buckets
+const hourlyStatsRetention = 30 * 24 * time.Hour
+
+func statsArgs(stats *domain.Statistics) []interface{} {
+	return []interface{}{stats.Messages, stats.FilesClean, stats.FilesDirty, stats.FilesUnknown, stats.URLsClean, stats.URLsDirty, stats.URLsUnknown,
+		stats.HashesClean, stats.HashesDirty, stats.HashesUnknown, stats.IPsClean, stats.IPsDirty, stats.IPsUnknown,
+		stats.DomainsClean, stats.DomainsDirty, stats.DomainsUnknown}
 }
 
+// upsertStatsBucket adds the counters to the bucket of the team
+func upsertStatsBucket(db sqlx.Execer, stats *domain.Statistics, period string, bucket time.Time) error {
+	_, err := db.Exec(`INSERT INTO statistics_buckets (team, period, bucket, `+statsColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE messages = messages + VALUES(messages),
+files_clean = files_clean + VALUES(files_clean), files_dirty = files_dirty + VALUES(files_dirty), files_unknown = files_unknown + VALUES(files_unknown),
+urls_clean = urls_clean + VALUES(urls_clean), urls_dirty = urls_dirty + VALUES(urls_dirty), urls_unknown = urls_unknown + VALUES(urls_unknown),
+hashes_clean = hashes_clean + VALUES(hashes_clean), hashes_dirty = hashes_dirty + VALUES(hashes_dirty), hashes_unknown = hashes_unknown + VALUES(hashes_unknown),
+ips_clean = ips_clean + VALUES(ips_clean), ips_dirty = ips_dirty + VALUES(ips_dirty), ips_unknown = ips_unknown + VALUES(ips_unknown),
+domains_clean = domains_clean + VALUES(domains_clean), domains_dirty = domains_dirty + VALUES(domains_dirty), domains_unknown = domains_unknown + VALUES(domains_unknown)`,
+		append([]interface{}{stats.Team, period, bucket}, statsArgs(stats)...)...)
+	return err
+}
+
+// UpdateStatistics adds the counters to the current hourly bucket of the team
 func (r *MySQL) UpdateStatistics(stats *domain.Statistics) error {
 	if stats == nil || !stats.HasSomething() {
 		return nil
 	}
-	// Can be probably done via UPSERT
-	// The code selects current timestamp. If there is no row for the team, we try to insert. If insert fails (because someone already inserted this team) then move to updates.
-	// The updates try to update the row while making sure that the timestamp is the same as we selected. If someone changed data, we will need to re-select timestmap to prevent lost updates.
-	var oldTimestamp time.Time
-	err := r.db.Get(&oldTimestamp, "SELECT ts FROM team_statistics WHERE team = ?", stats.Team)
+	return upsertStatsBucket(r.db, stats, domain.StatsHour, domain.StatsBucket(time.Now(), domain.StatsHour))
+}
+
+// rollupStatistics moves the hourly buckets before the given time into daily buckets
+func (r *MySQL) rollupStatistics(before time.Time) error {
+	tx, err := r.db.Beginx()
 	if err != nil {
-		if err != sql.ErrNoRows {
-			return err
+		return err
+	}
+	defer tx.Rollback()
+	var hourly []domain.Statistics
+	// Locking the rows makes other web servers wait so the buckets are not rolled up twice
+	err = tx.Select(&hourly, "SELECT team, bucket as ts, "+statsColumns+" FROM statistics_buckets WHERE period = ? AND bucket < ? FOR UPDATE",
+		domain.StatsHour, before)
+	if err != nil || len(hourly) == 0 {
+		return err
+	}
+	daily := make(map[string]map[time.Time]*domain.Statistics)
+	for i := range hourly {
+		day := domain.StatsBucket(hourly[i].Timestamp, domain.StatsDay)
+		if daily[hourly[i].Team] == nil {
+			daily[hourly[i].Team] = make(map[time.Time]*domain.Statistics)
 		}
-		_, err := r.db.Exec(`INSERT INTO team_statistics
-(team, ts, messages, files_clean, files_dirty, files_unknown, urls_clean, urls_dirty, urls_unknown, hashes_clean, hashes_dirty, hashes_unknown, ips_clean, ips_dirty, ips_unknown)
-VALUES (?, now(), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			stats.Team, stats.Messages, stats.FilesClean, stats.FilesDirty, stats.FilesUnknown, stats.URLsClean, stats.URLsDirty, stats.URLsUnknown,
-			stats.HashesClean, stats.HashesDirty, stats.HashesUnknown, stats.IPsClean, stats.IPsDirty, stats.IPsUnknown)
-		if err != nil {
-			switch mysqlErr := err.(type) {
-			case *mysql.MySQLError:
-				// Duplicate key because someone already inserted stats for team
-				if mysqlErr.Number == 1062 {
-					// Do select again and then update
-					err = r.db.Get(&oldTimestamp, "SELECT ts FROM team_statistics WHERE team = ?", stats.Team)
-					if err != nil {
-						return err
-					}
-					return r.updateStats(stats, oldTimestamp)
-				}
+		if daily[hourly[i].Team][day] == nil {
+			daily[hourly[i].Team][day] = &domain.Statistics{Team: hourly[i].Team}
+		}
+		daily[hourly[i].Team][day].Add(&hourly[i])
+	}
+	for _, days := range daily {
+		for day, stats := range days {
+			if err = upsertStatsBucket(tx, stats, domain.StatsDay, day); err != nil {
+				return err
 			}
-			return err
 		}
-		return nil
 	}
-	return r.updateStats(stats, oldTimestamp)
+	if _, err = tx.Exec("DELETE FROM statistics_buckets WHERE period = ? AND bucket < ?", domain.StatsHour, before); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *MySQL) Statistics(team string) (*domain.Statistics, error) {
-	stats := &domain.Statistics{}
-	err := r.db.Get(stats, "SELECT * FROM team_statistics WHERE team = ?", team)
+// StatisticsBuckets of the team between from and to in buckets of the interval - hour, day or week. All the teams if team is empty.
+// Hours that were already rolled up are counted at the start of their day.
+func (r *MySQL) StatisticsBuckets(team string, from, to time.Time, interval string) ([]domain.Statistics, error) {
+	query, args := "SELECT team, bucket as ts, "+statsColumns+" FROM statistics_buckets WHERE bucket >= ? AND bucket < ?", []interface{}{from.UTC(), to.UTC()}
+	if team != "" {
+		query, args = query+" AND team = ?", append(args, team)
+	}
+	var rows []domain.Statistics
+	if err := r.db.Select(&rows, query+" ORDER BY bucket", args...); err != nil {
+		return nil, err
+	}
+	if team == "" {
+		team = "Global"
+	}
+	var res []domain.Statistics
+	for i := range rows {
+		bucket := domain.StatsBucket(rows[i].Timestamp, interval)
+		if len(res) == 0 || !res[len(res)-1].Timestamp.Equal(bucket) {
+			res = append(res, domain.Statistics{Team: team, Timestamp: bucket})
+		}
+		res[len(res)-1].Add(&rows[i])
+	}
+	return res, nil
+}
+
+// totalStatistics of the team or all the teams if empty. The team_statistics table holds the totals from before the buckets.
+func (r *MySQL) totalStatistics(team string) (*domain.Statistics, error) {
+	where, args := "", []interface{}{}
+	if team != "" {
+		where, args = " WHERE team = ?", []interface{}{team, team}
+	}
+	sums := make([]string, 0, 13)
+	for _, c := range strings.Split(statsColumns, ", ") {
+		sums = append(sums, "ifnull(sum("+c+"), 0) as "+c)
+	}
+	stats := &domain.Statistics{Team: team}
+	err := r.db.Get(stats, "SELECT "+strings.Join(sums, ", ")+" FROM (SELECT "+statsColumns+" FROM team_statistics"+where+
+		" UNION ALL SELECT "+statsColumns+" FROM statistics_buckets"+where+") s", args...)
 	return stats, err
 }
 
+// Statistics of the team since it joined
+func (r *MySQL) Statistics(team string) (*domain.Statistics, error) {
+	return r.totalStatistics(team)
+}
+
+// GlobalStatistics of all the teams
 func (r *MySQL) GlobalStatistics() (*domain.Statistics, error) {
-	// Notice - this will not work if there are no statistics at all in the DB
-	stats := &domain.Statistics{}
-	err := r.db.Get(stats, `SELECT 'Global' as team, sum(messages) as messages,
-sum(files_clean) as clean_files, sum(files_dirty) as files_dirty, sum(files_unknown) as files_unknown,
-sum(urls_clean) as urls_clean, sum(urls_dirty) as urls_dirty, sum(urls_unknown) as urls_unknown,
-sum(hashes_clean) as hashes_clean, sum(hashes_dirty) as hashes_dirty, sum(hashes_unknown) as hashes_unknown,
-sum(ips_clean) as ips_clean, sum(ips_dirty) as ips_dirty, sum(ips_unknown) as ips_unknown FROM team_statistics`)
-	return stats, err
+	stats, err := r.totalStatistics("")
+	if err != nil {
+		return nil, err
+	}
+	stats.Team = "Global"
+	return stats, nil
 }
 
+// totalMessagesTTL is how long the total number of messages is cached - it is shown to anyone so it must be cheap
+const totalMessagesTTL = time.Minute
+
+// TotalMessages we ever handled, cached for totalMessagesTTL
 func (r *MySQL) TotalMessages() (int, error) {
-	var sum int
-	err := r.db.Get(&sum, `SELECT ifnull(sum(messages), 0) FROM team_statistics`)
-	return sum, err
+	r.totalMu.Lock()
+	defer r.totalMu.Unlock()
+	if time.Now().Before(r.totalExpires) {
+		return r.total, nil
+	}
+	stats, err := r.totalStatistics("")
+	if err != nil {
+		return 0, err
+	}
+	r.total, r.totalExpires = int(stats.Messages), time.Now().Add(totalMessagesTTL)
+	return r.total, nil
 }
 
+// StoreMaliciousContent of a message. Convicting the same indicator of the message again, like a rescan does, updates the scores.
 func (r *MySQL) StoreMaliciousContent(convicted *domain.MaliciousContent) error {
-	_, err := r.db.Exec("INSERT INTO convicted (team, channel, message_id, ts, content_type, content, file_name, vt, xfe, clamav, cy, af) VALUES (?, ?, ?, now(), ?, ?, ?, ?, ?, ?, ?, ?)",
+	return storeMaliciousContent(r.db, convicted)
+}
+
+// StoreConvicted content and queue the event for the webhooks of the team in one transaction so every conviction,
+// also of an indicator convicted before, is delivered exactly once
+func (r *MySQL) StoreConvicted(convicted *domain.MaliciousContent, event []byte) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err = storeMaliciousContent(tx, convicted); err != nil {
+		return err
+	}
+	if err = queueWebhookEvent(tx, convicted.Team, domain.EventConvicted, event); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func storeMaliciousContent(db sqlx.Execer, convicted *domain.MaliciousContent) error {
+	_, err := db.Exec(`INSERT INTO convicted (team, channel, message_id, ts, content_type, content, file_name, vt, xfe, clamav, cy, af, intel) VALUES (?, ?, ?, now(), ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE file_name = VALUES(file_name), vt = VALUES(vt), xfe = VALUES(xfe), clamav = VALUES(clamav), cy = VALUES(cy), af = VALUES(af), intel = VALUES(intel)`,
 		convicted.Team, convicted.Channel, convicted.MessageID, convicted.ContentType, util.Substr(convicted.Content, 0, 128), util.Substr(convicted.FileName, 0, 128),
-		util.Substr(convicted.VT, 0, 128), util.Substr(convicted.XFE, 0, 128), util.Substr(convicted.ClamAV, 0, 128), util.Substr(convicted.Cy, 0, 128), util.Substr(convicted.AF, 0, 128))
+		util.Substr(convicted.VT, 0, 128), util.Substr(convicted.XFE, 0, 128), util.Substr(convicted.ClamAV, 0, 128), util.Substr(convicted.Cy, 0, 128), util.Substr(convicted.AF, 0, 128),
+		util.Substr(convicted.Intel, 0, 128))
+	return err
+}
+
+// Convicted content of the team in the given time range ordered by time
+func (r *MySQL) Convicted(team string, from, to time.Time) ([]domain.MaliciousContent, error) {
+	res, _, err := r.QueryConvicted(team, &domain.ConvictedQuery{From: from, To: to})
+	return res, err
+}
+
+// QueryConvicted returns the page of the convicted content of the team matching the query ordered by time
+// and the total number of matching content
+func (r *MySQL) QueryConvicted(team string, q *domain.ConvictedQuery) ([]domain.MaliciousContent, int, error) {
+	where, args := "team = ?", []interface{}{team}
+	if q.Channel != "" {
+		where, args = where+" AND channel = ?", append(args, q.Channel)
+	}
+	if q.ContentType != 0 {
+		where, args = where+" AND content_type = ?", append(args, q.ContentType)
+	}
+	if q.Provider != "" {
+		if !util.In(domain.ConvictedProviders, q.Provider) {
+			return nil, 0, fmt.Errorf("unknown provider %s", q.Provider)
+		}
+		// The provider is one of the known columns so it is safe to use
+		where += " AND ifnull(" + q.Provider + ", '') <> ''"
+	}
+	if !q.From.IsZero() {
+		where, args = where+" AND ts >= ?", append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		where, args = where+" AND ts < ?", append(args, q.To)
+	}
+	var total int
+	if err := r.db.Get(&total, "SELECT count(*) FROM convicted WHERE "+where, args...); err != nil {
+		return nil, 0, err
+	}
+	query := `SELECT team, channel, message_id, ts, content_type, content, ifnull(file_name, '') as file_name,
+ifnull(vt, '') as vt, ifnull(xfe, '') as xfe, ifnull(clamav, '') as clamav, ifnull(cy, '') as cy, ifnull(af, '') as af, ifnull(intel, '') as intel
+FROM convicted WHERE ` + where + " ORDER BY ts, channel, message_id"
+	if q.Limit > 0 {
+		query, args = query+" LIMIT ? OFFSET ?", append(args, q.Limit, q.Offset)
+	}
+	var res []domain.MaliciousContent
+	err := r.db.Select(&res, query, args...)
+	return res, total, err
+}
+
+// deliveriesExpiry is how long we keep the log of finished webhook deliveries
+const deliveriesExpiry = 30 * 24 * time.Hour
+
+// AddWebhook for the team - the secret is stored encrypted and the ID of the new webhook is set
+func (r *MySQL) AddWebhook(w *domain.Webhook) error {
+	secret, err := util.Encrypt(w.Secret, conf.Options.Security.DBKey)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.Exec("INSERT INTO webhooks (team, url, secret, user, ts) VALUES (?, ?, ?, ?, now())", w.Team, w.URL, secret, w.User)
+	if err != nil {
+		return err
+	}
+	w.ID, err = res.LastInsertId()
+	return err
+}
+
+// RemoveWebhook of the team together with its deliveries
+func (r *MySQL) RemoveWebhook(team string, id int64) error {
+	res, err := r.db.Exec("DELETE FROM webhooks WHERE team = ? AND id = ?", team, id)
+	if err != nil {
+		return err
+	}
+	if c, err := res.RowsAffected(); err == nil && c == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Webhooks of the team without their secrets
+func (r *MySQL) Webhooks(team string) ([]domain.Webhook, error) {
+	var res []domain.Webhook
+	err := r.db.Select(&res, "SELECT id, team, url, user, ts FROM webhooks WHERE team = ? ORDER BY id", team)
+	return res, err
+}
+
+// QueueWebhookEvent for delivery to every webhook of the team
+func (r *MySQL) QueueWebhookEvent(team, eventType string, event []byte) error {
+	return queueWebhookEvent(r.db, team, eventType, event)
+}
+
+func queueWebhookEvent(db sqlx.Execer, team, eventType string, event []byte) error {
+	_, err := db.Exec(`INSERT INTO webhook_deliveries (webhook, team, event_type, event, status, attempts, last_error, next_attempt, ts)
+SELECT id, team, ?, ?, ?, 0, '', now(), now() FROM webhooks WHERE team = ?`, eventType, string(event), domain.DeliveryPending, team)
+	return err
+}
+
+// ClaimWebhookDeliveries leases up to limit pending deliveries that are due with the URL and clear secret of their webhook.
+// Claimed deliveries must be marked as delivered or failed.
+func (r *MySQL) ClaimWebhookDeliveries(lease time.Duration, limit int) ([]domain.WebhookDelivery, error) {
+	claim := util.Hostname + ":" + util.SecureRandomString(16, false)
+	res, err := r.db.Exec(`UPDATE webhook_deliveries SET claimed_by = ?, claimed_at = now()
+WHERE status = ? AND next_attempt <= ? AND (claimed_by IS NULL OR claimed_at < ?) ORDER BY next_attempt LIMIT ?`,
+		claim, domain.DeliveryPending, time.Now(), time.Now().Add(-lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	if c, err := res.RowsAffected(); err != nil || c == 0 {
+		return nil, err
+	}
+	var deliveries []domain.WebhookDelivery
+	err = r.db.Select(&deliveries, `SELECT d.id, d.webhook, w.url, w.secret, d.team, d.event_type, d.event, d.status, d.attempts, d.last_error,
+d.next_attempt, d.ts, d.delivered FROM webhook_deliveries d JOIN webhooks w ON d.webhook = w.id WHERE d.claimed_by = ? ORDER BY d.id`, claim)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deliveries {
+		if deliveries[i].Secret, err = util.Decrypt(deliveries[i].Secret, conf.Options.Security.DBKey); err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, nil
+}
+
+// WebhookDelivered marks the claimed delivery as accepted by the webhook
+func (r *MySQL) WebhookDelivered(id int64) error {
+	_, err := r.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, last_error = '', next_attempt = NULL,
+claimed_by = NULL, claimed_at = NULL, delivered = now() WHERE id = ?`, domain.DeliveryDelivered, id)
+	return err
+}
+
+// WebhookAttemptFailed records the error of the claimed delivery and schedules the next attempt.
+// Without a next attempt the delivery is marked as failed.
+func (r *MySQL) WebhookAttemptFailed(d *domain.WebhookDelivery, reason string, nextAttempt *time.Time) error {
+	status := domain.DeliveryPending
+	if nextAttempt == nil {
+		status = domain.DeliveryFailed
+	}
+	_, err := r.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, last_error = ?, next_attempt = ?,
+claimed_by = NULL, claimed_at = NULL WHERE id = ?`, status, d.Attempts+1, util.Substr(reason, 0, 512), nextAttempt, d.ID)
+	return err
+}
+
+// WebhookDeliveries of the team - the latest first
+func (r *MySQL) WebhookDeliveries(team string, limit int) ([]domain.WebhookDelivery, error) {
+	var res []domain.WebhookDelivery
+	err := r.db.Select(&res, `SELECT d.id, d.webhook, w.url, d.team, d.event_type, d.event, d.status, d.attempts, d.last_error,
+d.next_attempt, d.ts, d.delivered FROM webhook_deliveries d JOIN webhooks w ON d.webhook = w.id WHERE d.team = ? ORDER BY d.id DESC LIMIT ?`, team, limit)
+	return res, err
+}
+
+// eventsExpiry is how long we remember Slack events - Slack stops retrying well before that
+const eventsExpiry = time.Hour
+
+// MarkEventSeen records the Slack event and returns false if we have already seen it
+func (r *MySQL) MarkEventSeen(eventID string) (bool, error) {
+	_, err := r.db.Exec("INSERT INTO slack_events (event_id, ts) VALUES (?, now())", eventID)
+	if err != nil {
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// repliesExpiry is how long edits of a message still update the bot reply instead of posting a new one
+const repliesExpiry = 7 * 24 * time.Hour
+
+// BotReply returns the reply the bot posted for the message or ErrNotFound
+func (r *MySQL) BotReply(team, channel, messageID string) (*domain.BotReply, error) {
+	reply := &domain.BotReply{}
+	err := r.db.Get(reply, "SELECT * FROM bot_replies WHERE team = ? AND channel = ? AND message_id = ?", team, channel, messageID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// SetBotReply records the reply the bot posted for the message
+func (r *MySQL) SetBotReply(reply *domain.BotReply) error {
+	_, err := r.db.Exec(`INSERT INTO bot_replies (team, channel, message_id, reply_ts, ts) VALUES (?, ?, ?, ?, now())
+ON DUPLICATE KEY UPDATE reply_ts = ?, ts = now()`, reply.Team, reply.Channel, reply.MessageID, reply.ReplyTS, reply.ReplyTS)
+	return err
+}
+
+// DelBotReply forgets the reply the bot posted for the message
+func (r *MySQL) DelBotReply(team, channel, messageID string) error {
+	_, err := r.db.Exec("DELETE FROM bot_replies WHERE team = ? AND channel = ? AND message_id = ?", team, channel, messageID)
 	return err
 }
 
@@ -769,9 +1413,92 @@ func (r *MySQL) QueueMessages(names []string, messageType string) (messages []*d
 	return
 }
 
+// ClaimMessages leases up to limit messages of the given type and priority that are not claimed or whose lease expired.
+// Claimed messages must be acked or nacked.
+func (r *MySQL) ClaimMessages(names []string, messageType string, priority int, lease time.Duration, limit int) ([]*domain.DBQueueMessage, error) {
+	claim := util.Hostname + ":" + util.SecureRandomString(16, false)
+	// Expired leases count as a retry. Retries must be updated first as MySQL assigns from left to right.
+	query := `UPDATE queue SET retries = retries + IF(claimed_by IS NULL, 0, 1), claimed_by = ?, claimed_at = now()
+WHERE message_type = ? AND priority = ? AND (claimed_by IS NULL OR claimed_at < ?)`
+	args := []interface{}{claim, messageType, priority, time.Now().Add(-lease)}
+	if len(names) > 0 {
+		query += " AND name IN (?" + strings.Repeat(",?", len(names)-1) + ")"
+		for _, name := range names {
+			args = append(args, name)
+		}
+	}
+	res, err := r.db.Exec(query+" ORDER BY id LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	if c, err := res.RowsAffected(); err != nil || c == 0 {
+		return nil, err
+	}
+	var messages []*domain.DBQueueMessage
+	err = r.db.Select(&messages, "SELECT id, name, message_type, message, ts, retries, priority FROM queue WHERE claimed_by = ? ORDER BY id", claim)
+	return messages, err
+}
+
+// AckMessage deletes the claimed message
+func (r *MySQL) AckMessage(id int64) error {
+	_, err := r.db.Exec("DELETE FROM queue WHERE id = ?", id)
+	return err
+}
+
+// NackMessage releases the claim on the message so it will be retried
+func (r *MySQL) NackMessage(id int64) error {
+	_, err := r.db.Exec("UPDATE queue SET retries = retries + 1, claimed_by = NULL, claimed_at = NULL WHERE id = ?", id)
+	return err
+}
+
+// DeadLetter moves the message to the dead letters with the reason it could not be handled
+func (r *MySQL) DeadLetter(message *domain.DBQueueMessage, reason string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO dead_letters (name, message_type, message, retries, reason, ts) VALUES (?, ?, ?, ?, ?, now())",
+		message.Name, message.MessageType, message.Message, message.Retries, reason)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// Messages that were already read are not in the queue anymore
+	if message.ID != 0 {
+		if _, err = tx.Exec("DELETE FROM queue WHERE id = ?", message.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeadLetters returns the dead letters, newest first
+func (r *MySQL) DeadLetters(limit int) ([]domain.DeadLetter, error) {
+	var letters []domain.DeadLetter
+	err := r.db.Select(&letters, "SELECT * FROM dead_letters ORDER BY id DESC LIMIT ?", limit)
+	return letters, err
+}
+
+// DeadLetterByID returns the dead letter or ErrNotFound
+func (r *MySQL) DeadLetterByID(id int64) (*domain.DeadLetter, error) {
+	letter := &domain.DeadLetter{}
+	err := r.db.Get(letter, "SELECT * FROM dead_letters WHERE id = ?", id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return letter, err
+}
+
+// DelDeadLetter removes the dead letter
+func (r *MySQL) DelDeadLetter(id int64) error {
+	_, err := r.db.Exec("DELETE FROM dead_letters WHERE id = ?", id)
+	return err
+}
+
 func (r *MySQL) PostMessage(message *domain.DBQueueMessage) error {
-	_, err := r.db.Exec("INSERT INTO queue (name, message_type, message, ts) VALUES (?, ?, ?, now())",
-		message.Name, message.MessageType, message.Message)
+	_, err := r.db.Exec("INSERT INTO queue (name, message_type, message, ts, priority) VALUES (?, ?, ?, now(), ?)",
+		message.Name, message.MessageType, message.Message, message.Priority)
 	return err
 }
 
@@ -780,3 +1507,25 @@ func (r *MySQL) PostMessageToAll(message *domain.DBQueueMessage) error {
 		message.MessageType, message.Message)
 	return err
 }
+
+// CachedResult returns the cached provider result with the given ID if it did not expire
+func (r *MySQL) CachedResult(id string) (*domain.CachedResult, error) {
+	res := &domain.CachedResult{}
+	err := r.get("reputation_cache", "id", id, res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Expires.Before(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return res, nil
+}
+
+func (r *MySQL) SetCachedResult(res *domain.CachedResult) error {
+	_, err := r.db.Exec(`INSERT INTO reputation_cache (id, provider, indicator, online, result, expires)
+VALUES (?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE online = ?, result = ?, expires = ?`,
+		res.ID, res.Provider, res.Indicator, boolToInt(res.Online), res.Result, res.Expires,
+		boolToInt(res.Online), res.Result, res.Expires)
+	return err
+}
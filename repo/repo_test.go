@@ -3,6 +3,7 @@
 package repo
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -19,13 +20,25 @@ func getTestDB(t *testing.T) *MySQL {
 		t.Fatalf("%v", err)
 	}
 	db.db.Exec("DELETE FROM queue")
+	db.db.Exec("DELETE FROM dead_letters")
+	db.db.Exec("DELETE FROM slack_events")
+	db.db.Exec("DELETE FROM reputation_cache")
 	db.db.Exec("DELETE FROM convicted")
 	db.db.Exec("DELETE FROM slack_invites")
 	db.db.Exec("DELETE FROM team_statistics")
+	db.db.Exec("DELETE FROM statistics_buckets")
 	db.db.Exec("DELETE FROM bot_for_team")
 	db.db.Exec("DELETE FROM bots")
 	db.db.Exec("DELETE FROM configuration")
+	db.db.Exec("DELETE FROM verdict_policies")
 	db.db.Exec("DELETE FROM oauth_state")
+	db.db.Exec("DELETE FROM allowlist")
+	db.db.Exec("DELETE FROM blocklist")
+	db.db.Exec("DELETE FROM feedback")
+	db.db.Exec("DELETE FROM intel_indicators")
+	db.db.Exec("DELETE FROM taxii_state")
+	db.db.Exec("DELETE FROM webhook_deliveries")
+	db.db.Exec("DELETE FROM webhooks")
 	db.db.Exec("DELETE FROM users")
 	db.db.Exec("DELETE FROM teams")
 	return db
@@ -129,6 +142,27 @@ func TestOAuthStateMySQL(t *testing.T) {
 	r.Close()
 }
 
+func TestCachedResultMySQL(t *testing.T) {
+	r := getTestDB(t)
+	err := r.SetCachedResult(&domain.CachedResult{ID: "x", Provider: "vt", Indicator: "kuku", Result: "{}", Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Errorf("Unable to save cached result - %v", err)
+	}
+	res, err := r.CachedResult("x")
+	if err != nil || res.Provider != "vt" || res.Online {
+		t.Errorf("Unable to load cached result - %v", err)
+	}
+	err = r.SetCachedResult(&domain.CachedResult{ID: "x", Provider: "vt", Indicator: "kuku", Online: true, Result: "{}", Expires: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Errorf("Unable to update cached result - %v", err)
+	}
+	_, err = r.CachedResult("x")
+	if err != ErrNotFound {
+		t.Errorf("Expected expired result to be not found but got %v", err)
+	}
+	r.Close()
+}
+
 func TestQueueMessages(t *testing.T) {
 	r := getTestDB(t)
 	messages, err := r.QueueMessages(false, "work")
@@ -165,3 +199,398 @@ func TestQueueMessages(t *testing.T) {
 		t.Errorf("Got messages but expecting none after delete")
 	}
 }
+
+func TestClaimMessages(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.PostMessage(&domain.DBQueueMessage{Name: "web1", Message: "ABC", MessageType: "work"}); err != nil {
+		t.Fatalf("Unable to post message - %v", err)
+	}
+	messages, err := r.ClaimMessages(nil, "work", domain.PriorityNormal, time.Minute, 10)
+	if err != nil || len(messages) != 1 || messages[0].Retries != 0 {
+		t.Fatalf("Expecting 1 message but got %v, %v", messages, err)
+	}
+	// Still leased
+	if again, err := r.ClaimMessages(nil, "work", domain.PriorityNormal, time.Minute, 10); err != nil || len(again) != 0 {
+		t.Fatalf("Expecting no messages while leased but got %v, %v", again, err)
+	}
+	if err = r.NackMessage(messages[0].ID); err != nil {
+		t.Fatalf("Unable to nack message - %v", err)
+	}
+	messages, err = r.ClaimMessages(nil, "work", domain.PriorityNormal, time.Minute, 10)
+	if err != nil || len(messages) != 1 || messages[0].Retries != 1 {
+		t.Fatalf("Expecting 1 retried message but got %v, %v", messages, err)
+	}
+	if err = r.DeadLetter(messages[0], "kuku"); err != nil {
+		t.Fatalf("Unable to dead letter message - %v", err)
+	}
+	letters, err := r.DeadLetters(10)
+	if err != nil || len(letters) != 1 || letters[0].Reason != "kuku" || letters[0].Retries != 1 || letters[0].Name != "web1" {
+		t.Fatalf("Expecting 1 dead letter but got %v, %v", letters, err)
+	}
+	if messages, err = r.ClaimMessages(nil, "work", domain.PriorityNormal, 0, 10); err != nil || len(messages) != 0 {
+		t.Errorf("Expecting dead letter to leave the queue but got %v, %v", messages, err)
+	}
+}
+
+func TestMarkEventSeen(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	for i, expected := range []bool{true, false} {
+		first, err := r.MarkEventSeen("Ev123")
+		if err != nil || first != expected {
+			t.Errorf("Attempt %d expected %v but got %v, %v", i, expected, first, err)
+		}
+	}
+}
+
+func TestBotReply(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if _, err := r.BotReply("T1", "C1", "1.1"); err != ErrNotFound {
+		t.Fatalf("Expected not found but got %v", err)
+	}
+	for _, ts := range []string{"2.1", "2.2"} {
+		if err := r.SetBotReply(&domain.BotReply{Team: "T1", Channel: "C1", MessageID: "1.1", ReplyTS: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reply, err := r.BotReply("T1", "C1", "1.1")
+	if err != nil || reply.ReplyTS != "2.2" {
+		t.Fatalf("Expected the latest reply but got %+v, %v", reply, err)
+	}
+	if err = r.DelBotReply("T1", "C1", "1.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.BotReply("T1", "C1", "1.1"); err != ErrNotFound {
+		t.Errorf("Expected not found after delete but got %v", err)
+	}
+}
+
+func TestSaveConfigurationKeepsPolicy(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "policy", Name: "policy", ExternalID: "Tpolicy"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	policy := &conf.VerdictPolicies{URL: conf.VerdictPolicy{Thresholds: map[string]float64{"vt": 2}}}
+	err := r.SetChannelsAndGroups(&domain.Configuration{Team: "policy", Channels: []string{"C1"}, Providers: []string{"vt"}, Policy: policy})
+	if err != nil {
+		t.Fatalf("Unable to save configuration - %v", err)
+	}
+	// The conf page saves the channels without the providers and the policy
+	if err = r.SetChannelsAndGroups(&domain.Configuration{Team: "policy", Channels: []string{"C2"}}); err != nil {
+		t.Fatalf("Unable to save configuration - %v", err)
+	}
+	configuration, err := r.ChannelsAndGroups("policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configuration.Channels) != 1 || configuration.Channels[0] != "C2" {
+		t.Errorf("Unexpected channels %v", configuration.Channels)
+	}
+	if len(configuration.Providers) != 1 || configuration.Providers[0] != "vt" {
+		t.Errorf("Expected the providers to survive but got %v", configuration.Providers)
+	}
+	if configuration.Policy == nil || configuration.Policy.URL.Thresholds["vt"] != 2 {
+		t.Errorf("Expected the policy to survive but got %+v", configuration.Policy)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "allow", Name: "allow", ExternalID: "Tallow"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.AddToAllowlist("allow", "good.com", "U1"); err != nil {
+			t.Fatalf("Unable to add to allowlist - %v", err)
+		}
+	}
+	configuration, err := r.ChannelsAndGroups("allow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configuration.Allowlist) != 1 || configuration.Allowlist[0] != "good.com" {
+		t.Errorf("Unexpected allowlist %v", configuration.Allowlist)
+	}
+	if err = r.RemoveFromAllowlist("allow", "good.com"); err != nil {
+		t.Fatalf("Unable to remove from allowlist - %v", err)
+	}
+	allowlist, err := r.Allowlist("allow")
+	if err != nil || len(allowlist) != 0 {
+		t.Errorf("Expected empty allowlist but got %v - %v", allowlist, err)
+	}
+	err = r.StoreFeedback(&domain.Feedback{Team: "allow", User: "U1", Channel: "C1", MessageID: "1.1", Indicator: "good.com", IndicatorType: "domain", Kind: "false_positive"})
+	if err != nil {
+		t.Errorf("Unable to store feedback - %v", err)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "block", Name: "block", ExternalID: "Tblock"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	entries := []domain.BlocklistEntry{{Indicator: "evil.com", Description: "IR-1"}, {Indicator: "10.0.0.0/8"}}
+	if err := r.AddToBlocklist("block", "U1", entries); err != nil {
+		t.Fatalf("Unable to add to blocklist - %v", err)
+	}
+	if err := r.AddToBlocklist("block", "U1", []domain.BlocklistEntry{{Indicator: "evil.com", Description: "IR-2"}}); err != nil {
+		t.Fatalf("Unable to update blocklist - %v", err)
+	}
+	configuration, err := r.ChannelsAndGroups("block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configuration.Blocklist) != 2 || configuration.Blocklist[1].Indicator != "evil.com" || configuration.Blocklist[1].Description != "IR-2" {
+		t.Errorf("Unexpected blocklist %v", configuration.Blocklist)
+	}
+	if err = r.RemoveFromBlocklist("block", "evil.com"); err != nil {
+		t.Fatalf("Unable to remove from blocklist - %v", err)
+	}
+	blocklist, err := r.Blocklist("block")
+	if err != nil || len(blocklist) != 1 {
+		t.Errorf("Expected a single entry but got %v - %v", blocklist, err)
+	}
+}
+
+func TestIntel(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "intel", Name: "intel", ExternalID: "Tintel"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	indicators := []domain.IntelIndicator{
+		{STIXID: "indicator--1", Type: domain.ReplyTypeDomain, Value: "evil.com", Name: "C2", ValidFrom: past},
+		{STIXID: "indicator--2", Type: domain.ReplyTypeDomain, Value: "expired.com", ValidFrom: past, ValidUntil: &past},
+		{STIXID: "indicator--3", Type: domain.ReplyTypeDomain, Value: "future.com", ValidFrom: future},
+		{STIXID: "indicator--4", Type: domain.ReplyTypeIP, Value: "10.0.0.0/8", ValidFrom: past, ValidUntil: &future},
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.StoreIntel("intel", indicators); err != nil {
+			t.Fatalf("Unable to store intel - %v", err)
+		}
+	}
+	if i, err := r.IntelIndicator("intel", domain.ReplyTypeDomain, "evil.com"); err != nil || i.STIXID != "indicator--1" || i.Name != "C2" {
+		t.Errorf("Expected the C2 indicator but got %+v - %v", i, err)
+	}
+	for _, value := range []string{"expired.com", "future.com"} {
+		if _, err := r.IntelIndicator("intel", domain.ReplyTypeDomain, value); err != ErrNotFound {
+			t.Errorf("Expected %s to be out of its validity window but got %v", value, err)
+		}
+	}
+	if networks, err := r.IntelNetworks("intel"); err != nil || len(networks) != 1 || networks[0].Value != "10.0.0.0/8" {
+		t.Errorf("Unexpected networks %+v - %v", networks, err)
+	}
+}
+
+func TestTAXIIState(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "taxii", Name: "taxii", ExternalID: "Ttaxii"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	const url = "https://tip.test/api/collections/c1/"
+	if addedAfter, err := r.TAXIIAddedAfter("taxii", url); err != nil || addedAfter != "" {
+		t.Errorf("Expected no state but got %s - %v", addedAfter, err)
+	}
+	for _, addedAfter := range []string{"2020-01-01T00:00:00.000Z", "2020-01-02T00:00:00.000Z"} {
+		if err := r.SetTAXIIAddedAfter("taxii", url, addedAfter); err != nil {
+			t.Fatalf("Unable to set state - %v", err)
+		}
+	}
+	if addedAfter, err := r.TAXIIAddedAfter("taxii", url); err != nil || addedAfter != "2020-01-02T00:00:00.000Z" {
+		t.Errorf("Unexpected state %s - %v", addedAfter, err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	unlock, ok, err := r.Lock("test")
+	if err != nil || !ok {
+		t.Fatalf("Unable to take the lock - %v", err)
+	}
+	if _, ok, err = r.Lock("test"); err != nil || ok {
+		t.Errorf("Expected the lock to be held - %v", err)
+	}
+	unlock()
+	unlock, ok, err = r.Lock("test")
+	if err != nil || !ok {
+		t.Fatalf("Expected the released lock to be available - %v", err)
+	}
+	unlock()
+	// The deferred close runs as well - closing twice must not panic
+	if err = r.Close(); err != nil {
+		t.Errorf("Unable to close - %v", err)
+	}
+}
+
+func TestConvicted(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "misp", Name: "misp", ExternalID: "Tmisp"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	convicted := &domain.MaliciousContent{Team: "misp", Channel: "C1", MessageID: "1", ContentType: domain.ReplyTypeDomain, Content: "evil.com", VT: "5 / 60"}
+	if err := r.StoreMaliciousContent(convicted); err != nil {
+		t.Fatalf("Unable to store convicted - %v", err)
+	}
+	res, err := r.Convicted("misp", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil || len(res) != 1 || res[0].Content != "evil.com" || res[0].VT != "5 / 60" || res[0].Timestamp.IsZero() {
+		t.Errorf("Unexpected convicted %+v - %v", res, err)
+	}
+	if res, err = r.Convicted("misp", time.Now().Add(time.Hour), time.Now().Add(2*time.Hour)); err != nil || len(res) != 0 {
+		t.Errorf("Expected nothing in the range but got %+v - %v", res, err)
+	}
+}
+
+func TestConvictedSameMessage(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "same", Name: "same", ExternalID: "Tsame"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	for _, convicted := range []*domain.MaliciousContent{
+		{Team: "same", Channel: "C1", MessageID: "1", ContentType: domain.ReplyTypeHash, Content: "44d88612fea8a8f36de82e1278abb02f", VT: "55 / 60"},
+		{Team: "same", Channel: "C1", MessageID: "1", ContentType: domain.ReplyTypeDomain, Content: "evil.com", Intel: "On the team blocklist as evil.com"},
+	} {
+		if err := r.StoreMaliciousContent(convicted); err != nil {
+			t.Fatalf("Unable to store convicted %s - %v", convicted.Content, err)
+		}
+	}
+	res, err := r.Convicted("same", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil || len(res) != 2 {
+		t.Errorf("Expected both indicators of the message but got %+v - %v", res, err)
+	}
+}
+
+func TestQueryConvicted(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "history", Name: "history", ExternalID: "Thistory"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	for i, c := range []domain.MaliciousContent{
+		{Channel: "C1", ContentType: domain.ReplyTypeURL, Content: "http://evil.com/a", VT: "5 / 60"},
+		{Channel: "C1", ContentType: domain.ReplyTypeDomain, Content: "evil.com", XFE: "7"},
+		{Channel: "C2", ContentType: domain.ReplyTypeURL, Content: "http://evil.com/b", VT: "6 / 60"},
+	} {
+		c.Team, c.MessageID = "history", strconv.Itoa(i)
+		if err := r.StoreMaliciousContent(&c); err != nil {
+			t.Fatalf("Unable to store convicted - %v", err)
+		}
+	}
+	res, total, err := r.QueryConvicted("history", &domain.ConvictedQuery{ContentType: domain.ReplyTypeURL, Provider: "vt", Limit: 1})
+	if err != nil || total != 2 || len(res) != 1 {
+		t.Errorf("Unexpected page %+v of %d - %v", res, total, err)
+	}
+	res, total, err = r.QueryConvicted("history", &domain.ConvictedQuery{Channel: "C1", Provider: "xfe"})
+	if err != nil || total != 1 || len(res) != 1 || res[0].Content != "evil.com" {
+		t.Errorf("Unexpected convicted %+v of %d - %v", res, total, err)
+	}
+	if _, _, err = r.QueryConvicted("history", &domain.ConvictedQuery{Provider: "content"}); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
+
+func TestStoreConvicted(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "events", Name: "events", ExternalID: "Tevents"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	if err := r.AddWebhook(&domain.Webhook{Team: "events", URL: "https://soar.test/hooks", Secret: "secret", User: "U1"}); err != nil {
+		t.Fatalf("Unable to add webhook - %v", err)
+	}
+	// A rescan convicts the same indicator of the message again
+	for _, vt := range []string{"5 / 60", "7 / 60"} {
+		convicted := &domain.MaliciousContent{Team: "events", Channel: "C1", MessageID: "1", ContentType: domain.ReplyTypeDomain, Content: "evil.com", VT: vt}
+		if err := r.StoreConvicted(convicted, []byte(`{"event": "convicted"}`)); err != nil {
+			t.Fatalf("Unable to store convicted - %v", err)
+		}
+	}
+	res, err := r.Convicted("events", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil || len(res) != 1 || res[0].VT != "7 / 60" {
+		t.Errorf("Expected the updated conviction but got %+v - %v", res, err)
+	}
+	if log, err := r.WebhookDeliveries("events", 10); err != nil || len(log) != 2 {
+		t.Errorf("Expected an event for every conviction but got %+v - %v", log, err)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "hooks", Name: "hooks", ExternalID: "Thooks"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	hook := &domain.Webhook{Team: "hooks", URL: "https://soar.test/hooks", Secret: "secret", User: "U1"}
+	if err := r.AddWebhook(hook); err != nil || hook.ID == 0 {
+		t.Fatalf("Unable to add webhook - %v", err)
+	}
+	if err := r.QueueWebhookEvent("hooks", domain.EventConvicted, []byte(`{"event": "convicted"}`)); err != nil {
+		t.Fatalf("Unable to queue event - %v", err)
+	}
+	deliveries, err := r.ClaimWebhookDeliveries(time.Minute, 10)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Secret != "secret" || deliveries[0].URL != hook.URL {
+		t.Fatalf("Unexpected deliveries %+v - %v", deliveries, err)
+	}
+	// Claimed deliveries are not claimed again until the lease expires
+	if again, err := r.ClaimWebhookDeliveries(time.Minute, 10); err != nil || len(again) != 0 {
+		t.Errorf("Expected no deliveries but got %+v - %v", again, err)
+	}
+	next := time.Now().Add(time.Minute)
+	if err = r.WebhookAttemptFailed(&deliveries[0], "connection refused", &next); err != nil {
+		t.Fatalf("Unable to fail delivery - %v", err)
+	}
+	log, err := r.WebhookDeliveries("hooks", 10)
+	if err != nil || len(log) != 1 || log[0].Status != domain.DeliveryPending || log[0].Attempts != 1 || log[0].LastError != "connection refused" {
+		t.Errorf("Unexpected delivery log %+v - %v", log, err)
+	}
+	if err = r.WebhookDelivered(deliveries[0].ID); err != nil {
+		t.Fatalf("Unable to mark delivery - %v", err)
+	}
+	if log, err = r.WebhookDeliveries("hooks", 10); err != nil || len(log) != 1 || log[0].Status != domain.DeliveryDelivered || log[0].Delivered == nil {
+		t.Errorf("Unexpected delivery log %+v - %v", log, err)
+	}
+	if err = r.RemoveWebhook("hooks", hook.ID); err != nil {
+		t.Errorf("Unable to remove webhook - %v", err)
+	}
+	if err = r.RemoveWebhook("hooks", hook.ID); err != ErrNotFound {
+		t.Errorf("Expected not found but got %v", err)
+	}
+}
+
+func TestStatisticsBuckets(t *testing.T) {
+	r := getTestDB(t)
+	defer r.Close()
+	if err := r.SetTeam(&domain.Team{ID: "stats", Name: "stats", ExternalID: "Tstats"}); err != nil {
+		t.Fatalf("Unable to create team - %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.UpdateStatistics(&domain.Statistics{Team: "stats", Messages: 2, URLsDirty: 1, DomainsDirty: 1}); err != nil {
+			t.Fatalf("Unable to update statistics - %v", err)
+		}
+	}
+	// An old hour that is rolled up into its day
+	old := time.Date(2020, 1, 8, 15, 0, 0, 0, time.UTC)
+	if err := upsertStatsBucket(r.db, &domain.Statistics{Team: "stats", Messages: 5}, domain.StatsHour, old); err != nil {
+		t.Fatalf("Unable to add old bucket - %v", err)
+	}
+	if err := r.rollupStatistics(time.Date(2020, 1, 9, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Unable to roll up - %v", err)
+	}
+	buckets, err := r.StatisticsBuckets("stats", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().Add(time.Hour), domain.StatsDay)
+	if err != nil || len(buckets) != 2 || buckets[0].Messages != 5 || !buckets[0].Timestamp.Equal(time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)) ||
+		buckets[1].Messages != 4 || buckets[1].URLsDirty != 2 || buckets[1].DomainsDirty != 2 {
+		t.Errorf("Unexpected buckets %+v - %v", buckets, err)
+	}
+	if total, err := r.TotalMessages(); err != nil || total != 9 {
+		t.Errorf("Expected 9 messages but got %d - %v", total, err)
+	}
+}
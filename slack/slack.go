@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/demisto/alfred/util"
@@ -86,3 +88,28 @@ func (s *Client) Do(method, path string, body interface{}) (util.Object, error)
 	}
 	return res, nil
 }
+
+// responseURLPrefix of the URLs Slack gives us to reply to commands and actions
+const responseURLPrefix = "https://hooks.slack.com/"
+
+var responseClient = &http.Client{Timeout: 10 * time.Second}
+
+// Respond posts the message to the response URL of a slash command or an action
+func Respond(responseURL string, message interface{}) error {
+	if !strings.HasPrefix(responseURL, responseURLPrefix) {
+		return errors.New("invalid response URL: " + responseURL)
+	}
+	b, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	resp, err := responseClient.Post(responseURL, "application/json; charset=utf-8", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("unexpected status code: [" + resp.Status + "]")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Package stix reads the indicators of STIX 2.1 bundles so threat intel from a TIP can be stored locally.
+// Only the equality comparisons on file hashes, domains, URLs and IP addresses in STIX patterns are used -
+// other comparisons and qualifiers in the pattern are ignored.
+package stix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+// ErrNotBundle is returned if the JSON is not a STIX bundle
+var ErrNotBundle = errors.New("not a STIX bundle")
+
+type bundle struct {
+	Type    string            `json:"type"`
+	Objects []json.RawMessage `json:"objects"`
+}
+
+type indicator struct {
+	Type        string     `json:"type"`
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Pattern     string     `json:"pattern"`
+	PatternType string     `json:"pattern_type"`
+	ValidFrom   time.Time  `json:"valid_from"`
+	ValidUntil  *time.Time `json:"valid_until"`
+	Revoked     bool       `json:"revoked"`
+}
+
+var (
+	comparisonReg = regexp.MustCompile(`([a-z0-9-]+):([A-Za-z0-9_.'-]+)\s*=\s*'((?:[^'\\]|\\.)*)'`)
+	hashReg       = regexp.MustCompile(`^(?:[a-fA-F\d]{32}|[a-fA-F\d]{40}|[a-fA-F\d]{64})$`)
+	unescaper     = strings.NewReplacer(`\'`, `'`, `\\`, `\`)
+)
+
+// value of the comparison with its type. ok is false for comparisons we do not handle.
+func value(object, path, v string) (int, string, bool) {
+	v = unescaper.Replace(v)
+	switch {
+	case object == "file" && strings.HasPrefix(path, "hashes."):
+		if hashReg.MatchString(v) {
+			return domain.ReplyTypeHash, strings.ToLower(v), true
+		}
+	case object == "domain-name" && path == "value":
+		return domain.ReplyTypeDomain, strings.ToLower(strings.TrimSuffix(v, ".")), v != ""
+	case object == "url" && path == "value":
+		return domain.ReplyTypeURL, v, v != ""
+	case (object == "ipv4-addr" || object == "ipv6-addr") && path == "value":
+		if _, ipnet, err := net.ParseCIDR(v); err == nil {
+			return domain.ReplyTypeIP, ipnet.String(), true
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			return domain.ReplyTypeIP, ip.String(), true
+		}
+	}
+	return 0, "", false
+}
+
+// Parse the bundle and return an intel indicator for every value in the patterns of the indicator objects.
+// Revoked indicators and patterns that are not STIX patterns are skipped.
+func Parse(r io.Reader) ([]domain.IntelIndicator, error) {
+	b := &bundle{}
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, err
+	}
+	if b.Type != "bundle" {
+		return nil, ErrNotBundle
+	}
+	return ParseObjects(b.Objects)
+}
+
+// ParseObjects returns the intel indicators of the STIX objects like Parse does for a bundle
+func ParseObjects(objects []json.RawMessage) ([]domain.IntelIndicator, error) {
+	var res []domain.IntelIndicator
+	for _, raw := range objects {
+		var header struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil {
+			return nil, err
+		}
+		if header.Type != "indicator" {
+			continue
+		}
+		i := &indicator{}
+		if err := json.Unmarshal(raw, i); err != nil {
+			return nil, fmt.Errorf("invalid indicator - %v", err)
+		}
+		if i.Revoked || i.PatternType != "" && i.PatternType != "stix" {
+			continue
+		}
+		if i.ID == "" || i.ValidFrom.IsZero() {
+			return nil, fmt.Errorf("indicator %q is missing its id or valid_from", i.ID)
+		}
+		name := i.Name
+		if name == "" {
+			name = i.Description
+		}
+		seen := make(map[string]bool)
+		for _, c := range comparisonReg.FindAllStringSubmatch(i.Pattern, -1) {
+			t, v, ok := value(c[1], c[2], c[3])
+			if !ok || seen[v] {
+				continue
+			}
+			seen[v] = true
+			res = append(res, domain.IntelIndicator{
+				STIXID:     i.ID,
+				Type:       t,
+				Value:      v,
+				Name:       name,
+				ValidFrom:  i.ValidFrom,
+				ValidUntil: i.ValidUntil,
+			})
+		}
+	}
+	return res, nil
+}
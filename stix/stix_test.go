@@ -0,0 +1,81 @@
+package stix
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+const testBundle = `{
+	"type": "bundle",
+	"id": "bundle--1",
+	"objects": [
+		{"type": "identity", "id": "identity--1", "name": "TIP"},
+		{
+			"type": "indicator", "id": "indicator--1", "name": "IR-1 dropper",
+			"pattern": "[file:hashes.'SHA-256' = 'AEC070645FE53EE3B3763059376134F058CC337247C978ADD178B6CCDFB0019F'] OR [file:hashes.MD5 = '44d88612fea8a8f36de82e1278abb02f']",
+			"pattern_type": "stix", "valid_from": "2020-01-01T00:00:00Z"
+		},
+		{
+			"type": "indicator", "id": "indicator--2", "description": "C2",
+			"pattern": "[domain-name:value = 'Evil.example.com'] AND [url:value = 'http://evil.example.com/it\\'s']",
+			"pattern_type": "stix", "valid_from": "2020-01-01T00:00:00Z", "valid_until": "2021-01-01T00:00:00Z"
+		},
+		{
+			"type": "indicator", "id": "indicator--3",
+			"pattern": "[ipv4-addr:value = '198.51.100.7/24' OR ipv6-addr:value = '2001:DB8::1' OR ipv4-addr:value = 'kuku']",
+			"valid_from": "2020-01-01T00:00:00Z"
+		},
+		{"type": "indicator", "id": "indicator--4", "pattern": "[domain-name:value = 'revoked.com']", "valid_from": "2020-01-01T00:00:00Z", "revoked": true},
+		{"type": "indicator", "id": "indicator--5", "pattern": "alert tcp any any", "pattern_type": "snort", "valid_from": "2020-01-01T00:00:00Z"}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	indicators, err := Parse(strings.NewReader(testBundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		id    string
+		t     int
+		value string
+		name  string
+	}{
+		{"indicator--1", domain.ReplyTypeHash, "aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f", "IR-1 dropper"},
+		{"indicator--1", domain.ReplyTypeHash, "44d88612fea8a8f36de82e1278abb02f", "IR-1 dropper"},
+		{"indicator--2", domain.ReplyTypeDomain, "evil.example.com", "C2"},
+		{"indicator--2", domain.ReplyTypeURL, "http://evil.example.com/it's", "C2"},
+		{"indicator--3", domain.ReplyTypeIP, "198.51.100.0/24", ""},
+		{"indicator--3", domain.ReplyTypeIP, "2001:db8::1", ""},
+	}
+	if len(indicators) != len(expected) {
+		t.Fatalf("Expected %d indicators but got %+v", len(expected), indicators)
+	}
+	for i, e := range expected {
+		if indicators[i].STIXID != e.id || indicators[i].Type != e.t || indicators[i].Value != e.value || indicators[i].Name != e.name {
+			t.Errorf("Expected %+v but got %+v", e, indicators[i])
+		}
+	}
+	if indicators[2].ValidUntil == nil || indicators[2].Active(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the indicator to expire but got %+v", indicators[2])
+	}
+	if !indicators[0].Active(time.Now()) || indicators[0].Active(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong validity window for %+v", indicators[0])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bundles := []string{
+		`{"type": "indicator"}`,
+		`{"type": "bundle", "objects": [{"type": "indicator", "pattern": "[domain-name:value = 'evil.com']"}]}`,
+		`kuku`,
+	}
+	for _, b := range bundles {
+		if _, err := Parse(strings.NewReader(b)); err == nil {
+			t.Errorf("Expected an error for %s", b)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package taxii polls TAXII 2.1 collections for the indicators added since the last poll
+package taxii
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/stix"
+)
+
+// MediaType of TAXII 2.1 requests and responses
+const MediaType = "application/taxii+json;version=2.1"
+
+// maxPages we fetch in a single poll so a misbehaving server cannot keep us busy forever
+const maxPages = 1000
+
+// ErrTooManyPages is returned if the collection keeps saying there is more after maxPages
+var ErrTooManyPages = errors.New("taxii: too many pages")
+
+// Client of a single TAXII 2.1 collection
+type Client struct {
+	URL      string       // URL of the collection - https://host/api-root/collections/id/. Required.
+	Username string       // Username for basic authentication if needed
+	Password string       // Password for basic authentication if needed
+	HTTP     *http.Client // The HTTP client to use - a client with a timeout if nil
+}
+
+type envelope struct {
+	More    bool              `json:"more"`
+	Next    string            `json:"next"`
+	Objects []json.RawMessage `json:"objects"`
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return &http.Client{Timeout: time.Minute}
+}
+
+// page of objects added after the given time or continuing from next. Returns the time the last object was added.
+func (c *Client) page(addedAfter, next string) (*envelope, string, error) {
+	params := url.Values{}
+	if addedAfter != "" {
+		params.Set("added_after", addedAfter)
+	}
+	if next != "" {
+		params.Set("next", next)
+	}
+	u := strings.TrimSuffix(c.URL, "/") + "/objects/"
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", MediaType)
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("taxii: unexpected status %s from %s", resp.Status, c.URL)
+	}
+	e := &envelope{}
+	if err = json.NewDecoder(resp.Body).Decode(e); err != nil {
+		return nil, "", err
+	}
+	return e, resp.Header.Get("X-TAXII-Date-Added-Last"), nil
+}
+
+// Poll the indicators added to the collection after the given time - all of them if empty.
+// It returns the time the last object was added to use in the next poll.
+func (c *Client) Poll(addedAfter string) ([]domain.IntelIndicator, string, error) {
+	var res []domain.IntelIndicator
+	last, next := addedAfter, ""
+	for i := 0; i < maxPages; i++ {
+		e, added, err := c.page(addedAfter, next)
+		if err != nil {
+			return nil, "", err
+		}
+		indicators, err := stix.ParseObjects(e.Objects)
+		if err != nil {
+			return nil, "", err
+		}
+		res = append(res, indicators...)
+		if added != "" {
+			last = added
+		}
+		if !e.More {
+			return res, last, nil
+		}
+		// Servers without next support are paged by the time of the last object we got
+		if next = e.Next; next == "" {
+			if added == "" || added == addedAfter {
+				return nil, "", fmt.Errorf("taxii: %s has more objects but no way to get them", c.URL)
+			}
+			addedAfter = added
+		}
+	}
+	return nil, "", ErrTooManyPages
+}
@@ -0,0 +1,91 @@
+package taxii
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// collection is a local TAXII stand-in serving objects two at a time
+type collection struct {
+	dates    []string
+	patterns []string
+	useNext  bool
+}
+
+func (c *collection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Accept") != MediaType || r.URL.Path != "/api/collections/c1/objects/" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	start := 0
+	if next := r.URL.Query().Get("next"); next != "" {
+		start, _ = strconv.Atoi(next)
+	} else if after := r.URL.Query().Get("added_after"); after != "" {
+		for start < len(c.dates) && c.dates[start] <= after {
+			start++
+		}
+	}
+	end := start + 2
+	if end > len(c.dates) {
+		end = len(c.dates)
+	}
+	e := &envelope{More: end < len(c.dates)}
+	if e.More && c.useNext {
+		e.Next = strconv.Itoa(end)
+	}
+	for i := start; i < end; i++ {
+		e.Objects = append(e.Objects, json.RawMessage(fmt.Sprintf(
+			`{"type": "indicator", "id": "indicator--%d", "pattern": %q, "pattern_type": "stix", "valid_from": "2020-01-01T00:00:00Z"}`, i, c.patterns[i])))
+	}
+	if end > start {
+		w.Header().Set("X-TAXII-Date-Added-Last", c.dates[end-1])
+	}
+	w.Header().Set("Content-Type", MediaType)
+	json.NewEncoder(w).Encode(e)
+}
+
+func testCollection(useNext bool) *collection {
+	return &collection{
+		dates: []string{"2020-01-01T00:00:00.000Z", "2020-01-02T00:00:00.000Z", "2020-01-03T00:00:00.000Z"},
+		patterns: []string{
+			"[domain-name:value = 'evil.com']",
+			"[ipv4-addr:value = '198.51.100.7']",
+			"[url:value = 'http://evil.com/a']",
+		},
+		useNext: useNext,
+	}
+}
+
+func TestPoll(t *testing.T) {
+	for _, useNext := range []bool{true, false} {
+		c := testCollection(useNext)
+		server := httptest.NewServer(c)
+		client := &Client{URL: server.URL + "/api/collections/c1/"}
+		indicators, last, err := client.Poll("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(indicators) != 3 || indicators[2].Value != "http://evil.com/a" || last != c.dates[2] {
+			t.Errorf("Unexpected poll result %+v until %s", indicators, last)
+		}
+		// Nothing new since the last poll
+		indicators, again, err := client.Poll(last)
+		if err != nil || len(indicators) != 0 || again != last {
+			t.Errorf("Expected nothing new but got %+v until %s - %v", indicators, again, err)
+		}
+		server.Close()
+	}
+}
+
+func TestPollError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	client := &Client{URL: server.URL + "/api/collections/c1/"}
+	if _, _, err := client.Poll(""); err == nil {
+		t.Error("Expected an error for a missing collection")
+	}
+}
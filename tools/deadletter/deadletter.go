@@ -0,0 +1,123 @@
+// deadletter lists, replays and deletes the queue messages that could not be handled
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/queue"
+	"github.com/demisto/alfred/repo"
+)
+
+var (
+	confFile = flag.String("conf", "conf.json", "Path to configuration file in JSON format")
+	limit    = flag.Int("limit", 100, "Maximum number of dead letters to list")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] list | show <id> | replay <id> | delete <id>\n", os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+// replay pushes the message back to the queue it came from
+func replay(q queue.Queue, letter *domain.DeadLetter) error {
+	switch letter.MessageType {
+	case "work":
+		work := &domain.WorkRequest{}
+		if err := json.Unmarshal([]byte(letter.Message), work); err != nil {
+			return err
+		}
+		return q.PushWork(work)
+	case "workr":
+		reply := &domain.WorkReply{}
+		if err := json.Unmarshal([]byte(letter.Message), reply); err != nil {
+			return err
+		}
+		return q.PushWorkReply(letter.Name, reply)
+	case "conf":
+		return q.PushConf(letter.Message)
+	}
+	return fmt.Errorf("unknown message type %s", letter.MessageType)
+}
+
+// run the command and return the first error
+func run(command string, arg string) error {
+	err := conf.Load(*confFile, false)
+	if err != nil {
+		return err
+	}
+	// We do not want to consume any messages ourselves
+	conf.Options.Web, conf.Options.Worker = false, false
+	r, err := repo.NewMySQL()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if command == "list" {
+		letters, err := r.DeadLetters(*limit)
+		if err != nil {
+			return err
+		}
+		for _, letter := range letters {
+			fmt.Printf("%d\t%s\t%s\t%s\t%d\t%s\n", letter.ID, letter.Timestamp.Format("2006-01-02 15:04:05"), letter.MessageType, letter.Name, letter.Retries, letter.Reason)
+		}
+		return nil
+	}
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid dead letter id %s", arg)
+	}
+	letter, err := r.DeadLetterByID(id)
+	if err == repo.ErrNotFound {
+		return fmt.Errorf("dead letter %d not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "show":
+		fmt.Println(letter.Message)
+	case "replay":
+		q, err := queue.New(r)
+		if err != nil {
+			return err
+		}
+		defer q.Close()
+		if err = replay(q, letter); err != nil {
+			return fmt.Errorf("unable to replay dead letter %d - %v", id, err)
+		}
+		if err = r.DelDeadLetter(id); err != nil {
+			return err
+		}
+		log.Printf("Replayed dead letter %d\n", id)
+	case "delete":
+		if err = r.DelDeadLetter(id); err != nil {
+			return err
+		}
+		log.Printf("Deleted dead letter %d\n", id)
+	}
+	return nil
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	command := flag.Arg(0)
+	switch {
+	case command == "list" && flag.NArg() == 1:
+	case (command == "show" || command == "replay" || command == "delete") && flag.NArg() == 2:
+	default:
+		usage()
+	}
+	if err := run(command, flag.Arg(1)); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
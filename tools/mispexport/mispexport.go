@@ -0,0 +1,72 @@
+// mispexport exports the convicted content of a team as a MISP event or pushes it to the configured MISP
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/misp"
+	"github.com/demisto/alfred/repo"
+)
+
+var (
+	confFile = flag.String("conf", "conf.json", "Path to configuration file in JSON format")
+	team     = flag.String("team", "", "The Slack ID of the team to export the convictions of")
+	from     = flag.String("from", "", "Start date of the export as YYYY-MM-DD - 30 days ago if empty")
+	to       = flag.String("to", "", "End date of the export (exclusive) as YYYY-MM-DD - now if empty")
+	push     = flag.Bool("push", false, "Push the event to the MISP configured in the configuration file instead of printing it")
+)
+
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func date(s string, def time.Time) time.Time {
+	if s == "" {
+		return def
+	}
+	t, err := time.Parse("2006-01-02", s)
+	check(err)
+	return t
+}
+
+func main() {
+	flag.Parse()
+	if *team == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	err := conf.Load(*confFile, false)
+	check(err)
+	// We do not want to consume any messages ourselves
+	conf.Options.Web, conf.Options.Worker = false, false
+	end := date(*to, time.Now())
+	start := date(*from, end.AddDate(0, 0, -30))
+	r, err := repo.NewMySQL()
+	check(err)
+	defer r.Close()
+	t, err := r.TeamByExternalID(*team)
+	check(err)
+	convicted, err := r.Convicted(t.ID, start, end)
+	check(err)
+	e := misp.NewEvent(fmt.Sprintf("DBot convictions for %s from %s to %s", t.Name, start.Format("2006-01-02"), end.Format("2006-01-02")), end, convicted)
+	if !*push {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		check(enc.Encode(&misp.Export{Event: e}))
+		return
+	}
+	if conf.Options.MISP.URL == "" {
+		fmt.Fprintln(os.Stderr, "MISP URL is not configured")
+		os.Exit(1)
+	}
+	client := &misp.Client{URL: conf.Options.MISP.URL, Key: conf.Options.MISP.Key}
+	check(client.Push(e))
+	fmt.Printf("Pushed %d attributes from %d convictions\n", len(e.Attribute), len(convicted))
+}
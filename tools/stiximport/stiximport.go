@@ -0,0 +1,55 @@
+// stiximport loads the indicators of STIX 2.1 bundles into the intel of a team
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/repo"
+	"github.com/demisto/alfred/stix"
+)
+
+var (
+	confFile = flag.String("conf", "conf.json", "Path to configuration file in JSON format")
+	team     = flag.String("team", "", "The Slack ID of the team to import the intel for")
+)
+
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] bundle.json...\n", os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 || *team == "" {
+		usage()
+	}
+	err := conf.Load(*confFile, false)
+	check(err)
+	// We do not want to consume any messages ourselves
+	conf.Options.Web, conf.Options.Worker = false, false
+	r, err := repo.NewMySQL()
+	check(err)
+	defer r.Close()
+	t, err := r.TeamByExternalID(*team)
+	check(err)
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		check(err)
+		indicators, err := stix.Parse(f)
+		f.Close()
+		check(err)
+		check(r.StoreIntel(t.ID, indicators))
+		fmt.Printf("Imported %d indicators from %s\n", len(indicators), name)
+	}
+}
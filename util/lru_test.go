@@ -0,0 +1,28 @@
+package util
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if v, ok := c.Get("a"); !ok || v.(int) != 1 {
+		t.Error("Should find a")
+	}
+	// b is now the least recently used
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a should still be there")
+	}
+	c.Add("a", 4)
+	if v, _ := c.Get("a"); v.(int) != 4 {
+		t.Error("a should be replaced")
+	}
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 1 {
+		t.Error("a should be removed")
+	}
+}
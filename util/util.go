@@ -159,3 +159,49 @@ func init() {
 		logrus.Fatal(err)
 	}
 }
+
+// JoinMap joins the keys that are set to true with commas
+func JoinMap(m map[string]bool) string {
+	res := ""
+	for k, v := range m {
+		if v {
+			res += k + ","
+		}
+	}
+	if len(res) > 0 {
+		return res[0 : len(res)-1]
+	}
+	return res
+}
+
+// JoinMapInt joins the keys with their values in parenthesis
+func JoinMapInt(m map[string]int) string {
+	res := ""
+	for k, v := range m {
+		res += fmt.Sprintf("%s (%d),", k, v)
+	}
+	if len(res) > 0 {
+		return res[0 : len(res)-1]
+	}
+	return res
+}
+
+// JoinMapFloat32 joins the keys with their values
+func JoinMapFloat32(m map[string]float32) string {
+	res := ""
+	for k, v := range m {
+		res += fmt.Sprintf("%s: %v,", k, v)
+	}
+	if len(res) > 0 {
+		return res[0 : len(res)-1]
+	}
+	return res
+}
+
+// NilOrUnknown formats the value or returns Unknown if there is none
+func NilOrUnknown(v interface{}) string {
+	if v == nil {
+		return "Unknown"
+	}
+	return fmt.Sprintf("%v", v)
+}
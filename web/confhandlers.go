@@ -1,17 +1,24 @@
 package web
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/asaskevich/govalidator"
 	"github.com/demisto/alfred/conf"
 	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+	"github.com/demisto/alfred/misp"
+	"github.com/demisto/alfred/stix"
 	"github.com/demisto/alfred/util"
 	"github.com/demisto/slack"
 )
@@ -133,6 +140,12 @@ func (ac *AppContext) save(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if req.Policy != nil {
+		if err := req.Policy.Validate(); err != nil {
+			WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: fmt.Sprintf("Invalid verdict policy - %v", err)})
+			return
+		}
+	}
 	err := ac.r.SetChannelsAndGroups(req)
 	if err != nil {
 		panic(err)
@@ -148,6 +161,241 @@ func (ac *AppContext) save(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("\n"))
 }
 
+type allowlistRequest struct {
+	Entries []string `json:"entries"`
+}
+
+type allowlistResponse struct {
+	Allowlist []string `json:"allowlist"`
+}
+
+func (ac *AppContext) allowlist(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	allowlist, err := ac.r.Allowlist(u.Team)
+	if err != nil {
+		panic(err)
+	}
+	if allowlist == nil {
+		allowlist = []string{}
+	}
+	json.NewEncoder(w).Encode(allowlistResponse{Allowlist: allowlist})
+}
+
+// pushTeamConf lets the bots know the configuration of the team changed
+func (ac *AppContext) pushTeamConf(u *domain.User) {
+	team, err := ac.r.Team(u.Team)
+	if err != nil {
+		panic(err)
+	}
+	if err = ac.q.PushConf(team.ExternalID); err != nil {
+		logrus.WithError(err).Warnf("Unable to push configuration reload for team [%s]", team.ExternalID)
+	}
+}
+
+func (ac *AppContext) addToAllowlist(w http.ResponseWriter, r *http.Request) {
+	req := getRequestBody(r).(*allowlistRequest)
+	u := getRequestUser(r)
+	// Validate all the entries before adding any of them
+	entries := make([]string, len(req.Entries))
+	for i := range req.Entries {
+		entry, err := ioc.ParseEntry(req.Entries[i])
+		if err != nil {
+			WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: fmt.Sprintf("Invalid allowlist entry %s - %v", req.Entries[i], err)})
+			return
+		}
+		entries[i] = entry
+	}
+	for _, entry := range entries {
+		if err := ac.r.AddToAllowlist(u.Team, entry, u.ExternalID); err != nil {
+			panic(err)
+		}
+	}
+	ac.pushTeamConf(u)
+	w.WriteHeader(http.StatusNoContent)
+	w.Write([]byte("\n"))
+}
+
+func (ac *AppContext) removeFromAllowlist(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	entry, err := ioc.ParseEntry(r.URL.Query().Get("entry"))
+	if err != nil {
+		// Allow removing entries that were added as is from the message buttons
+		entry = r.URL.Query().Get("entry")
+	}
+	if entry == "" {
+		WriteError(w, ErrBadRequest)
+		return
+	}
+	if err = ac.r.RemoveFromAllowlist(u.Team, entry); err != nil {
+		panic(err)
+	}
+	ac.pushTeamConf(u)
+	w.WriteHeader(http.StatusNoContent)
+	w.Write([]byte("\n"))
+}
+
+// blocklistMaxBody is the largest blocklist import we accept
+const blocklistMaxBody = 5 << 20
+
+type blocklistRequest struct {
+	Entries []domain.BlocklistEntry `json:"entries"`
+}
+
+type blocklistResponse struct {
+	Blocklist []domain.BlocklistEntry `json:"blocklist"`
+}
+
+func (ac *AppContext) blocklist(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	blocklist, err := ac.r.Blocklist(u.Team)
+	if err != nil {
+		panic(err)
+	}
+	if blocklist == nil {
+		blocklist = []domain.BlocklistEntry{}
+	}
+	json.NewEncoder(w).Encode(blocklistResponse{Blocklist: blocklist})
+}
+
+// parseBlocklistCSV reads indicator and optional description rows. A first row with an indicator header is skipped.
+func parseBlocklistCSV(body io.Reader) ([]domain.BlocklistEntry, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var entries []domain.BlocklistEntry
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(record[0], "indicator") {
+			continue
+		}
+		entry := domain.BlocklistEntry{Indicator: record[0]}
+		if len(record) > 1 {
+			entry.Description = record[1]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// importBlocklist adds the entries from a JSON or a CSV body to the team blocklist
+func (ac *AppContext) importBlocklist(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	body := http.MaxBytesReader(w, r.Body, blocklistMaxBody)
+	var entries []domain.BlocklistEntry
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "application/json"):
+		req := &blocklistRequest{}
+		if err := json.NewDecoder(body).Decode(req); err != nil {
+			WriteError(w, ErrBadRequest)
+			return
+		}
+		entries = req.Entries
+	case strings.Contains(contentType, "text/csv"):
+		var err error
+		if entries, err = parseBlocklistCSV(body); err != nil {
+			WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: fmt.Sprintf("Error parsing CSV - %v", err)})
+			return
+		}
+	default:
+		WriteError(w, ErrUnsupportedMediaType)
+		return
+	}
+	// Validate all the entries before adding any of them
+	for i := range entries {
+		indicator, err := ioc.ParseEntry(entries[i].Indicator)
+		if err != nil {
+			WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: fmt.Sprintf("Invalid blocklist entry %s - %v", entries[i].Indicator, err)})
+			return
+		}
+		entries[i].Indicator = indicator
+	}
+	if err := ac.r.AddToBlocklist(u.Team, u.ExternalID, entries); err != nil {
+		panic(err)
+	}
+	ac.pushTeamConf(u)
+	w.WriteHeader(http.StatusNoContent)
+	w.Write([]byte("\n"))
+}
+
+func (ac *AppContext) removeFromBlocklist(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	entry, err := ioc.ParseEntry(r.URL.Query().Get("entry"))
+	if err != nil {
+		WriteError(w, ErrBadRequest)
+		return
+	}
+	if err = ac.r.RemoveFromBlocklist(u.Team, entry); err != nil {
+		panic(err)
+	}
+	ac.pushTeamConf(u)
+	w.WriteHeader(http.StatusNoContent)
+	w.Write([]byte("\n"))
+}
+
+// stixMaxBody is the largest STIX bundle we accept
+const stixMaxBody = 20 << 20
+
+type stixImportResponse struct {
+	Imported int `json:"imported"`
+}
+
+// importSTIX stores the indicators of a STIX 2.1 bundle in the team intel
+func (ac *AppContext) importSTIX(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	indicators, err := stix.Parse(http.MaxBytesReader(w, r.Body, stixMaxBody))
+	if err != nil {
+		WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: fmt.Sprintf("Error parsing STIX bundle - %v", err)})
+		return
+	}
+	if err = ac.r.StoreIntel(u.Team, indicators); err != nil {
+		panic(err)
+	}
+	json.NewEncoder(w).Encode(stixImportResponse{Imported: len(indicators)})
+}
+
+// mispExportDays is the default range of the MISP export
+const mispExportDays = 30
+
+// parseMISPRange of the export from the from and to parameters. A to date includes the whole day.
+func parseMISPRange(params url.Values) (from, to time.Time, err error) {
+	to = time.Now()
+	if s := params.Get("to"); s != "" {
+		if to, err = parseTimeParam(s, true); err != nil {
+			return from, to, fmt.Errorf("to must be a YYYY-MM-DD date or an RFC3339 time")
+		}
+	}
+	from = to.AddDate(0, 0, -mispExportDays)
+	if s := params.Get("from"); s != "" {
+		if from, err = parseTimeParam(s, false); err != nil {
+			return from, to, fmt.Errorf("from must be a YYYY-MM-DD date or an RFC3339 time")
+		}
+	}
+	return from, to, nil
+}
+
+// exportMISP convictions of the team between the from and to dates (YYYY-MM-DD) as a MISP event
+func (ac *AppContext) exportMISP(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	r.ParseForm()
+	from, to, err := parseMISPRange(r.Form)
+	if err != nil {
+		WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: err.Error()})
+		return
+	}
+	convicted, err := ac.r.Convicted(u.Team, from, to)
+	if err != nil {
+		panic(err)
+	}
+	// The last day of the range as to is exclusive
+	last := to.Add(-time.Nanosecond)
+	info := fmt.Sprintf("DBot convictions from %s to %s", from.Format("2006-01-02"), last.Format("2006-01-02"))
+	json.NewEncoder(w).Encode(&misp.Export{Event: misp.NewEvent(info, last, convicted)})
+}
+
 // Struct for parsing json in google's response
 type googleResponse struct {
 	Success    bool
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+func TestParseBlocklistCSV(t *testing.T) {
+	body := "indicator,description\nevil.com,IR-1\n10.0.0.0/8\n\"hxxp://evil[.]com/a\", \"IR-2, stage 2\"\n"
+	entries, err := parseBlocklistCSV(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []domain.BlocklistEntry{
+		{Indicator: "evil.com", Description: "IR-1"},
+		{Indicator: "10.0.0.0/8"},
+		{Indicator: "hxxp://evil[.]com/a", Description: "IR-2, stage 2"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, entries)
+	}
+	if _, err = parseBlocklistCSV(strings.NewReader("\"evil.com")); err == nil {
+		t.Error("Expected an error for a broken CSV")
+	}
+}
+
+func TestParseMISPRange(t *testing.T) {
+	from, to, err := parseMISPRange(url.Values{"from": {"2020-01-01"}, "to": {"2020-01-08"}})
+	if err != nil || !from.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2020, 1, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the to date to include the whole day but got %v - %v - %v", from, to, err)
+	}
+	from, to, err = parseMISPRange(url.Values{"to": {"2020-01-08T12:00:00Z"}})
+	if err != nil || !to.Equal(time.Date(2020, 1, 8, 12, 0, 0, 0, time.UTC)) || !from.Equal(to.AddDate(0, 0, -mispExportDays)) {
+		t.Errorf("Unexpected range %v - %v - %v", from, to, err)
+	}
+	for _, bad := range []url.Values{{"to": {"kuku"}}, {"from": {"2020-13-01"}}} {
+		if _, _, err = parseMISPRange(bad); err == nil {
+			t.Errorf("Expected error for %v", bad)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/util"
+)
+
+const (
+	// convictedPageSize is the default number of convictions in a page
+	convictedPageSize = 50
+	// convictedMaxPageSize is the largest page we return - the full CSV export is read in pages of this size
+	convictedMaxPageSize = 5000
+)
+
+// convictedTypes are the content type names of the type filter
+var convictedTypes = map[string]int{
+	"hash":   domain.ReplyTypeHash,
+	"url":    domain.ReplyTypeURL,
+	"ip":     domain.ReplyTypeIP,
+	"file":   domain.ReplyTypeFile,
+	"domain": domain.ReplyTypeDomain,
+}
+
+// convictedTypeName of the content type for the exports
+func convictedTypeName(contentType int) string {
+	for name, t := range convictedTypes {
+		if t == contentType {
+			return name
+		}
+	}
+	return strconv.Itoa(contentType)
+}
+
+// parseTimeParam accepts either a date or an RFC3339 time. A date as the end of a range includes the whole day.
+func parseTimeParam(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseConvictedQuery from the request parameters - channel, type, provider, from, to, page and limit
+func parseConvictedQuery(params url.Values) (*domain.ConvictedQuery, error) {
+	q := &domain.ConvictedQuery{Channel: params.Get("channel"), Provider: strings.ToLower(params.Get("provider")), Limit: convictedPageSize}
+	if s := params.Get("type"); s != "" {
+		t, ok := convictedTypes[strings.ToLower(s)]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s", s)
+		}
+		q.ContentType = t
+	}
+	if q.Provider != "" && !util.In(domain.ConvictedProviders, q.Provider) {
+		return nil, fmt.Errorf("unknown provider %s - it must be one of %s", q.Provider, strings.Join(domain.ConvictedProviders, ", "))
+	}
+	var err error
+	if s := params.Get("from"); s != "" {
+		if q.From, err = parseTimeParam(s, false); err != nil {
+			return nil, errors.New("from must be a YYYY-MM-DD date or an RFC3339 time")
+		}
+	}
+	if s := params.Get("to"); s != "" {
+		if q.To, err = parseTimeParam(s, true); err != nil {
+			return nil, errors.New("to must be a YYYY-MM-DD date or an RFC3339 time")
+		}
+	}
+	if s := params.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil || q.Limit < 1 || q.Limit > convictedMaxPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", convictedMaxPageSize)
+		}
+	}
+	if s := params.Get("page"); s != "" {
+		page, err := strconv.Atoi(s)
+		if err != nil || page < 1 {
+			return nil, errors.New("page must be a positive number")
+		}
+		q.Offset = (page - 1) * q.Limit
+	}
+	return q, nil
+}
+
+// convictedCSVHeader is the first row of the CSV export
+var convictedCSVHeader = []string{"time", "channel", "message_id", "type", "content", "file_name", "vt", "xfe", "clamav", "cy", "af", "intel"}
+
+// csvCell that spreadsheets will not run as a formula. The content comes from the chat so cells starting
+// with a formula character are prefixed with a quote.
+func csvCell(s string) string {
+	if s != "" && strings.IndexByte("=+-@\t\r", s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// writeConvictedRecords without the header
+func writeConvictedRecords(cw *csv.Writer, convicted []domain.MaliciousContent) {
+	for i := range convicted {
+		c := &convicted[i]
+		record := []string{c.Channel, c.MessageID, convictedTypeName(c.ContentType), c.Content, c.FileName, c.VT, c.XFE, c.ClamAV, c.Cy, c.AF, c.Intel}
+		for j := range record {
+			record[j] = csvCell(record[j])
+		}
+		cw.Write(append([]string{c.Timestamp.UTC().Format(time.RFC3339)}, record...))
+	}
+}
+
+// writeConvictedCSV with a header row
+func writeConvictedCSV(w io.Writer, convicted []domain.MaliciousContent) error {
+	cw := csv.NewWriter(w)
+	cw.Write(convictedCSVHeader)
+	writeConvictedRecords(cw, convicted)
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportConvicted streams all the matching convictions as CSV a page at a time so the export is not cut at the largest page
+func (ac *AppContext) exportConvicted(w io.Writer, team string, q *domain.ConvictedQuery, convicted []domain.MaliciousContent) error {
+	cw := csv.NewWriter(w)
+	cw.Write(convictedCSVHeader)
+	for {
+		writeConvictedRecords(cw, convicted)
+		cw.Flush()
+		if err := cw.Error(); err != nil || len(convicted) < q.Limit {
+			return err
+		}
+		q.Offset += q.Limit
+		var err error
+		if convicted, _, err = ac.r.QueryConvicted(team, q); err != nil {
+			return err
+		}
+	}
+}
+
+type convictedResponse struct {
+	Total     int                       `json:"total"`
+	Page      int                       `json:"page"`
+	Limit     int                       `json:"limit"`
+	Convicted []domain.MaliciousContent `json:"convicted"`
+}
+
+// convicted content of the team of the user - a team parameter must be the same team. With format=csv it is exported as CSV,
+// all of it unless a page or a limit is given.
+func (ac *AppContext) convicted(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	params := r.URL.Query()
+	if team := params.Get("team"); team != "" && team != u.Team {
+		t, err := ac.r.Team(u.Team)
+		if err != nil {
+			panic(err)
+		}
+		if team != t.ExternalID {
+			WriteError(w, ErrForbidden)
+			return
+		}
+	}
+	format := params.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: "format must be json or csv"})
+		return
+	}
+	q, err := parseConvictedQuery(params)
+	if err != nil {
+		WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: err.Error()})
+		return
+	}
+	// A CSV export without a page or a limit has all the matching convictions
+	all := format == "csv" && params.Get("page") == "" && params.Get("limit") == ""
+	if all {
+		q.Limit = convictedMaxPageSize
+	}
+	convicted, total, err := ac.r.QueryConvicted(u.Team, q)
+	if err != nil {
+		panic(err)
+	}
+	if convicted == nil {
+		convicted = []domain.MaliciousContent{}
+	}
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="convicted.csv"`)
+		if all {
+			if err = ac.exportConvicted(w, u.Team, q, convicted); err != nil {
+				// Part of the export was already sent so all we can do is log it
+				logrus.WithError(err).Warnf("Unable to export the convictions of team [%s]", u.Team)
+			}
+			return
+		}
+		if err = writeConvictedCSV(w, convicted); err != nil {
+			panic(err)
+		}
+		return
+	}
+	json.NewEncoder(w).Encode(convictedResponse{Total: total, Page: q.Offset/q.Limit + 1, Limit: q.Limit, Convicted: convicted})
+}
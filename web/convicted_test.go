@@ -0,0 +1,61 @@
+package web
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+func TestParseConvictedQuery(t *testing.T) {
+	params := url.Values{"channel": {"C1"}, "type": {"URL"}, "provider": {"vt"}, "from": {"2020-01-01"}, "to": {"2020-01-07"}, "page": {"3"}, "limit": {"10"}}
+	q, err := parseConvictedQuery(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Channel != "C1" || q.ContentType != domain.ReplyTypeURL || q.Provider != "vt" || q.Offset != 20 || q.Limit != 10 ||
+		!q.From.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) || !q.To.Equal(time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected query %+v", q)
+	}
+	if q, err = parseConvictedQuery(url.Values{}); err != nil || q.Limit != convictedPageSize || q.Offset != 0 || !q.From.IsZero() {
+		t.Errorf("Unexpected default query %+v - %v", q, err)
+	}
+	for _, bad := range []url.Values{
+		{"type": {"email"}}, {"provider": {"xfe; DROP TABLE convicted"}}, {"from": {"last week"}},
+		{"limit": {"0"}}, {"limit": {"100000"}}, {"page": {"0"}},
+	} {
+		if _, err = parseConvictedQuery(bad); err == nil {
+			t.Errorf("Expected an error for %v", bad)
+		}
+	}
+}
+
+func TestWriteConvictedCSV(t *testing.T) {
+	var b bytes.Buffer
+	err := writeConvictedCSV(&b, []domain.MaliciousContent{{Channel: "C1", MessageID: "1", ContentType: domain.ReplyTypeFile, Content: "44d88612fea8a8f36de82e1278abb02f",
+		FileName: "eicar, again.com", ClamAV: "Eicar-Test-Signature", Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "time,channel,message_id,type,content,file_name,vt,xfe,clamav,cy,af,intel\n" +
+		"2020-01-02T03:04:05Z,C1,1,file,44d88612fea8a8f36de82e1278abb02f,\"eicar, again.com\",,,Eicar-Test-Signature,,,\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, b.String())
+	}
+}
+
+func TestWriteConvictedCSVFormulas(t *testing.T) {
+	var b bytes.Buffer
+	err := writeConvictedCSV(&b, []domain.MaliciousContent{{Channel: "C1", MessageID: "1", ContentType: domain.ReplyTypeURL, Content: "=HYPERLINK(\"http://evil.com\")",
+		FileName: "+cmd", VT: "-1", XFE: "@SUM(A1)", Cy: "\tx", AF: "\rx", Intel: "a=b", Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "time,channel,message_id,type,content,file_name,vt,xfe,clamav,cy,af,intel\n" +
+		"2020-01-02T03:04:05Z,C1,1,url,\"'=HYPERLINK(\"\"http://evil.com\"\")\",'+cmd,'-1,'@SUM(A1),,'\tx,\"'\rx\",a=b\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, b.String())
+	}
+}
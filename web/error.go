@@ -38,6 +38,8 @@ var (
 	ErrBadContentRequest = &Error{"bad_content", 400, "Bad content", "Request contains bad content"}
 	// ErrAuth if not authenticated
 	ErrAuth = &Error{"unauthorized", 401, "Unauthorized", "The request requires authorization"}
+	// ErrBadSignature is returned if the Slack signature of the request does not match
+	ErrBadSignature = &Error{"bad_signature", 401, "Bad signature", "The request signature does not match"}
 	// ErrCredentials if there are missing / wrong credentials
 	ErrCredentials = &Error{"invalid_credentials", 401, "Invalid credentials", "Invalid username or password"}
 	// ErrNotFound if file is not found
@@ -52,6 +54,8 @@ var (
 	ErrForbidden = &Error{"forbidden", 403, "Forbidden", "Forbidden"}
 	// ErrInternalServer if things go wrong on our side
 	ErrInternalServer = &Error{"internal_server_error", 500, "Internal Server Error", "Something went wrong."}
+	// ErrTimeout is returned if the worker did not reply in time
+	ErrTimeout = &Error{"timeout", 504, "Gateway Timeout", "The request did not complete in time."}
 	// ErrCouldNotFindTeam ...
 	ErrCouldNotFindTeam = &Error{"could_find_team", 400, "Could not find slack team", "Could not find slack team"}
 )
@@ -7,7 +7,7 @@ import (
 
 func TestWriteError(t *testing.T) {
 	errors := []*Error{ErrBadRequest, ErrMissingPartRequest, ErrAuth, ErrCredentials, ErrNotAcceptable,
-		ErrUnsupportedMediaType, ErrCSRF, ErrForbidden, ErrInternalServer}
+		ErrUnsupportedMediaType, ErrCSRF, ErrForbidden, ErrInternalServer, ErrTimeout, ErrBadSignature}
 	for _, e := range errors {
 		w := httptest.NewRecorder()
 		WriteError(w, e)
@@ -1,10 +1,17 @@
 package web
 
 import (
+	"bytes"
 	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -79,6 +86,61 @@ func contentTypeHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+const (
+	slackSignatureHeader = "X-Slack-Signature"
+	slackTimestampHeader = "X-Slack-Request-Timestamp"
+	// slackSignatureWindow is how old a signed request can be before we consider it a replay
+	slackSignatureWindow = 5 * time.Minute
+	// slackMaxBody is the largest event body we are willing to read
+	slackMaxBody = 1 << 20
+)
+
+// slackSignature of the body at the given timestamp as described at https://api.slack.com/authentication/verifying-requests-from-slack
+func slackSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// slackSignatureHandler verifies that the request was sent by Slack and is not a replay
+func slackSignatureHandler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if conf.Options.Slack.SigningSecret == "" {
+			log.Error("Slack signing secret is not configured - rejecting event")
+			WriteError(w, ErrBadSignature)
+			return
+		}
+		ts, err := strconv.ParseInt(r.Header.Get(slackTimestampHeader), 10, 64)
+		if err != nil {
+			log.Warn("Request without Slack timestamp received")
+			WriteError(w, ErrBadSignature)
+			return
+		}
+		if age := time.Since(time.Unix(ts, 0)); age > slackSignatureWindow || age < -slackSignatureWindow {
+			log.Warnf("Slack request outside of the replay window - %v", age)
+			WriteError(w, ErrBadSignature)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, slackMaxBody))
+		if err != nil {
+			WriteError(w, ErrBadRequest)
+			return
+		}
+		expected := slackSignature(conf.Options.Slack.SigningSecret, r.Header.Get(slackTimestampHeader), body)
+		if !hmac.Equal([]byte(expected), []byte(r.Header.Get(slackSignatureHeader))) {
+			log.Warn("Request with bad Slack signature received")
+			WriteError(w, ErrBadSignature)
+			return
+		}
+		// The body handlers still need to read it
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
 const (
 	encodingGzip = "gzip"
 
@@ -0,0 +1,60 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/demisto/alfred/conf"
+)
+
+func TestSlackSignatureHandler(t *testing.T) {
+	conf.Options.Slack.SigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+	defer func() { conf.Options.Slack.SigningSecret = "" }()
+	body := `{"type":"event_callback"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		status    int
+	}{
+		{"valid", now, slackSignature(conf.Options.Slack.SigningSecret, now, []byte(body)), http.StatusOK},
+		{"wrong secret", now, slackSignature("kuku", now, []byte(body)), http.StatusUnauthorized},
+		{"missing signature", now, "", http.StatusUnauthorized},
+		{"missing timestamp", "", slackSignature(conf.Options.Slack.SigningSecret, "", []byte(body)), http.StatusUnauthorized},
+		{"replay", old, slackSignature(conf.Options.Slack.SigningSecret, old, []byte(body)), http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		var received string
+		h := slackSignatureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			received = string(b)
+		}))
+		r := httptest.NewRequest("POST", "/events", strings.NewReader(body))
+		r.Header.Set(slackTimestampHeader, test.timestamp)
+		r.Header.Set(slackSignatureHeader, test.signature)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d but got %d", test.name, test.status, w.Code)
+		}
+		if test.status == http.StatusOK && received != body {
+			t.Errorf("%s: the body was not passed on - %s", test.name, received)
+		}
+	}
+}
+
+func TestSlackSignatureKnownValue(t *testing.T) {
+	// The example from the Slack documentation
+	body := "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	expected := "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+	if sig := slackSignature("8f742231b10e8888abcd99yyyzzz85a5", "1531420618", []byte(body)); sig != expected {
+		t.Errorf("Expected %s but got %s", expected, sig)
+	}
+}
@@ -121,14 +121,32 @@ func New(appC *AppContext) *Router {
 	r.Get("/info", authHandlers.ThenFunc(appC.info))
 	r.Post("/match", authHandlers.Append(contentTypeHandler, bodyHandler(regexpMatch{})).ThenFunc(appC.match))
 	r.Post("/save", authHandlers.Append(contentTypeHandler, bodyHandler(domain.Configuration{})).ThenFunc(appC.save))
+	r.Get("/allowlist", authHandlers.ThenFunc(appC.allowlist))
+	r.Post("/allowlist", authHandlers.Append(contentTypeHandler, bodyHandler(allowlistRequest{})).ThenFunc(appC.addToAllowlist))
+	r.Delete("/allowlist", authHandlers.ThenFunc(appC.removeFromAllowlist))
+	r.Get("/blocklist", authHandlers.ThenFunc(appC.blocklist))
+	r.Post("/blocklist", authHandlers.ThenFunc(appC.importBlocklist))
+	r.Delete("/blocklist", authHandlers.ThenFunc(appC.removeFromBlocklist))
+	r.Post("/intel/stix", authHandlers.Append(contentTypeHandler).ThenFunc(appC.importSTIX))
+	r.Get("/convicted/misp", authHandlers.ThenFunc(appC.exportMISP))
+	r.Get("/api/convicted", authHandlers.ThenFunc(appC.convicted))
+	r.Get("/api/stats", authHandlers.ThenFunc(appC.stats))
+	r.Get("/webhooks", authHandlers.ThenFunc(appC.webhooks))
+	r.Post("/webhooks", authHandlers.Append(contentTypeHandler, bodyHandler(webhookRequest{})).ThenFunc(appC.addWebhook))
+	r.Delete("/webhooks", authHandlers.ThenFunc(appC.removeWebhook))
+	r.Get("/webhooks/deliveries", authHandlers.ThenFunc(appC.webhookDeliveries))
 	r.Get("/work", commonHandlers.ThenFunc(appC.work))
 	r.Post("/join", commonHandlers.Append(contentTypeHandler, bodyHandler(join{})).ThenFunc(appC.joinSlack))
 	r.Get("/messages", commonHandlers.ThenFunc(appC.totalMessages))
-	r.Post("/events", eventsHandler.Append(contentTypeHandler, bodyHandler(util.Object{})).ThenFunc(appC.events))
+	r.Post("/events", eventsHandler.Append(slackSignatureHandler, contentTypeHandler, bodyHandler(util.Object{})).ThenFunc(appC.events))
+	r.Post("/interactive", eventsHandler.Append(slackSignatureHandler).ThenFunc(appC.interactive))
+	r.Post("/slash", eventsHandler.Append(slackSignatureHandler).ThenFunc(appC.slash))
 	// Static
 	r.Get("/", staticHandlers.ThenFunc(pageHandler("/index.html")))
 	r.Get("/conf", staticHandlers.ThenFunc(pageHandler("/conf.html")))
 	r.Get("/details", staticHandlers.ThenFunc(pageHandler("/details.html")))
+	r.Get("/convicted", staticHandlers.ThenFunc(pageHandler("/convicted.html")))
+	r.Get("/integrations", staticHandlers.ThenFunc(pageHandler("/integrations.html")))
 	r.Get("/faq", staticHandlers.ThenFunc(pageHandler("/faq.html")))
 	r.Get("/slackuser", staticHandlers.ThenFunc(pageHandler("/slackuser.html")))
 	r.Get("/privacy", staticHandlers.ThenFunc(pageHandler("/privacy.html")))
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/demisto/alfred/domain"
+)
+
+const (
+	// statsDefaultDays is the default range of the statistics
+	statsDefaultDays = 30
+	// statsMaxBuckets we return in a single answer
+	statsMaxBuckets = 2000
+)
+
+// statsIntervals are the bucket intervals we support with their length
+var statsIntervals = map[string]time.Duration{
+	domain.StatsHour: time.Hour,
+	domain.StatsDay:  24 * time.Hour,
+	domain.StatsWeek: 7 * 24 * time.Hour,
+}
+
+type statsResponse struct {
+	Interval string              `json:"interval"`
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Total    domain.Statistics   `json:"total"`
+	Buckets  []domain.Statistics `json:"buckets"`
+}
+
+// stats of the team of the user between from and to (the last 30 days by default) bucketed by hour, day (default) or week
+func (ac *AppContext) stats(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	params := r.URL.Query()
+	interval := params.Get("interval")
+	if interval == "" {
+		interval = domain.StatsDay
+	}
+	length, ok := statsIntervals[interval]
+	if !ok {
+		WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: "interval must be hour, day or week"})
+		return
+	}
+	to := time.Now().UTC()
+	var err error
+	if s := params.Get("to"); s != "" {
+		if to, err = parseTimeParam(s, true); err != nil {
+			WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: "to must be a YYYY-MM-DD date or an RFC3339 time"})
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -statsDefaultDays)
+	if s := params.Get("from"); s != "" {
+		if from, err = parseTimeParam(s, false); err != nil {
+			WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: "from must be a YYYY-MM-DD date or an RFC3339 time"})
+			return
+		}
+	}
+	from = domain.StatsBucket(from, interval)
+	if !from.Before(to) || to.Sub(from)/length > statsMaxBuckets {
+		WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: "from must be before to and the range must fit in 2000 buckets"})
+		return
+	}
+	buckets, err := ac.r.StatisticsBuckets(u.Team, from, to, interval)
+	if err != nil {
+		panic(err)
+	}
+	res := statsResponse{Interval: interval, From: from, To: to, Total: domain.Statistics{Team: u.Team}, Buckets: buckets}
+	if res.Buckets == nil {
+		res.Buckets = []domain.Statistics{}
+	}
+	for i := range buckets {
+		res.Total.Add(&buckets[i])
+	}
+	json.NewEncoder(w).Encode(res)
+}
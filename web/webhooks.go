@@ -0,0 +1,135 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/demisto/alfred/conf"
+	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/repo"
+	"github.com/demisto/alfred/util"
+	"github.com/demisto/alfred/webhook"
+)
+
+const (
+	// webhookDeliveriesLimit is the default number of deliveries in the log
+	webhookDeliveriesLimit = 100
+	// webhookDeliveriesMax is the largest log we return
+	webhookDeliveriesMax = 1000
+	// webhookSecretSize of the secrets we generate
+	webhookSecretSize = 32
+)
+
+type webhookRequest struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+}
+
+type webhooksResponse struct {
+	Webhooks []domain.Webhook `json:"webhooks"`
+}
+
+type addWebhookResponse struct {
+	ID     int64  `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type webhookDeliveriesResponse struct {
+	Deliveries []domain.WebhookDelivery `json:"deliveries"`
+}
+
+// validWebhookURL must be an absolute HTTPS URL of a public host - plain HTTP and internal hosts are only allowed in development.
+// Names are checked again on every delivery when they are resolved so this only rejects the obvious ones early.
+func validWebhookURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || len(s) > 512 {
+		return errors.New("the webhook URL must be an absolute URL of up to 512 characters")
+	}
+	if conf.IsDev() {
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return errors.New("the webhook URL must use HTTP or HTTPS")
+		}
+		return nil
+	}
+	if u.Scheme != "https" {
+		return errors.New("the webhook URL must use HTTPS")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if ip := net.ParseIP(host); ip != nil && webhook.Forbidden(ip) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("the webhook URL must be on a public host")
+	}
+	return nil
+}
+
+func (ac *AppContext) webhooks(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	webhooks, err := ac.r.Webhooks(u.Team)
+	if err != nil {
+		panic(err)
+	}
+	if webhooks == nil {
+		webhooks = []domain.Webhook{}
+	}
+	json.NewEncoder(w).Encode(webhooksResponse{Webhooks: webhooks})
+}
+
+// addWebhook for the team of the user. The secret is generated if not given and is only returned here.
+func (ac *AppContext) addWebhook(w http.ResponseWriter, r *http.Request) {
+	req := getRequestBody(r).(*webhookRequest)
+	u := getRequestUser(r)
+	if err := validWebhookURL(req.URL); err != nil {
+		WriteError(w, &Error{ID: "bad_request", Status: 400, Title: "Bad Request", Detail: err.Error()})
+		return
+	}
+	if req.Secret == "" {
+		req.Secret = util.SecureRandomString(webhookSecretSize, false)
+	}
+	hook := &domain.Webhook{Team: u.Team, URL: req.URL, Secret: req.Secret, User: u.ExternalID}
+	if err := ac.r.AddWebhook(hook); err != nil {
+		panic(err)
+	}
+	json.NewEncoder(w).Encode(addWebhookResponse{ID: hook.ID, Secret: hook.Secret})
+}
+
+func (ac *AppContext) removeWebhook(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		WriteError(w, ErrBadRequest)
+		return
+	}
+	if err = ac.r.RemoveWebhook(u.Team, id); err == repo.ErrNotFound {
+		WriteError(w, ErrNotFound)
+		return
+	} else if err != nil {
+		panic(err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	w.Write([]byte("\n"))
+}
+
+// webhookDeliveries is the delivery log of the team - the latest first
+func (ac *AppContext) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	u := getRequestUser(r)
+	limit := webhookDeliveriesLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil || limit < 1 || limit > webhookDeliveriesMax {
+			WriteError(w, ErrBadRequest)
+			return
+		}
+	}
+	deliveries, err := ac.r.WebhookDeliveries(u.Team, limit)
+	if err != nil {
+		panic(err)
+	}
+	if deliveries == nil {
+		deliveries = []domain.WebhookDelivery{}
+	}
+	json.NewEncoder(w).Encode(webhookDeliveriesResponse{Deliveries: deliveries})
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/demisto/alfred/conf"
+)
+
+func TestValidWebhookURL(t *testing.T) {
+	conf.Options.Env = "PROD"
+	for s, valid := range map[string]bool{
+		"https://soar.example.com/hooks/dbot": true,
+		"http://soar.example.com/hooks/dbot":  false,
+		"https:///hooks":                      false,
+		"soar.example.com/hooks":              false,
+		"ftp://soar.example.com/hooks":        false,
+		"https://127.0.0.1/hooks":             false,
+		"https://[::1]:8443/hooks":            false,
+		"https://169.254.169.254/latest":      false,
+		"https://10.0.0.1/hooks":              false,
+		"https://localhost/hooks":             false,
+		"https://api.localhost./hooks":        false,
+		"https://203.0.113.7/hooks":           true,
+	} {
+		if err := validWebhookURL(s); (err == nil) != valid {
+			t.Errorf("Expected %s to be valid %v but got %v", s, valid, err)
+		}
+	}
+	conf.Options.Env = "DEV"
+	defer func() { conf.Options.Env = "" }()
+	if err := validWebhookURL("http://localhost:8080/hooks"); err != nil {
+		t.Errorf("Expected HTTP to be allowed in development - %v", err)
+	}
+}
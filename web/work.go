@@ -1,11 +1,17 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/demisto/alfred/conf"
 	"github.com/demisto/alfred/domain"
+	"github.com/demisto/alfred/ioc"
+	"github.com/demisto/alfred/queue"
 	"github.com/demisto/alfred/slack"
 	"github.com/demisto/alfred/util"
 	"github.com/demisto/go-uuid"
@@ -21,6 +27,57 @@ func (ac *AppContext) events(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// interactive handles the buttons on our messages - Slack posts them as a form with a JSON payload
+func (ac *AppContext) interactive(w http.ResponseWriter, r *http.Request) {
+	payload, err := util.NewObject([]byte(r.PostFormValue("payload")))
+	if err != nil {
+		WriteError(w, ErrBadRequest)
+		return
+	}
+	// Slack wants the ack within 3 seconds and handling the action posts back to Slack so do it in the background
+	if payload.S("type") == "block_actions" {
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					logrus.WithField("error", err).Warn("Recovered from error handling action")
+				}
+			}()
+			ac.b.HandleAction(payload)
+		}()
+	}
+	w.Write([]byte{'\n'})
+}
+
+const slashUsage = "Usage: `/dbot <indicator...>` - look up URLs, domains, IPs and hashes."
+
+// slashReply is shown right away to the user who ran the command
+type slashReply struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// slash handles the /dbot command - Slack expects an answer within 3 seconds so the lookup is queued
+// and the verdict is sent to the response URL by the bot
+func (ac *AppContext) slash(w http.ResponseWriter, r *http.Request) {
+	team, channel, user := r.PostFormValue("team_id"), r.PostFormValue("channel_id"), r.PostFormValue("user_id")
+	text, responseURL := r.PostFormValue("text"), r.PostFormValue("response_url")
+	if team == "" || responseURL == "" {
+		WriteError(w, ErrBadRequest)
+		return
+	}
+	reply := slashReply{ResponseType: "ephemeral", Text: slashUsage}
+	if indicators := ioc.Extract(text); len(indicators) > 0 {
+		if err := ac.b.HandleSlash(team, channel, user, text, responseURL); err != nil {
+			logrus.WithError(err).Warnf("Unable to handle slash command for team %s", team)
+			reply.Text = "I had an issue starting the lookup, please try again."
+		} else {
+			reply.Text = fmt.Sprintf("Looking up %d indicators, I will let you know shortly.", len(indicators))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
 func (ac *AppContext) work(w http.ResponseWriter, r *http.Request) {
 	team := r.FormValue("t")
 	file := r.FormValue("f")
@@ -41,6 +98,12 @@ func (ac *AppContext) work(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, ErrInternalServer)
 		return
 	}
+	configuration, err := ac.r.ChannelsAndGroups(t.ID)
+	if err != nil {
+		logrus.Warnf("Error loading team configuration - %v\n", err)
+		WriteError(w, ErrInternalServer)
+		return
+	}
 	uuid, err := uuid.NewRandom()
 	if err != nil {
 		panic(err)
@@ -49,18 +112,8 @@ func (ac *AppContext) work(w http.ResponseWriter, r *http.Request) {
 	var workReq *domain.WorkRequest
 	// If we have the actual text to show details for
 	if file == "" {
-		workReq = &domain.WorkRequest{
-			MessageID:  message,
-			Type:       "message",
-			Text:       text,
-			ReplyQueue: replyQueue,
-			Online:     true,
-			VTKey:      t.VTKey,
-			XFEKey:     t.XFEKey,
-			XFEPass:    t.XFEPass,
-			AFKey:      t.AFKey,
-			Context:    &domain.Context{},
-		}
+		workReq = domain.NewWorkRequest(t, configuration)
+		workReq.MessageID, workReq.Type, workReq.Text = message, "message", text
 	} else {
 		// Bot scope does not have file info and history permissions so we need to iterate users
 		users, err := ac.r.TeamMembers(team)
@@ -79,34 +132,16 @@ func (ac *AppContext) work(w http.ResponseWriter, r *http.Request) {
 					logrus.Infof("Error retrieving file info - %v\n", err)
 					continue
 				}
-				workReq = &domain.WorkRequest{
-					Type:       "file",
-					File:       domain.File{URL: info.S("file.url_private"), Name: info.S("file.name"), Size: info.I("file.size"), Token: t.BotToken},
-					ReplyQueue: replyQueue,
-					Context:    &domain.Context{},
-					Online:     true,
-					VTKey:      t.VTKey,
-					XFEKey:     t.XFEKey,
-					XFEPass:    t.XFEPass,
-					AFKey:      t.AFKey,
-				}
+				workReq = domain.NewWorkRequest(t, configuration)
+				workReq.Type = "file"
+				workReq.File = domain.File{URL: info.S("file.url_private"), Name: info.S("file.name"), Size: info.I("file.size"), Token: t.BotToken}
 				break
 			}
 		}
 		// Just retrieve the details for the MD5
 		if workReq == nil {
-			workReq = &domain.WorkRequest{
-				MessageID:  "file-message",
-				Type:       "message",
-				Text:       text,
-				Context:    &domain.Context{},
-				ReplyQueue: replyQueue,
-				Online:     true,
-				VTKey:      t.VTKey,
-				XFEKey:     t.XFEKey,
-				XFEPass:    t.XFEPass,
-				AFKey:      t.AFKey,
-			}
+			workReq = domain.NewWorkRequest(t, configuration)
+			workReq.MessageID, workReq.Type, workReq.Text = "file-message", "message", text
 		}
 	}
 	if workReq == nil {
@@ -114,16 +149,65 @@ func (ac *AppContext) work(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, ErrInternalServer)
 		return
 	}
+	// Someone is waiting for the answer on the details page
+	workReq.ReplyQueue, workReq.Context, workReq.Online, workReq.Priority = replyQueue, &domain.Context{}, true, domain.PriorityHigh
 	err = ac.q.PushWork(workReq)
 	if err != nil {
 		logrus.WithError(err).Error("Error pushing work")
 		WriteError(w, ErrInternalServer)
 		return
 	}
-	workReply, err := ac.q.PopWorkReply(replyQueue, 0)
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(conf.Options.WorkTimeout)*time.Second)
+	defer cancel()
+	workReply, err := ac.q.PopWorkReply(ctx, replyQueue)
+	if err == queue.ErrTimeout {
+		logrus.Warnf("Timeout waiting for work reply for team %s\n", team)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(ErrTimeout.Status)
+		json.NewEncoder(w).Encode(partialReply{Errors: []*Error{ErrTimeout}, Reply: partialWorkReply(workReq)})
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Error waiting for work reply")
+		WriteError(w, ErrInternalServer)
+		return
+	}
 	json.NewEncoder(w).Encode(workReply)
 }
 
+// partialReply is returned when the worker did not reply in time
+type partialReply struct {
+	Errors []*Error          `json:"errors"`
+	Reply  *domain.WorkReply `json:"reply"`
+}
+
+// partialWorkReply holds what we know without the worker - the indicators in the request with unknown results
+func partialWorkReply(req *domain.WorkRequest) *domain.WorkReply {
+	reply := &domain.WorkReply{MessageID: req.MessageID, Context: req.Context}
+	if req.Type == "file" {
+		reply.Type = domain.ReplyTypeFile
+		reply.File = domain.FileReply{Result: domain.ResultUnknown, Details: req.File}
+		return reply
+	}
+	for _, indicator := range ioc.Extract(req.Text) {
+		switch indicator.Type {
+		case ioc.URL:
+			reply.Type |= domain.ReplyTypeURL
+			reply.URLs = append(reply.URLs, domain.URLReply{Details: indicator.Value, Original: indicator.Original, Result: domain.ResultUnknown})
+		case ioc.IP:
+			reply.Type |= domain.ReplyTypeIP
+			reply.IPs = append(reply.IPs, domain.IPReply{Details: indicator.Value, Original: indicator.Original, Result: domain.ResultUnknown})
+		case ioc.Domain:
+			reply.Type |= domain.ReplyTypeDomain
+			reply.Domains = append(reply.Domains, domain.DomainReply{Details: indicator.Value, Original: indicator.Original, Result: domain.ResultUnknown})
+		case ioc.Hash:
+			reply.Type |= domain.ReplyTypeHash
+			reply.Hashes = append(reply.Hashes, domain.HashReply{Details: indicator.Value, Original: indicator.Original, Result: domain.ResultUnknown})
+		}
+	}
+	return reply
+}
+
 type messageCount struct {
 	Count int `json:"count"`
 }
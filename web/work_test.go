@@ -0,0 +1,62 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/demisto/alfred/domain"
+)
+
+func TestPartialWorkReply(t *testing.T) {
+	reply := partialWorkReply(&domain.WorkRequest{MessageID: "1", Type: "message", Text: "check evil[.]com and 8.8.8.8"})
+	if reply.MessageID != "1" || reply.Type != domain.ReplyTypeDomain|domain.ReplyTypeIP {
+		t.Fatalf("Wrong partial reply %+v", reply)
+	}
+	if len(reply.Domains) != 1 || reply.Domains[0].Details != "evil.com" || reply.Domains[0].Original != "evil[.]com" || reply.Domains[0].Result != domain.ResultUnknown {
+		t.Errorf("Wrong domains %+v", reply.Domains)
+	}
+	if len(reply.IPs) != 1 || reply.IPs[0].Details != "8.8.8.8" || reply.IPs[0].Result != domain.ResultUnknown {
+		t.Errorf("Wrong IPs %+v", reply.IPs)
+	}
+	reply = partialWorkReply(&domain.WorkRequest{Type: "file", File: domain.File{Name: "kuku.exe"}})
+	if reply.Type != domain.ReplyTypeFile || reply.File.Details.Name != "kuku.exe" || reply.File.Result != domain.ResultUnknown {
+		t.Errorf("Wrong file reply %+v", reply)
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	ac := &AppContext{}
+	for payload, status := range map[string]int{"kuku": http.StatusBadRequest, `{"type": "view_closed"}`: http.StatusOK} {
+		req := httptest.NewRequest("POST", "/interactive", strings.NewReader(url.Values{"payload": {payload}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		ac.interactive(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected %d for payload %s but got %d", status, payload, rec.Code)
+		}
+	}
+}
+
+func TestSlashUsage(t *testing.T) {
+	ac := &AppContext{}
+	form := url.Values{"team_id": {"T1"}, "response_url": {"https://hooks.slack.com/commands/1"}, "text": {"nothing to see"}}
+	req := httptest.NewRequest("POST", "/slash", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	ac.slash(rec, req)
+	reply := &slashReply{}
+	if err := json.NewDecoder(rec.Body).Decode(reply); err != nil || reply.Text != slashUsage || reply.ResponseType != "ephemeral" {
+		t.Errorf("Expected usage but got %+v - %v", reply, err)
+	}
+	req = httptest.NewRequest("POST", "/slash", strings.NewReader(url.Values{"text": {"evil.com"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	ac.slash(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request without a response URL but got %d", rec.Code)
+	}
+}
@@ -0,0 +1,147 @@
+// Package webhook posts events to the webhooks of a team signed with the webhook secret.
+// The signature follows the Slack scheme - "v0=" and the hex HMAC-SHA256 of "v0:<timestamp>:<body>".
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the signature of the body
+	SignatureHeader = "X-DBot-Signature"
+	// TimestampHeader carries the unix time the body was signed at
+	TimestampHeader = "X-DBot-Request-Timestamp"
+	// DeliveryHeader carries the ID of the delivery so receivers can ignore retries they already handled
+	DeliveryHeader = "X-DBot-Delivery"
+	// EventHeader carries the type of the event
+	EventHeader = "X-DBot-Event"
+)
+
+// Timeout of a single delivery
+const Timeout = 30 * time.Second
+
+// ErrForbiddenAddress is returned for webhooks on addresses of internal networks
+var ErrForbiddenAddress = errors.New("webhook: the address is not a public one")
+
+// internalNetworks webhooks cannot post to - loopback, private, shared, link-local and unspecified addresses
+var internalNetworks = func() []*net.IPNet {
+	var res []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+		"192.168.0.0/16", "::/128", "::1/128", "fc00::/7", "fe80::/10"} {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		res = append(res, ipnet)
+	}
+	return res
+}()
+
+// Forbidden checks if the address is not a public one. Multicast addresses are forbidden as well.
+func Forbidden(ip net.IP) bool {
+	if ip.IsMulticast() {
+		return true
+	}
+	for _, ipnet := range internalNetworks {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// control the connections so they only go to public addresses. The address is checked after it was resolved
+// so names that resolve to internal addresses, also by rebinding after the URL was validated, are caught as well.
+func control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || Forbidden(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// NewClient for the deliveries. It does not follow redirects and, unless allowInternal is set for development,
+// it refuses to connect to internal addresses. It connects directly so the check applies to the webhook itself.
+func NewClient(allowInternal bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowInternal {
+		dialer.Control = control
+	}
+	return &http.Client{
+		Timeout: Timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 2,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Sign the body with the secret at the given unix time
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify the signature of the body - receivers should also reject old timestamps to prevent replays
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Post the signed body to the webhook. Any 2xx status is a successful delivery - redirects are not followed
+// by the clients from NewClient so they fail the delivery.
+func Post(client *http.Client, url, secret, event string, delivery int64, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, Sign(secret, ts, body))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery, 10))
+	req.Header.Set(EventHeader, event)
+	if client == nil {
+		client = NewClient(false)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain some of the body so the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Backoff before the next attempt after the given number of failed attempts - doubling from 30 seconds up to an hour
+func Backoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
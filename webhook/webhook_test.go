@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPost(t *testing.T) {
+	var got []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !Verify("secret", r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) ||
+			r.Header.Get(DeliveryHeader) != "7" || r.Header.Get(EventHeader) != "convicted" {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		got = body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	body := []byte(`{"event": "convicted"}`)
+	client := NewClient(true)
+	if err := Post(client, server.URL, "secret", "convicted", 7, body); err != nil || string(got) != string(body) {
+		t.Errorf("Unexpected delivery of %s - %v", got, err)
+	}
+	if err := Post(client, server.URL, "wrong", "convicted", 7, body); err == nil {
+		t.Error("Expected an error for a bad signature")
+	}
+	if err := Post(nil, server.URL, "secret", "convicted", 7, body); err == nil || !strings.Contains(err.Error(), ErrForbiddenAddress.Error()) {
+		t.Errorf("Expected the loopback address to be refused but got %v", err)
+	}
+}
+
+func TestPostRedirect(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer server.Close()
+	if err := Post(NewClient(true), server.URL, "secret", "convicted", 7, []byte("{}")); err == nil || redirected {
+		t.Errorf("Expected the redirect to fail the delivery but got %v", err)
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	for s, forbidden := range map[string]bool{
+		"8.8.8.8":          false,
+		"2001:4860::8888":  false,
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.31.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"224.0.0.1":        true,
+		"::1":              true,
+		"fd00::1":          true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+	} {
+		if Forbidden(net.ParseIP(s)) != forbidden {
+			t.Errorf("Expected %s to be forbidden %v", s, forbidden)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// The same value the Slack documentation uses for its scheme
+	body := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c")
+	expected := "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+	if sig := Sign("8f742231b10e8888abcd99yyyzzz85a5", "1531420618", body); sig != expected {
+		t.Errorf("Expected %s but got %s", expected, sig)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, expected := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 20: time.Hour} {
+		if d := Backoff(attempts); d != expected {
+			t.Errorf("Expected %v after %d attempts but got %v", expected, attempts, d)
+		}
+	}
+}